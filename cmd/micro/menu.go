@@ -0,0 +1,451 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/micro-editor/tcell/v2"
+	"github.com/zyedidia/micro/v2/internal/action"
+	"github.com/zyedidia/micro/v2/internal/buffer"
+	"github.com/zyedidia/micro/v2/internal/clipboard"
+	"github.com/zyedidia/micro/v2/internal/config"
+	"github.com/zyedidia/micro/v2/internal/display"
+	ulua "github.com/zyedidia/micro/v2/internal/lua"
+	"github.com/zyedidia/micro/v2/internal/screen"
+	"github.com/zyedidia/micro/v2/internal/shell"
+	"github.com/zyedidia/micro/v2/internal/util"
+	luar "layeh.com/gopher-luar"
+)
+
+// menuToastDuration is how long a menu item's success message is shown
+const menuToastDuration = 2 * time.Second
+
+// menuRevealInterval is the time between revealing two rows of a dropdown
+// that rolls down
+const menuRevealInterval = 15 * time.Millisecond
+
+// menuPressDuration is how long a clicked menu is drawn pressed
+const menuPressDuration = 50 * time.Millisecond
+
+// menuRevealPending is set while the next row of a rolling down dropdown
+// is scheduled to be revealed
+var menuRevealPending bool
+
+// menuChoice is the item chosen from the menus whose action is running,
+// for actions that need more than their command line, see runMenuAction
+var menuChoice *display.Choice
+
+// menuButtonHeld is set while the first mouse button is held, so that
+// moving the mouse with it held doesn't press the menus again
+var menuButtonHeld bool
+
+// menuPromptShown is whether a prompt was shown when the menus were last
+// told about it, so that they are closed once when one opens
+var menuPromptShown bool
+
+// menuHoverPending is set while opening the menu or submenu the mouse
+// pointer rests on is scheduled
+var menuHoverPending bool
+
+// bufAction adapts a BufPane action to a menu action
+func bufAction(f func(*action.BufPane) bool) func(*action.BufPane) error {
+	return func(pane *action.BufPane) error {
+		f(pane)
+		return nil
+	}
+}
+
+// menuActions maps the actions of menu items to the functions running them
+var menuActions = map[string]func(*action.BufPane) error{
+	"NewTab": func(pane *action.BufPane) error {
+		pane.NewTabCmd([]string{})
+		return nil
+	},
+	"Open":   bufAction((*action.BufPane).OpenFile),
+	"Save":   bufAction((*action.BufPane).Save),
+	"SaveAs": bufAction((*action.BufPane).SaveAs),
+	"Quit":   bufAction((*action.BufPane).Quit),
+	"Undo":   bufAction((*action.BufPane).Undo),
+	"Redo":   bufAction((*action.BufPane).Redo),
+	"Cut":    bufAction((*action.BufPane).Cut),
+	"Copy":   bufAction((*action.BufPane).Copy),
+	"Paste":  bufAction((*action.BufPane).Paste),
+	"HSplit": bufAction((*action.BufPane).HSplitAction),
+	"VSplit": bufAction((*action.BufPane).VSplitAction),
+
+	"ToggleRuler":  bufAction((*action.BufPane).ToggleRuler),
+	"Find":         bufAction((*action.BufPane).Find),
+	"FindNext":     bufAction((*action.BufPane).FindNext),
+	"FindPrevious": bufAction((*action.BufPane).FindPrevious),
+	"Replace": func(pane *action.BufPane) error {
+		pane.ReplaceCmd([]string{})
+		return nil
+	},
+	"ReplaceInSelection": func(pane *action.BufPane) error {
+		// The replace command confines itself to the cursor's selection,
+		// which is set to the one the item was chosen with before running
+		// it. Repeating the item uses the current selection
+		selection := pane.Cursor.CurSelection
+		if menuChoice != nil && menuChoice.Selection[0] != menuChoice.Selection[1] {
+			selection = menuChoice.Selection
+		}
+		if selection[0] == selection[1] {
+			return errors.New("No selection to replace in")
+		}
+		action.InfoBar.Prompt("> ", "replace ", "Command", nil, func(resp string, canceled bool) {
+			if !canceled {
+				pane.Cursor.SetSelectionStart(selection[0])
+				pane.Cursor.SetSelectionEnd(selection[1])
+				pane.HandleCommand(resp)
+			}
+		})
+		return nil
+	},
+	"CommandMode": bufAction((*action.BufPane).CommandMode),
+	// TODO: Pre-fill with "plugin install " if possible
+	"PluginInstall": bufAction((*action.BufPane).CommandMode),
+	"ToggleHelp":    bufAction((*action.BufPane).ToggleHelp),
+	"ShowKey":       bufAction((*action.BufPane).ToggleKeyMenu),
+	"ShowAbout": func(pane *action.BufPane) error {
+		screen.TermMessage("Micro " + util.Version + " - " + util.CommitHash)
+		return nil
+	},
+	display.RepeatLastAction: func(pane *action.BufPane) error {
+		return action.MenuBar.RepeatLastAction()
+	},
+}
+
+// isMenuAction returns whether the given action can be run from a menu,
+// which is either a menu action or a command line
+func isMenuAction(name string) bool {
+	if name == "OpenURL" || strings.HasPrefix(name, "lua:") {
+		return true
+	}
+	if _, ok := menuActions[name]; ok {
+		return true
+	}
+	if fields := strings.Fields(name); len(fields) > 0 {
+		return action.IsCommand(fields[0])
+	}
+	return false
+}
+
+// initMenu sets up the menu bar and loads the user's menus.json
+// if there is one
+func initMenu() {
+	action.MenuBar.ValidAction = isMenuAction
+	action.MenuBar.SetActionRunner(executeMenuAction)
+	action.MenuBar.EditorState = menuEditorState
+	action.MenuBar.SetAutoHide(config.GetGlobalOption("menuautohide").(bool))
+	action.MenuBar.HoverDelayMs = int(config.GetGlobalOption("menuhoverdelay").(float64))
+
+	filename := filepath.Join(config.ConfigDir, "menus.json")
+	if _, e := os.Stat(filename); e == nil {
+		if err := action.MenuBar.LoadMenuConfig(filename); err != nil {
+			screen.TermMessage(err)
+		}
+	}
+
+	action.MenuBar.SetItemStateFunc("view", "ToggleRuler", func() bool {
+		pane := action.MainTab().CurPane()
+		return pane != nil && pane.Buf.Settings["ruler"].(bool)
+	})
+}
+
+// updateMenuBarVisibility reveals an auto-hidden menu bar while it is used
+// or Alt is held, keeps a revealed one shown while the mouse is on its row,
+// and hides it again afterwards
+func updateMenuBarVisibility(event tcell.Event) {
+	bar := action.MenuBar
+	if !bar.AutoHide {
+		return
+	}
+	if bar.IsOpen() || bar.IsFocused() {
+		bar.ShowTemporarily()
+		return
+	}
+	switch e := event.(type) {
+	case *tcell.EventKey:
+		if e.Modifiers()&tcell.ModAlt != 0 {
+			bar.ShowTemporarily()
+			return
+		}
+	case *tcell.EventMouse:
+		// A revealed bar stays shown while the mouse is on it, see DoEvent
+		if _, y := e.Position(); y == bar.Y && bar.Visible {
+			return
+		}
+	}
+	bar.HideIfAuto()
+}
+
+// scheduleMenuReveal reveals the next row of a dropdown that is rolling
+// down after menuRevealInterval, on the main loop
+func scheduleMenuReveal(dropdown *display.DropdownMenu) {
+	if menuRevealPending || !dropdown.Revealing() {
+		return
+	}
+	menuRevealPending = true
+	time.AfterFunc(menuRevealInterval, func() {
+		timerChan <- func() {
+			menuRevealPending = false
+			dropdown.Step()
+		}
+	})
+}
+
+// scheduleMenuHover opens the menu or submenu the mouse pointer rests on
+// once it has rested there for the menuhoverdelay option, on the main loop
+func scheduleMenuHover() {
+	if menuHoverPending || action.MenuBar == nil {
+		return
+	}
+	wait := action.MenuBar.HoverWait()
+	if wait == 0 {
+		return
+	}
+	menuHoverPending = true
+	time.AfterFunc(wait, func() {
+		timerChan <- func() {
+			menuHoverPending = false
+			action.MenuBar.HoverStep()
+		}
+	})
+}
+
+// updateMenuMotion has the terminal report the mouse pointer moving without
+// a button pressed while the menus want to know about it, so that hovering
+// switches menus, highlights items and opens submenus
+func updateMenuMotion() {
+	if action.MenuBar != nil {
+		screen.SetMouseMotion(action.MenuBar.WantsMotion())
+	}
+}
+
+// scheduleMenuRelease redraws the menu bar after menuPressDuration when a
+// clicked menu is about to be drawn pressed, so that it is only pressed
+// for a moment
+func scheduleMenuRelease() {
+	if action.MenuBar == nil || !action.MenuBar.Pressed() {
+		return
+	}
+	time.AfterFunc(menuPressDuration, screen.Redraw)
+}
+
+// recentFilesHistory is the history the recently opened files are kept in,
+// which is saved along with the prompt histories
+const recentFilesHistory = "RecentFiles"
+
+// recordedBuffers are the open buffers whose files have been added to the
+// recent files
+var recordedBuffers = make(map[*buffer.Buffer]bool)
+
+// updateRecentFiles adds the files of newly opened buffers to the recent
+// files and lists them in the menu if there are any
+func updateRecentFiles() {
+	changed := false
+	recorded := make(map[*buffer.Buffer]bool, len(buffer.OpenBuffers))
+	for _, b := range buffer.OpenBuffers {
+		if recordedBuffers[b] {
+			recorded[b] = true
+		} else if b.Type == buffer.BTDefault && b.AbsPath != "" {
+			action.InfoBar.AddToHistory(recentFilesHistory, b.AbsPath)
+			recorded[b] = true
+			changed = true
+		}
+	}
+	recordedBuffers = recorded
+	if changed {
+		listRecentFiles()
+	}
+}
+
+// listRecentFiles lists the recent files in the menu, most recent first
+func listRecentFiles() {
+	history := action.InfoBar.History[recentFilesHistory]
+	paths := make([]string, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		paths = append(paths, history[i])
+	}
+	action.MenuBar.SetRecentFiles(paths)
+}
+
+// listedBuffers and listedBuffer are the buffer names and the index of the
+// active buffer last listed in the Window menu
+var (
+	listedBuffers []string
+	listedBuffer  = -1
+)
+
+// updateBufferList lists the open buffers in the Window menu if they or the
+// active buffer have changed since they were last listed
+func updateBufferList() {
+	panes := action.BufPanes()
+	names := make([]string, len(panes))
+	active := -1
+	cur := action.MainTab().CurPane()
+	for i, p := range panes {
+		names[i] = p.Buf.GetName()
+		if p.Buf.AbsPath != "" {
+			names[i] = p.Buf.AbsPath
+		}
+		if cur != nil && p.Buf == cur.Buf {
+			active = i
+		}
+	}
+
+	if active == listedBuffer && len(names) == len(listedBuffers) {
+		same := true
+		for i := range names {
+			same = same && names[i] == listedBuffers[i]
+		}
+		if same {
+			return
+		}
+	}
+	listedBuffers, listedBuffer = names, active
+	action.MenuBar.SetBufferList(names, active)
+}
+
+// menuDescription is the description of the highlighted menu item that is
+// shown in the info bar
+var menuDescription string
+
+// updateMenuDescription shows the description of the highlighted menu item
+// in the info bar, and clears it once no item with a description is
+// highlighted anymore
+func updateMenuDescription() {
+	desc := ""
+	if dropdown := action.MenuBar.GetActiveDropdown(); dropdown != nil && dropdown.IsVisible() {
+		desc = dropdown.ActiveDescription()
+	}
+	if desc == menuDescription {
+		return
+	}
+	if desc != "" {
+		action.InfoBar.Message(desc)
+	} else if action.InfoBar.HasMessage && action.InfoBar.Msg == menuDescription {
+		action.InfoBar.Message("")
+	}
+	menuDescription = desc
+}
+
+// menuEditorState collects the editor state used to enable or disable
+// menu items
+func menuEditorState() display.EditorState {
+	var state display.EditorState
+	if pane := action.MainTab().CurPane(); pane != nil {
+		state.HasSelection = pane.Cursor.HasSelection()
+		state.Selection = pane.Cursor.CurSelection
+		state.CanUndo = pane.Buf.UndoStack.Len() > 0
+		state.CanRedo = pane.Buf.RedoStack.Len() > 0
+		state.Modified = pane.Buf.Modified()
+	}
+	if clip, err := clipboard.Read(clipboard.ClipboardReg); err == nil {
+		state.HasClipboard = clip != ""
+	}
+	return state
+}
+
+// runMenuItem executes an item chosen from the menus and reports the
+// outcome
+func runMenuItem(choice *display.Choice) {
+	if choice.URL != "" {
+		openMenuURL(choice.URL)
+		return
+	}
+	if choice.NeedsInput() {
+		item := *choice.DropdownItem
+		choice := *choice
+		choice.DropdownItem = &item
+		action.InfoBar.Prompt(item.Prompt, "", "Menu "+item.Action, nil, func(resp string, canceled bool) {
+			if !canceled {
+				runMenuAction(&choice, item.Action+" "+resp)
+			}
+		})
+		return
+	}
+	runMenuAction(choice, choice.Action)
+}
+
+// runMenuAction runs the command line of a chosen item as many times as
+// its repeat count and reports the outcome
+func runMenuAction(choice *display.Choice, cmdline string) {
+	menuChoice = choice
+	defer func() { menuChoice = nil }()
+	for i := 0; i < choice.Count; i++ {
+		if err := action.MenuBar.RunAction(cmdline); err != nil {
+			action.InfoBar.Error(err)
+			return
+		}
+	}
+	if choice.SuccessMessage != "" {
+		showMenuToast(choice.SuccessMessage)
+	}
+}
+
+// openMenuURL opens a URL in the browser, falling back to copying it to
+// the clipboard if no browser could be launched
+func openMenuURL(url string) {
+	if err := shell.OpenURL(url); err != nil {
+		if err := clipboard.Write(url, clipboard.ClipboardReg); err != nil {
+			action.InfoBar.Error("Could not open ", url)
+			return
+		}
+		action.InfoBar.Message("Could not open a browser, copied ", url, " to the clipboard")
+	}
+}
+
+// showMenuToast displays a message in the info bar and clears it again
+// after menuToastDuration unless it has been replaced in the meantime
+func showMenuToast(msg string) {
+	action.InfoBar.Message(msg)
+	time.AfterFunc(menuToastDuration, func() {
+		timerChan <- func() {
+			if action.InfoBar.HasMessage && action.InfoBar.Msg == msg {
+				action.InfoBar.Message("")
+			}
+		}
+	})
+}
+
+// executeMenuAction executes the specified action from a menu selection.
+// Actions that aren't menu actions are run as command lines
+func executeMenuAction(actionName string) error {
+	// Get the current buffer pane to perform actions on
+	pane := action.MainTab().CurPane()
+	if pane == nil {
+		return nil
+	}
+
+	if strings.HasPrefix(actionName, "lua:") {
+		return runLuaMenuAction(pane, strings.TrimPrefix(actionName, "lua:"))
+	}
+
+	if f, ok := menuActions[actionName]; ok {
+		return f(pane)
+	}
+	if fields := strings.Fields(actionName); len(fields) > 0 && action.IsCommand(fields[0]) {
+		pane.HandleCommand(actionName)
+		return nil
+	}
+	return errors.New("Unknown action: " + actionName)
+}
+
+// runLuaMenuAction calls the plugin function fn, given as plugin.function,
+// with the current pane
+func runLuaMenuAction(pane *action.BufPane, fn string) error {
+	plName, plFn, ok := strings.Cut(fn, ".")
+	if !ok {
+		return errors.New("Invalid lua action: " + fn)
+	}
+	pl := config.FindPlugin(plName)
+	if pl == nil {
+		return errors.New("Plugin not found: " + plName)
+	}
+	_, err := pl.Call(plFn, luar.New(ulua.L, pane))
+	return err
+}
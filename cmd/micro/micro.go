@@ -386,6 +386,7 @@ func main() {
 	}
 
 	action.InitGlobals()
+	initMenu()
 	buffer.SetMessager(action.InfoBar)
 	args := flag.Args()
 	b := LoadInput(args)
@@ -397,6 +398,9 @@ func main() {
 	}
 
 	action.InitTabs(b)
+	listRecentFiles()
+	updateRecentFiles()
+	updateBufferList()
 
 	err = config.RunPluginFn("init")
 	if err != nil {
@@ -470,11 +474,6 @@ func DoEvent() {
 	// Hide cursor initially (will be shown by panes if no dropdown is open)
 	screen.Screen.HideCursor()
 
-	// Display menu bar first (at the top) - but not the dropdown yet
-	if action.MenuBar != nil {
-		action.MenuBar.Display()
-	}
-
 	action.Tabs.Display()
 	for _, ep := range action.MainTab().Panes {
 		ep.Display()
@@ -482,6 +481,15 @@ func DoEvent() {
 	action.MainTab().Display()
 	action.InfoBar.Display()
 
+	// Display menu bar after the panes, an auto-hidden bar is drawn over
+	// them - but not the dropdown yet
+	if action.MenuBar != nil {
+		scheduleMenuRelease()
+		scheduleMenuHover()
+		updateMenuMotion()
+		action.MenuBar.Display()
+	}
+
 	// Display dropdown menus LAST so they appear on top of everything
 	if dropdownOpen {
 		dropdown := action.MenuBar.GetActiveDropdown()
@@ -489,6 +497,7 @@ func DoEvent() {
 			dropdown.Display()
 			// Force cursor to be hidden when dropdown is visible
 			screen.Screen.HideCursor()
+			scheduleMenuReveal(dropdown)
 		}
 	}
 
@@ -530,6 +539,25 @@ func DoEvent() {
 		return
 	}
 
+	if e, ok := event.(*tcell.EventRaw); ok && (e.EscSeq() == screen.FocusIn || e.EscSeq() == screen.FocusOut) {
+		// Menus don't stay open in a terminal that lost the focus, and
+		// the button can be released elsewhere
+		if e.EscSeq() == screen.FocusOut && action.MenuBar != nil {
+			action.MenuBar.CloseAll()
+			menuButtonHeld = false
+		}
+		return
+	}
+
+	if action.MenuBar != nil && action.InfoBar.HasPrompt != menuPromptShown {
+		menuPromptShown = action.InfoBar.HasPrompt
+		action.MenuBar.SetModalActive(menuPromptShown)
+		// A prompt takes the keyboard focus away from the menus
+		if menuPromptShown {
+			action.MenuBar.CloseAll()
+		}
+	}
+
 	if event != nil {
 		_, resize := event.(*tcell.EventResize)
 		if resize {
@@ -549,42 +577,75 @@ func DoEvent() {
 				switch e := event.(type) {
 				case *tcell.EventMouse:
 					mx, my := e.Position()
-					if clickedItem := action.MenuBar.HandleClick(mx, my); clickedItem != nil {
-						// Menu item was clicked, execute the action
-						executeMenuAction(clickedItem.Action)
-						handled = true
+					// tcell reports moving the mouse with the button held
+					// as Button1 too, only a fresh press may open a menu
+					pressed := !menuButtonHeld
+					menuButtonHeld = e.Buttons()&tcell.Button1 != 0
+					// Clicking the top row shows an auto-hidden menu bar,
+					// and the click is kept from the editor the row was
+					// given to
+					revealed := false
+					if action.MenuBar.AutoHide && !action.MenuBar.Visible && pressed && e.Buttons() == tcell.Button1 && my == action.MenuBar.Y {
+						action.MenuBar.ShowTemporarily()
+						revealed = true
 					}
-				case *tcell.EventKey:
-					// Handle keyboard navigation for menus and dropdowns
-					var selectedItem *display.DropdownItem
-
-					// Only handle special keys if menu is open
-					if action.MenuBar.IsOpen() {
-						// Menu is open - handle navigation keys
-						if e.Key() == tcell.KeyEnter || e.Key() == tcell.KeyEscape ||
-							e.Key() == tcell.KeyUp || e.Key() == tcell.KeyDown ||
-							e.Key() == tcell.KeyLeft || e.Key() == tcell.KeyRight {
-							selectedItem = action.MenuBar.HandleKeyNavigation(e.Rune(), int(e.Key()))
-							handled = true
-						} else {
-							// Menu is open, check for dropdown item hotkeys
-							selectedItem = action.MenuBar.HandleKeyNavigation(e.Rune(), int(e.Key()))
-							if selectedItem != nil {
-								handled = true
+					if e.Buttons()&tcell.WheelUp != 0 && action.MenuBar.HandleWheel(mx, my, -1) {
+						handled = true
+					} else if e.Buttons()&tcell.WheelDown != 0 && action.MenuBar.HandleWheel(mx, my, 1) {
+						handled = true
+					} else if e.Buttons() == tcell.Button1 && e.Modifiers() == tcell.ModNone {
+						// Pressing on a menu and releasing on one of its items
+						// chooses it, the same as clicking the menu and the item.
+						// Clicks with modifiers choose the items' variants
+						// Dragging from the editor across the menus, such as
+						// to select text, is left to the editor
+						if action.MenuBar.IsDragging() {
+							handled = action.MenuBar.HandleMouseDrag(mx, my)
+						} else if pressed {
+							handled = action.MenuBar.HandleMouseDown(mx, my)
+						}
+					} else if e.Buttons() == tcell.ButtonNone && action.MenuBar.IsDragging() {
+						if released, ended := action.MenuBar.HandleMouseUp(mx, my); ended {
+							if released != nil {
+								runMenuItem(released)
 							}
+							handled = true
 						}
-					} else if e.Modifiers()&tcell.ModAlt != 0 {
-						// Menu is closed - only handle Alt+key combinations to open menus
-						selectedItem = action.MenuBar.HandleKeyNavigation(e.Rune(), int(e.Key()))
-						if selectedItem != nil || action.MenuBar.IsOpen() {
+					} else if e.Buttons() == tcell.ButtonNone && action.MenuBar.HandleMotion(mx, my) {
+						handled = true
+					} else if action.MenuBar.IsOpen() || action.MenuBar.HitTest(mx, my) {
+						// Clicks away from the menus only concern them while
+						// one is open, which they close
+						if clicked, consumed := action.MenuBar.HandleClick(mx, my, e.Buttons(), e.Modifiers()); consumed {
+							// Menu item was clicked, execute the action
+							if clicked != nil {
+								runMenuItem(clicked)
+							}
 							handled = true
 						}
 					}
+					handled = handled || revealed
+				case *tcell.EventKey:
+					action.MenuBar.SetAltHeld(e.Modifiers()&tcell.ModAlt != 0)
+
+					// Handle keyboard navigation for menus and dropdowns
+					var selected *display.Choice
+
+					if e.Key() == tcell.KeyEscape && action.MenuBar.GetActive() >= 0 && !action.MenuBar.IsOpen() && !action.MenuBar.IsFocused() {
+						// A highlighted menu that isn't open is closed by Escape
+						action.MenuBar.CloseAll()
+						handled = true
+					} else if action.MenuBar.IsOpen() || action.MenuBar.IsFocused() ||
+						(e.Modifiers()&tcell.ModAlt != 0 && action.MenuBar.WantsKey(e.Rune())) {
+						// An open menu owns the keyboard until it closes, a
+						// closed one only takes the keys opening menus
+						selected, handled = action.MenuBar.HandleKeyNavigation(e.Rune(), int(e.Key()), e.Modifiers())
+					}
 
 					// Execute action if a menu item was selected
-					if selectedItem != nil {
+					if selected != nil {
 						// Execute the selected action
-						executeMenuAction(selectedItem.Action)
+						runMenuItem(selected)
 						handled = true
 					}
 				}
@@ -594,6 +655,13 @@ func DoEvent() {
 			if !handled {
 				action.Tabs.HandleEvent(event)
 			}
+
+			if action.MenuBar != nil {
+				updateMenuBarVisibility(event)
+				updateMenuDescription()
+				updateRecentFiles()
+				updateBufferList()
+			}
 		}
 	}
 
@@ -602,66 +670,3 @@ func DoEvent() {
 		screen.TermMessage(err)
 	}
 }
-
-// executeMenuAction executes the specified action from a menu selection
-func executeMenuAction(actionName string) {
-	// Get the current buffer pane to perform actions on
-	pane := action.MainTab().CurPane()
-	if pane == nil {
-		return
-	}
-
-	// Execute the appropriate action based on the action name
-	switch actionName {
-	case "NewTab":
-		pane.NewTabCmd([]string{})
-	case "Open":
-		pane.OpenFile()
-	case "Save":
-		pane.Save()
-	case "SaveAs":
-		pane.SaveAs()
-	case "Quit":
-		pane.Quit()
-	case "Undo":
-		pane.Undo()
-	case "Redo":
-		pane.Redo()
-	case "Cut":
-		pane.Cut()
-	case "Copy":
-		pane.Copy()
-	case "Paste":
-		pane.Paste()
-	case "HSplit":
-		pane.HSplitAction()
-	case "VSplit":
-		pane.VSplitAction()
-	case "ToggleRuler":
-		pane.ToggleRuler()
-	case "Find":
-		pane.Find()
-	case "FindNext":
-		pane.FindNext()
-	case "FindPrevious":
-		pane.FindPrevious()
-	case "Replace":
-		pane.ReplaceCmd([]string{})
-	case "CommandMode":
-		pane.CommandMode()
-	case "PluginInstall":
-		// Open command mode with plugin install command
-		pane.CommandMode()
-		// TODO: Pre-fill with "plugin install " if possible
-	case "ToggleHelp":
-		pane.ToggleHelp()
-	case "ShowKey":
-		pane.ToggleKeyMenu()
-	case "ShowAbout":
-		// Display about information
-		screen.TermMessage("Micro " + util.Version + " - " + util.CommitHash)
-	default:
-		// If action not recognized, try to execute it as a command
-		screen.TermMessage("Unknown action: " + actionName)
-	}
-}
@@ -325,6 +325,86 @@ func TestSearchAndReplace(t *testing.T) {
 	assert.Equal(t, srTest3, string(data))
 }
 
+func TestMenuFocusLoss(t *testing.T) {
+	injectKey(tcell.KeyRune, 'i', tcell.ModAlt)
+	assert.True(t, action.MenuBar.IsOpen())
+
+	// The menus are closed when the terminal loses the focus
+	sim.PostEvent(tcell.NewEventRaw(screen.FocusOut))
+	handleEvent()
+	assert.False(t, action.MenuBar.IsOpen())
+	assert.Equal(t, -1, action.MenuBar.GetActive())
+
+	// Menus opened from a prompt stay open once it is done
+	injectKey(tcell.KeyRune, ';', tcell.ModAlt)
+	assert.True(t, action.InfoBar.HasPrompt)
+	injectString("se")
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	assert.False(t, action.InfoBar.HasPrompt)
+	assert.True(t, action.MenuBar.IsOpen())
+	assert.Equal(t, "search", action.MenuBar.GetMenuAction())
+	injectKey(tcell.KeyEscape, 0, tcell.ModNone)
+	assert.False(t, action.MenuBar.IsOpen())
+}
+
+func TestMenuHover(t *testing.T) {
+	injectKey(tcell.KeyRune, 'i', tcell.ModAlt)
+	assert.Equal(t, "file", action.MenuBar.GetMenuAction())
+
+	// Moving the pointer onto Edit without a button opens it instead
+	injectMouse(8, 0, tcell.ButtonNone, tcell.ModNone)
+	assert.True(t, action.MenuBar.IsOpen())
+	assert.Equal(t, "edit", action.MenuBar.GetMenuAction())
+
+	// Moving it over the dropdown highlights the item under it. The
+	// screen is drawn before each event is handled, so every motion is
+	// sent twice to see its effect
+	injectMouse(8, 0, tcell.ButtonNone, tcell.ModNone)
+	dropdown := action.MenuBar.GetActiveDropdown()
+	x, y := dropdown.X+3, dropdown.Y+2
+	_, _, before, _ := sim.GetContent(x, y)
+	injectMouse(x, y, tcell.ButtonNone, tcell.ModNone)
+	injectMouse(x, y, tcell.ButtonNone, tcell.ModNone)
+	_, _, after, _ := sim.GetContent(x, y)
+	assert.NotEqual(t, before, after)
+
+	// Moving it over the editor leaves the menu open
+	injectMouse(40, 15, tcell.ButtonNone, tcell.ModNone)
+	assert.True(t, action.MenuBar.IsOpen())
+	injectKey(tcell.KeyEscape, 0, tcell.ModNone)
+	assert.False(t, action.MenuBar.IsOpen())
+}
+
+func TestMenuAutoHideClick(t *testing.T) {
+	action.MenuBar.SetAutoHide(true)
+	action.Tabs.Resize()
+	defer func() {
+		action.MenuBar.SetAutoHide(false)
+		action.Tabs.Resize()
+	}()
+	assert.False(t, action.MenuBar.Visible)
+	injectString("top")
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	injectString("bottom")
+	cursor := action.MainTab().CurPane().Cursor.Loc
+	assert.NotEqual(t, 0, cursor.Y)
+
+	// Clicking the top row shows the bar without moving the cursor
+	injectMouse(60, 0, tcell.Button1, tcell.ModNone)
+	injectMouse(60, 0, tcell.ButtonNone, tcell.ModNone)
+	assert.True(t, action.MenuBar.Visible)
+	assert.False(t, action.MenuBar.IsOpen())
+	assert.Equal(t, cursor, action.MainTab().CurPane().Cursor.Loc)
+
+	// Dragging onto the row from the editor leaves the bar hidden
+	injectMouse(10, 5, tcell.Button1, tcell.ModNone)
+	assert.False(t, action.MenuBar.Visible)
+	injectMouse(10, 0, tcell.Button1, tcell.ModNone)
+	assert.False(t, action.MenuBar.Visible)
+	injectMouse(10, 0, tcell.ButtonNone, tcell.ModNone)
+	assert.False(t, action.MenuBar.Visible)
+}
+
 func TestMultiCursor(t *testing.T) {
 	// TODO
 }
@@ -1840,6 +1840,50 @@ func (h *BufPane) ToggleKeyMenu() bool {
 	return true
 }
 
+// ToggleMenuBar gives keyboard focus to the menu bar, or returns it to the
+// buffer if the menu bar already has it
+func (h *BufPane) ToggleMenuBar() bool {
+	if MenuBar != nil {
+		MenuBar.ToggleFocus()
+	}
+	return true
+}
+
+// FocusMenuBar gives keyboard focus to the menu bar without opening any
+// menu, for terminals that don't pass Alt combinations through
+func (h *BufPane) FocusMenuBar() bool {
+	if MenuBar == nil {
+		return false
+	}
+	return MenuBar.FocusBar()
+}
+
+// OpenMenuByName asks for the start of a menu's name and opens the first
+// menu on the menu bar whose name starts with it
+func (h *BufPane) OpenMenuByName() bool {
+	if MenuBar == nil {
+		return false
+	}
+	InfoBar.Prompt("Menu: ", "", "MenuName", nil, func(resp string, canceled bool) {
+		if !canceled && !MenuBar.OpenByPrefix(resp) {
+			InfoBar.Error("No menu named ", resp)
+		}
+	})
+	return true
+}
+
+// RepeatMenuAction runs the action last chosen from the menu bar again
+func (h *BufPane) RepeatMenuAction() bool {
+	if MenuBar == nil {
+		return false
+	}
+	if err := MenuBar.RepeatLastAction(); err != nil {
+		InfoBar.Error(err)
+		return false
+	}
+	return true
+}
+
 // ShellMode opens a terminal to run a shell command
 func (h *BufPane) ShellMode() bool {
 	InfoBar.Prompt("$ ", "", "Shell", nil, func(resp string, canceled bool) {
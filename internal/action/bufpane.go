@@ -810,6 +810,10 @@ var BufKeyActions = map[string]BufKeyAction{
 	"EndOfLine":                 (*BufPane).EndOfLine,
 	"ToggleHelp":                (*BufPane).ToggleHelp,
 	"ToggleKeyMenu":             (*BufPane).ToggleKeyMenu,
+	"ToggleMenuBar":             (*BufPane).ToggleMenuBar,
+	"FocusMenuBar":              (*BufPane).FocusMenuBar,
+	"OpenMenuByName":            (*BufPane).OpenMenuByName,
+	"RepeatMenuAction":          (*BufPane).RepeatMenuAction,
 	"ToggleDiffGutter":          (*BufPane).ToggleDiffGutter,
 	"ToggleRuler":               (*BufPane).ToggleRuler,
 	"ToggleHighlightSearch":     (*BufPane).ToggleHighlightSearch,
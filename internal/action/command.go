@@ -32,39 +32,41 @@ var commands map[string]Command
 
 func InitCommands() {
 	commands = map[string]Command{
-		"set":        {(*BufPane).SetCmd, OptionValueComplete},
-		"reset":      {(*BufPane).ResetCmd, OptionValueComplete},
-		"setlocal":   {(*BufPane).SetLocalCmd, OptionValueComplete},
-		"show":       {(*BufPane).ShowCmd, OptionComplete},
-		"showkey":    {(*BufPane).ShowKeyCmd, nil},
-		"run":        {(*BufPane).RunCmd, nil},
-		"bind":       {(*BufPane).BindCmd, nil},
-		"unbind":     {(*BufPane).UnbindCmd, nil},
-		"quit":       {(*BufPane).QuitCmd, nil},
-		"goto":       {(*BufPane).GotoCmd, nil},
-		"jump":       {(*BufPane).JumpCmd, nil},
-		"save":       {(*BufPane).SaveCmd, nil},
-		"replace":    {(*BufPane).ReplaceCmd, nil},
-		"replaceall": {(*BufPane).ReplaceAllCmd, nil},
-		"vsplit":     {(*BufPane).VSplitCmd, buffer.FileComplete},
-		"hsplit":     {(*BufPane).HSplitCmd, buffer.FileComplete},
-		"tab":        {(*BufPane).NewTabCmd, buffer.FileComplete},
-		"help":       {(*BufPane).HelpCmd, HelpComplete},
-		"eval":       {(*BufPane).EvalCmd, nil},
-		"log":        {(*BufPane).ToggleLogCmd, nil},
-		"plugin":     {(*BufPane).PluginCmd, PluginComplete},
-		"reload":     {(*BufPane).ReloadCmd, nil},
-		"reopen":     {(*BufPane).ReopenCmd, nil},
-		"cd":         {(*BufPane).CdCmd, buffer.FileComplete},
-		"pwd":        {(*BufPane).PwdCmd, nil},
-		"open":       {(*BufPane).OpenCmd, buffer.FileComplete},
-		"tabmove":    {(*BufPane).TabMoveCmd, nil},
-		"tabswitch":  {(*BufPane).TabSwitchCmd, nil},
-		"term":       {(*BufPane).TermCmd, nil},
-		"memusage":   {(*BufPane).MemUsageCmd, nil},
-		"retab":      {(*BufPane).RetabCmd, nil},
-		"raw":        {(*BufPane).RawCmd, nil},
-		"textfilter": {(*BufPane).TextFilterCmd, nil},
+		"set":          {(*BufPane).SetCmd, OptionValueComplete},
+		"reset":        {(*BufPane).ResetCmd, OptionValueComplete},
+		"setlocal":     {(*BufPane).SetLocalCmd, OptionValueComplete},
+		"show":         {(*BufPane).ShowCmd, OptionComplete},
+		"showkey":      {(*BufPane).ShowKeyCmd, nil},
+		"run":          {(*BufPane).RunCmd, nil},
+		"bind":         {(*BufPane).BindCmd, nil},
+		"unbind":       {(*BufPane).UnbindCmd, nil},
+		"quit":         {(*BufPane).QuitCmd, nil},
+		"goto":         {(*BufPane).GotoCmd, nil},
+		"jump":         {(*BufPane).JumpCmd, nil},
+		"save":         {(*BufPane).SaveCmd, nil},
+		"replace":      {(*BufPane).ReplaceCmd, nil},
+		"replaceall":   {(*BufPane).ReplaceAllCmd, nil},
+		"vsplit":       {(*BufPane).VSplitCmd, buffer.FileComplete},
+		"hsplit":       {(*BufPane).HSplitCmd, buffer.FileComplete},
+		"tab":          {(*BufPane).NewTabCmd, buffer.FileComplete},
+		"help":         {(*BufPane).HelpCmd, HelpComplete},
+		"eval":         {(*BufPane).EvalCmd, nil},
+		"log":          {(*BufPane).ToggleLogCmd, nil},
+		"plugin":       {(*BufPane).PluginCmd, PluginComplete},
+		"reload":       {(*BufPane).ReloadCmd, nil},
+		"reopen":       {(*BufPane).ReopenCmd, nil},
+		"cd":           {(*BufPane).CdCmd, buffer.FileComplete},
+		"pwd":          {(*BufPane).PwdCmd, nil},
+		"open":         {(*BufPane).OpenCmd, buffer.FileComplete},
+		"tabmove":      {(*BufPane).TabMoveCmd, nil},
+		"tabswitch":    {(*BufPane).TabSwitchCmd, nil},
+		"switchbuffer": {(*BufPane).SwitchBufferCmd, nil},
+		"menutree":     {(*BufPane).MenuTreeCmd, buffer.FileComplete},
+		"term":         {(*BufPane).TermCmd, nil},
+		"memusage":     {(*BufPane).MemUsageCmd, nil},
+		"retab":        {(*BufPane).RetabCmd, nil},
+		"raw":          {(*BufPane).RawCmd, nil},
+		"textfilter":   {(*BufPane).TextFilterCmd, nil},
 	}
 }
 
@@ -76,6 +78,12 @@ func MakeCommand(name string, action func(bp *BufPane, args []string), completer
 	}
 }
 
+// IsCommand returns whether a command with the given name exists
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
 // CommandEditAction returns a bindable function that opens a prompt with
 // the given string and executes the command when the user presses
 // enter
@@ -239,6 +247,59 @@ func (h *BufPane) TabSwitchCmd(args []string) {
 	}
 }
 
+// SwitchBufferCmd switches to the pane showing a given buffer, by its
+// number in the list of open buffers (see BufPanes)
+func (h *BufPane) SwitchBufferCmd(args []string) {
+	if len(args) < 1 {
+		InfoBar.Error("Not enough arguments")
+		return
+	}
+	num, err := strconv.Atoi(args[0])
+	panes := BufPanes()
+	if err != nil || num < 1 || num > len(panes) {
+		InfoBar.Error("Invalid buffer index")
+		return
+	}
+
+	pane := panes[num-1]
+	for i, t := range Tabs.List {
+		for j, p := range t.Panes {
+			if p == pane {
+				Tabs.SetActive(i)
+				t.SetActive(j)
+				return
+			}
+		}
+	}
+}
+
+// MenuTreeCmd writes a text outline of the menus to the given file, or
+// shows it in a vsplit if no file is given
+func (h *BufPane) MenuTreeCmd(args []string) {
+	if MenuBar == nil {
+		InfoBar.Error("The menu bar is disabled")
+		return
+	}
+	tree := MenuBar.AccessibilityTree()
+	if len(args) == 0 {
+		b := buffer.NewBufferFromString(tree, "", buffer.BTHelp)
+		b.SetName("Menus")
+		h.VSplitBuf(b)
+		return
+	}
+
+	path, err := util.ReplaceHome(args[0])
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if err := os.WriteFile(path, []byte(tree), util.FileMode); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	InfoBar.Message("Wrote the menus to ", path)
+}
+
 // CdCmd changes the current working directory
 func (h *BufPane) CdCmd(args []string) {
 	if len(args) > 0 {
@@ -586,6 +647,17 @@ func doSetGlobalOptionNative(option string, nativeValue interface{}) error {
 		}
 	} else if option == "infobar" || option == "keymenu" {
 		Tabs.Resize()
+	} else if option == "menuautohide" {
+		if MenuBar != nil {
+			MenuBar.SetAutoHide(nativeValue.(bool))
+			Tabs.Resize()
+		}
+	} else if option == "menuhoverdelay" {
+		if MenuBar != nil {
+			MenuBar.HoverDelayMs = int(nativeValue.(float64))
+		}
+	} else if option == "menucompact" || option == "menucompactwidth" {
+		Tabs.Resize()
 	} else if option == "mouse" {
 		if !nativeValue.(bool) {
 			screen.Screen.DisableMouse()
@@ -66,6 +66,7 @@ var bufdefaults = map[string]string{
 	"ShiftPageDown":  "SelectPageDown",
 	"Ctrl-g":         "ToggleHelp",
 	"Alt-g":          "ToggleKeyMenu",
+	"Alt-;":          "OpenMenuByName",
 	"Ctrl-r":         "ToggleRuler",
 	"Ctrl-l":         "command-edit:goto ",
 	"Delete":         "Delete",
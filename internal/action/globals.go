@@ -30,6 +30,11 @@ func GetInfoBar() *InfoPane {
 	return InfoBar
 }
 
+// GetMenuBar returns the menu bar
+func GetMenuBar() *display.MenuWindow {
+	return MenuBar
+}
+
 // WriteLog writes a string to the log buffer
 func WriteLog(s string) {
 	buffer.WriteLog(s)
@@ -24,18 +24,19 @@ type TabList struct {
 func NewTabList(bufs []*buffer.Buffer) *TabList {
 	w, h := screen.Screen.Size()
 	iOffset := config.GetInfoBarOffset()
-	menuBarHeight := 1 // Reserve space for menu bar
+	menuBarHeight := reservedMenuBarHeight()
+	menuBarWidth := reservedMenuBarWidth()
 
 	tl := new(TabList)
 	tl.List = make([]*Tab, len(bufs))
 	if len(bufs) > 1 {
 		// Multiple tabs: content starts below menu bar + tab bar
 		for i, b := range bufs {
-			tl.List[i] = NewTabFromBuffer(0, menuBarHeight+1, w, h-1-iOffset-menuBarHeight, b)
+			tl.List[i] = NewTabFromBuffer(menuBarWidth, menuBarHeight+1, w-menuBarWidth, h-1-iOffset-menuBarHeight, b)
 		}
 	} else {
 		// Single tab: content starts directly below menu bar
-		tl.List[0] = NewTabFromBuffer(0, menuBarHeight, w, h-iOffset-menuBarHeight, bufs[0])
+		tl.List[0] = NewTabFromBuffer(menuBarWidth, menuBarHeight, w-menuBarWidth, h-iOffset-menuBarHeight, bufs[0])
 	}
 	// TabWindow positioned below menu bar
 	tl.TabWindow = display.NewTabWindow(w, menuBarHeight)
@@ -80,6 +81,24 @@ func (t *TabList) RemoveTab(id uint64) {
 	}
 }
 
+// reservedMenuBarHeight returns the number of rows at the top of the
+// screen taken by the menu bar
+func reservedMenuBarHeight() int {
+	if MenuBar == nil {
+		return 1
+	}
+	return MenuBar.ReservedHeight()
+}
+
+// reservedMenuBarWidth returns the number of columns on the left of the
+// screen taken by the sidebar of vertical menus
+func reservedMenuBarWidth() int {
+	if MenuBar == nil {
+		return 0
+	}
+	return MenuBar.ReservedWidth()
+}
+
 // Resize resizes all elements within the tab list
 // One thing to note is that when there is only 1 tab
 // the tab bar should not be drawn so resizing must take
@@ -88,11 +107,18 @@ func (t *TabList) Resize() {
 	w, h := screen.Screen.Size()
 	iOffset := config.GetInfoBarOffset()
 
-	// Reserve space for menu bar at the top
-	menuBarHeight := 1
+	// Reserve space for menu bar at the top, or for the sidebar of
+	// vertical menus on the left, which runs down to the info bar
+	menuBarHeight := reservedMenuBarHeight()
+	menuBarWidth := reservedMenuBarWidth()
 	if MenuBar != nil {
-		MenuBar.Resize(w, menuBarHeight)
+		if MenuBar.Orientation == display.Vertical {
+			MenuBar.Resize(w, h-iOffset)
+		} else {
+			MenuBar.Resize(w, 1)
+		}
 	}
+	t.TabWindow.Y = menuBarHeight
 
 	// InfoBar remains at the bottom
 	InfoBar.Resize(w, h-1)
@@ -101,14 +127,16 @@ func (t *TabList) Resize() {
 		// Tab bar is below menu bar
 		tabBarY := menuBarHeight
 		for _, p := range t.List {
+			p.X = menuBarWidth
 			p.Y = tabBarY + 1 // Content starts below both menu and tab bars
-			p.Node.Resize(w, h-1-iOffset-menuBarHeight)
+			p.Node.Resize(w-menuBarWidth, h-1-iOffset-menuBarHeight)
 			p.Resize()
 		}
 	} else if len(t.List) == 1 {
 		// Single tab: content starts directly below menu bar
+		t.List[0].X = menuBarWidth
 		t.List[0].Y = menuBarHeight
-		t.List[0].Node.Resize(w, h-iOffset-menuBarHeight)
+		t.List[0].Node.Resize(w-menuBarWidth, h-iOffset-menuBarHeight)
 		t.List[0].Resize()
 	}
 	t.TabWindow.Resize(w, h)
@@ -241,6 +269,22 @@ func MainTab() *Tab {
 	return Tabs.List[Tabs.Active()]
 }
 
+// BufPanes returns the panes of the open buffers, tab by tab. A buffer
+// shown in several panes is listed with the first of them
+func BufPanes() []*BufPane {
+	var panes []*BufPane
+	seen := make(map[*buffer.Buffer]bool)
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			if bp, ok := p.(*BufPane); ok && !seen[bp.Buf] {
+				seen[bp.Buf] = true
+				panes = append(panes, bp)
+			}
+		}
+	}
+	return panes
+}
+
 // A Tab represents a single tab
 // It consists of a list of edit panes (the open buffers),
 // a split tree (stored as just the root node), and a uiwindow
@@ -21,22 +21,27 @@ type optionValidator func(string, interface{}) error
 
 // a list of settings that need option validators
 var optionValidators = map[string]optionValidator{
-	"autosave":        validateNonNegativeValue,
-	"clipboard":       validateChoice,
-	"colorcolumn":     validateNonNegativeValue,
-	"colorscheme":     validateColorscheme,
-	"detectlimit":     validateNonNegativeValue,
-	"encoding":        validateEncoding,
-	"fileformat":      validateChoice,
-	"helpsplit":       validateChoice,
-	"matchbracestyle": validateChoice,
-	"multiopen":       validateChoice,
-	"pageoverlap":     validateNonNegativeValue,
-	"reload":          validateChoice,
-	"scrollmargin":    validateNonNegativeValue,
-	"scrollspeed":     validateNonNegativeValue,
-	"tabsize":         validatePositiveValue,
-	"truecolor":       validateChoice,
+	"autosave":         validateNonNegativeValue,
+	"clipboard":        validateChoice,
+	"colorcolumn":      validateNonNegativeValue,
+	"colorscheme":      validateColorscheme,
+	"detectlimit":      validateNonNegativeValue,
+	"encoding":         validateEncoding,
+	"fileformat":       validateChoice,
+	"helpsplit":        validateChoice,
+	"matchbracestyle":  validateChoice,
+	"menuborders":      validateChoice,
+	"menucompact":      validateChoice,
+	"menucompactwidth": validateNonNegativeValue,
+	"menuhoverdelay":   validateNonNegativeValue,
+	"menurecentfiles":  validateNonNegativeValue,
+	"multiopen":        validateChoice,
+	"pageoverlap":      validateNonNegativeValue,
+	"reload":           validateChoice,
+	"scrollmargin":     validateNonNegativeValue,
+	"scrollspeed":      validateNonNegativeValue,
+	"tabsize":          validatePositiveValue,
+	"truecolor":        validateChoice,
 }
 
 // a list of settings with pre-defined choices
@@ -45,6 +50,8 @@ var OptionChoices = map[string][]string{
 	"fileformat":      {"unix", "dos"},
 	"helpsplit":       {"hsplit", "vsplit"},
 	"matchbracestyle": {"underline", "highlight"},
+	"menuborders":     {"auto", "unicode", "ascii"},
+	"menucompact":     {"auto", "always", "never"},
 	"multiopen":       {"tab", "hsplit", "vsplit"},
 	"reload":          {"prompt", "auto", "disabled"},
 	"truecolor":       {"auto", "on", "off"},
@@ -109,27 +116,40 @@ var defaultCommonSettings = map[string]interface{}{
 // a list of settings that should only be globally modified and their
 // default values
 var DefaultGlobalOnlySettings = map[string]interface{}{
-	"autosave":       float64(0),
-	"clipboard":      "external",
-	"colorscheme":    "default",
-	"divchars":       "|-",
-	"divreverse":     true,
-	"fakecursor":     false,
-	"helpsplit":      "hsplit",
-	"infobar":        true,
-	"keymenu":        false,
-	"mouse":          true,
-	"multiopen":      "tab",
-	"parsecursor":    false,
-	"paste":          false,
-	"pluginchannels": []string{"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json"},
-	"pluginrepos":    []string{},
-	"savehistory":    true,
-	"scrollbarchar":  "|",
-	"sucmd":          "sudo",
-	"tabhighlight":   false,
-	"tabreverse":     true,
-	"xterm":          false,
+	"autosave":              float64(0),
+	"clipboard":             "external",
+	"colorscheme":           "default",
+	"divchars":              "|-",
+	"divreverse":            true,
+	"fakecursor":            false,
+	"helpsplit":             "hsplit",
+	"infobar":               true,
+	"keymenu":               false,
+	"menuanimate":           false,
+	"menuautohide":          false,
+	"menuautoopen":          false,
+	"menuborders":           "auto",
+	"menubreadcrumbs":       false,
+	"menucompact":           "auto",
+	"menucompactwidth":      float64(30),
+	"menufocusring":         false,
+	"menuhighlightdisabled": false,
+	"menuhoverdelay":        float64(0),
+	"menunotch":             false,
+	"menurecentfiles":       float64(10),
+	"menurememberselection": false,
+	"mouse":                 true,
+	"multiopen":             "tab",
+	"parsecursor":           false,
+	"paste":                 false,
+	"pluginchannels":        []string{"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json"},
+	"pluginrepos":           []string{},
+	"savehistory":           true,
+	"scrollbarchar":         "|",
+	"sucmd":                 "sudo",
+	"tabhighlight":          false,
+	"tabreverse":            true,
+	"xterm":                 false,
 }
 
 // a list of settings that should never be globally modified
@@ -0,0 +1,50 @@
+package display
+
+import (
+	"github.com/micro-editor/tcell/v2"
+	"github.com/zyedidia/micro/v2/internal/config"
+	"github.com/zyedidia/micro/v2/internal/screen"
+)
+
+// Cell is a character cell of a grid the menus can be rendered into
+type Cell struct {
+	Rune  rune
+	Comb  []rune // Combining characters drawn with Rune
+	Style tcell.Style
+}
+
+// setContentFunc draws a character and its combining characters at the
+// given position
+type setContentFunc func(x, y int, r rune, comb []rune, style tcell.Style)
+
+// safeSetContent draws a character on the screen, silently dropping writes
+// outside of it so that the menus never draw off-screen, and all of them
+// before the screen is initialized
+func safeSetContent(x, y int, r rune, comb []rune, style tcell.Style) {
+	if screen.Screen == nil {
+		return
+	}
+	w, h := screen.Screen.Size()
+	if x < 0 || x >= w || y < 0 || y >= h {
+		return
+	}
+	screen.SetContent(x, y, r, comb, style)
+}
+
+// newCellGrid returns a grid of blank cells of the given size along with a
+// function drawing into it, which ignores positions outside of the grid
+func newCellGrid(width, height int) ([][]Cell, setContentFunc) {
+	cells := make([][]Cell, height)
+	for y := range cells {
+		cells[y] = make([]Cell, width)
+		for x := range cells[y] {
+			cells[y][x] = Cell{' ', nil, config.DefStyle}
+		}
+	}
+	set := func(x, y int, r rune, comb []rune, style tcell.Style) {
+		if y >= 0 && y < height && x >= 0 && x < width {
+			cells[y][x] = Cell{r, comb, style}
+		}
+	}
+	return cells, set
+}
@@ -1,10 +1,19 @@
 package display
 
 import (
+	"image"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	runewidth "github.com/mattn/go-runewidth"
+	"github.com/micro-editor/tcell/v2"
 	"github.com/zyedidia/micro/v2/internal/config"
 	"github.com/zyedidia/micro/v2/internal/screen"
-	"github.com/zyedidia/micro/v2/internal/util"
 )
 
 // DropdownItem represents a single item in a dropdown menu
@@ -13,9 +22,127 @@ type DropdownItem struct {
 	Action    string
 	Hotkey    rune
 	Enabled   bool
-	Separator bool // True for separator lines
+	Separator bool // True for separator lines, labeled with Text if it is set
+	Icon      rune // Glyph drawn before the text, 0 for none
+
+	// HotkeyLabel is shown in parentheses after the text instead of the
+	// hotkey, such as "Ctrl+Shift+P" for an item run by a key chord. The
+	// hotkey still chooses the item from the keyboard
+	HotkeyLabel string
+
+	// Style overrides the colors of the item, such as red for a
+	// destructive action. The item is still reversed while selected and
+	// dimmed while disabled. Nil uses the dropdown's colors
+	Style *tcell.Style
+
+	// SuccessMessage is briefly shown in the info bar after the item's
+	// action completes without an error
+	SuccessMessage string
+
+	// URL is opened in the default browser instead of running Action
+	URL string
+
+	// Prompt asks for input with this prompt, such as "Line: ", when the
+	// item is chosen, and then runs Action with the input appended to it
+	// as an argument, see NeedsInput
+	Prompt string
+
+	// AltAction is a variant of Action, such as opening a file in a new
+	// tab instead of the current one, which is run instead of Action and
+	// URL when the item is middle-clicked or clicked with a modifier held
+	AltAction string
+
+	// Repeatable items are run as many times as the repeat count typed
+	// before selecting them
+	Repeatable bool
+
+	// Submenu is opened to the side of the item instead of running Action
+	Submenu *DropdownMenu
+
+	// Expanded items of accordion dropdowns show the items of their
+	// Submenu inline below them, see DropdownMenu.Accordion
+	Expanded bool
+
+	// Description is shown in the info bar while the item is highlighted
+	Description string
+
+	// DisabledReason explains why the item is disabled, it is shown along
+	// with the item's text while the disabled item is highlighted
+	DisabledReason string
+
+	// Shortcut is the key binding shown right-aligned next to the item
+	Shortcut string
+
+	// Checkable items show a checkmark when Checked. If StateFunc is set
+	// it is called to refresh Checked whenever the dropdown is shown
+	Checkable bool
+	Checked   bool
+	StateFunc func() bool
+
+	// Items sharing a RadioGroup are mutually exclusive choices, the
+	// Checked one is marked with a filled circle and the others with an
+	// empty one. StateFunc refreshes Checked as for checkable items, and
+	// SetRadioSelection checks one item of the group
+	RadioGroup string
+
+	// KeepOpenOnSelect items, meant for checkable and radio items, leave
+	// their dropdown open and highlighted when they are chosen, so that
+	// several options can be flipped at once. Choosing them toggles their
+	// check mark, or checks them in their radio group, right away
+	KeepOpenOnSelect bool
+
+	// Pinned items stay at the top of the dropdown in their own order when
+	// its items are sorted, see SortMode
+	Pinned bool
+}
+
+// NeedsInput returns whether the item asks for input before running its
+// action. The app should then prompt for it with the item's Prompt and run
+// Action followed by a space and the input
+func (item DropdownItem) NeedsInput() bool {
+	return item.Prompt != "" && item.URL == ""
 }
 
+// SortMode is the order the items of a dropdown are shown in
+type SortMode int
+
+const (
+	// SortNone shows the items in the order they were given
+	SortNone SortMode = iota
+	// SortAlpha sorts the items by their shown text, ignoring case
+	SortAlpha
+	// SortCustom sorts the items with the dropdown's Less function
+	SortCustom
+)
+
+// TruncateMode is how the text of items too long for their dropdown is
+// shortened
+type TruncateMode int
+
+const (
+	// TruncateEnd cuts off the end of the text, replaced with an ellipsis
+	TruncateEnd TruncateMode = iota
+	// TruncateMiddle cuts out the middle of the text, replaced with an
+	// ellipsis, keeping the last path element of paths whole if it fits
+	TruncateMiddle
+	// TruncateNone clips the text without an ellipsis
+	TruncateNone
+)
+
+// WidthMode is how the width of a dropdown is chosen
+type WidthMode int
+
+const (
+	// WidthAuto fits the dropdown to its items
+	WidthAuto WidthMode = iota
+	// WidthFixed gives the dropdown the width set with SetFixedWidth
+	WidthFixed
+	// WidthMatchParent widens a submenu to the dropdown it is opened from
+	// and to the widest of its sibling submenus matching the parent too,
+	// so that cascading submenus line up
+	WidthMatchParent
+)
+
 // DropdownMenu represents a dropdown menu that appears below menu items
 type DropdownMenu struct {
 	Items   []DropdownItem
@@ -23,123 +150,1405 @@ type DropdownMenu struct {
 	Y       int
 	Width   int
 	Height  int
-	Active  int  // Currently highlighted item (-1 for none)
+	Active  int  // Item selected with the keyboard, which Enter chooses (-1 for none)
 	Visible bool // Whether the dropdown is currently shown
+
+	// KeyboardFocus is set when the dropdown was opened or navigated with
+	// the keyboard rather than the mouse
+	KeyboardFocus bool
+
+	// HideMnemonics hides the underlined hotkeys and the " (X)" hotkey hints
+	// and hotkey labels
+	HideMnemonics bool
+
+	// AllowHighlightDisabled lets the selection stop on disabled items, so
+	// that their DisabledReason can be shown. They still can't be chosen
+	AllowHighlightDisabled bool
+
+	// RememberSelection highlights the item that was highlighted when the
+	// dropdown was last hidden when it is shown again, if it is still
+	// enabled, instead of the first item
+	RememberSelection bool
+
+	// WrapNavigation makes moving past the last item go to the first one
+	// and vice versa, otherwise the selection stops at the edges
+	WrapNavigation bool
+
+	// ShadowEnabled draws a drop shadow ShadowOffset cells to the right of
+	// and below the dropdown
+	ShadowEnabled bool
+	ShadowOffset  int
+
+	// UseASCIIBorders draws the dropdown with ASCII characters instead of
+	// box-drawing ones, for terminals that can't display those
+	UseASCIIBorders bool
+
+	// AnimateOpen rolls the dropdown down a row at every call to Step after
+	// it is shown, instead of showing it at once
+	AnimateOpen bool
+
+	// Notch opens the top border under the menu on the menu bar the
+	// dropdown was opened from, connecting the two like a tab
+	Notch bool
+
+	// ItemPadding is the number of blank columns on each side of the items
+	// and MinWidth the smallest width of the dropdown, borders included
+	ItemPadding int
+	MinWidth    int
+
+	// MaxWidth is the largest width of the dropdown, borders included,
+	// zero for no limit. Texts that don't fit are shortened as set by
+	// Truncate, leaving room for the hotkey hints and the shortcuts
+	MaxWidth int
+	Truncate TruncateMode
+
+	// WidthMode is how the width of the dropdown is chosen, see
+	// SetFixedWidth. It is still capped by MaxWidth
+	WidthMode WidthMode
+
+	// HoverDelayMs is how long in milliseconds the pointer has to rest on
+	// an item with a submenu before the submenu is opened, see
+	// MenuWindow.HoverDelayMs
+	HoverDelayMs int
+
+	// Translate returns the text shown for an item's text, for localizing
+	// the labels. Actions are never translated. Nil shows texts as they are
+	Translate func(string) string
+
+	// Title is shown centered in the top border, such as the path of a
+	// submenu. It is not translated
+	Title string
+
+	// FormatItem returns the label of an item and the text shown
+	// right-aligned next to it, such as its shortcut. The hotkey is
+	// underlined in the label and anything appended to the item's text is
+	// dimmed. Nil formats the items with DefaultFormatItem
+	FormatItem func(item DropdownItem) (left, right string)
+
+	// MaxColumns is the largest number of columns the items are laid out
+	// in, column by column, when there are more than columnMinRows of them
+	// and they don't fit in a single column on the terminal. Fewer columns
+	// are used if they would be wider than the terminal. Zero or one keeps
+	// a single column
+	MaxColumns int
+
+	// Accordion dropdowns show the items of submenus inline below their
+	// parent item while it is Expanded, pushing the following items down,
+	// instead of opening them to the side. Choosing the parent toggles
+	// Expanded. The items are laid out in a single column, and submenus of
+	// the inline items are not opened
+	Accordion bool
+
+	// SortMode sorts the items when they are set or invalidated. Sorted
+	// dropdowns drop the separators of their items and insert their own
+	// after the pinned items and between the groups
+	SortMode SortMode
+
+	// Less reports whether item a is shown before item b with SortCustom.
+	// Nil sorts as SortAlpha
+	Less func(a, b DropdownItem) bool
+
+	// GroupBy returns the group of an item in a sorted dropdown. The items
+	// of a group are kept together, in the order of the groups' names, and
+	// each group is preceded by a separator labeled with its name
+	GroupBy func(item DropdownItem) string
+
+	// PopulateFunc, if set, is called every time the dropdown is shown and
+	// replaces its items with the ones it returns, for lists that are
+	// costly to build or change often, such as recent files. It runs
+	// synchronously before the dropdown is drawn, so it should be quick
+	PopulateFunc func() []DropdownItem
+
+	// onPopulate is called after PopulateFunc replaced the items, for the
+	// menu bar to configure them and apply its EnabledFuncs
+	onPopulate func()
+
+	columns   int  // number of columns the items are laid out in, set by calculateSize
+	colWidth  int  // width of each column without the borders, set by calculateSize
+	sizeStale bool // whether the items changed since calculateSize, see Invalidate
+	fitRows   int  // item rows a single column has room for on the terminal, set by placement
+	fitWidth  int  // width of the terminal the columns were counted for, set by placement
+
+	fixedWidth int // width of the dropdown in WidthFixed mode, see SetFixedWidth
+	autoWidth  int // width fitting the items, before WidthMode applies
+	matchWidth int // width of the parent and siblings in WidthMatchParent mode
+
+	busy map[string]bool // actions of items that are running an async task, guarded by busyLock
+
+	submenuFocused bool // whether keyboard input goes to the active item's submenu
+
+	scrollOffset int // index of the first row shown when the items don't fit
+	viewRows     int // number of item rows that fit on screen, set by draw, negative until then
+	shownX       int // column the dropdown was last drawn at, set by draw
+	shownY       int // row the dropdown was last drawn at, set by draw
+	minY         int // topmost row the dropdown may be moved up to, below the menu bar
+	flipOffset   int // offset from Y of the row just below the dropdown when it opens upward, see placement
+	anchorX      int // column of the menu the dropdown was opened from, set by Show
+	anchorWidth  int // width of that menu on the bar, 0 if there is none, see Notch
+
+	// covered returns whether a cell belongs to the dropdowns this submenu
+	// was opened from, which its shadow must not be drawn over. It is set
+	// by the parent's draw and nil for a top level dropdown
+	covered func(x, y int) bool
+
+	filter string // typed filter limiting the shown items in long dropdowns
+
+	revealRows int // number of rows shown while the dropdown rolls down, see Step
+
+	lastActive int // item that was highlighted when the dropdown was last hidden, see RememberSelection
+
+	inlineActive menuRow // highlighted row of an accordion dropdown, see activeRow
+
+	hoverActive menuRow // row under the mouse pointer, see HandleHover
+
+	dwell hoverDwell // item with a submenu the pointer rests on, see HoverDelayMs
+}
+
+// menuRow is a row of a dropdown, which shows one of its items or, in an
+// accordion dropdown, an item of the submenu of an expanded item
+type menuRow struct {
+	item  int // index of the item in the dropdown
+	child int // index of the item in the item's submenu, -1 for the item itself
+}
+
+// accordionIndent is the number of columns the inline submenu items of an
+// accordion dropdown are indented by
+const accordionIndent = 2
+
+// filterMinItems is the number of items from which typing in a dropdown
+// filters its items instead of matching hotkeys
+const filterMinItems = 10
+
+// columnMinRows is the number of items a dropdown must exceed before it is
+// split into several columns, and the least number of rows of each column
+const columnMinRows = 10
+
+// dropdownGlyphs are the characters the frame and markers of a dropdown
+// are drawn with
+type dropdownGlyphs struct {
+	topLeft, topRight, bottomLeft, bottomRight rune
+	horizontal, vertical                       rune
+	scrollUp, scrollDown                       rune
+	submenu, expanded, check                   rune
+	radioOn, radioOff                          rune
+}
+
+// unicodeGlyphs draw dropdowns with box-drawing characters
+var unicodeGlyphs = dropdownGlyphs{
+	topLeft: '┌', topRight: '┐', bottomLeft: '└', bottomRight: '┘',
+	horizontal: '─', vertical: '│',
+	scrollUp: '▲', scrollDown: '▼',
+	submenu: '▶', expanded: '▼', check: '✓',
+	radioOn: '●', radioOff: '○',
+}
+
+// asciiGlyphs draw dropdowns on terminals without box-drawing characters
+var asciiGlyphs = dropdownGlyphs{
+	topLeft: '+', topRight: '+', bottomLeft: '+', bottomRight: '+',
+	horizontal: '-', vertical: '|',
+	scrollUp: '^', scrollDown: 'v',
+	submenu: '>', expanded: 'v', check: '*',
+	radioOn: '*', radioOff: 'o',
+}
+
+// glyphs returns the characters the dropdown is drawn with
+func (d *DropdownMenu) glyphs() dropdownGlyphs {
+	if d.UseASCIIBorders {
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
 }
 
+// asciiBordersWanted returns whether dropdowns are drawn with ASCII borders
+// according to the menuborders option. By default ASCII borders are used
+// if the terminal can't display box-drawing characters
+func asciiBordersWanted() bool {
+	switch config.GetGlobalOption("menuborders").(string) {
+	case "ascii":
+		return true
+	case "unicode":
+		return false
+	}
+	return screen.Screen != nil && !screen.Screen.CanDisplay(unicodeGlyphs.topLeft, true)
+}
+
+// spinnerFrames are cycled through on the rows of busy items
+var spinnerFrames = []rune{'|', '/', '-', '\\'}
+
+// spinnerInterval is the time between two spinner frames
+const spinnerInterval = 100 * time.Millisecond
+
+// busyLock guards the busy items of all dropdowns, which async tasks mark
+// while the dropdowns are drawn
+var busyLock sync.Mutex
+
+// spinnerPending is set while a redraw for the next spinner frame is
+// scheduled, so that redraws for other reasons don't schedule more
+var spinnerPending atomic.Bool
+
 // NewDropdownMenu creates a new dropdown menu
 func NewDropdownMenu() *DropdownMenu {
 	return &DropdownMenu{
-		Items:   []DropdownItem{},
-		Active:  -1,
-		Visible: false,
+		Items:          []DropdownItem{},
+		Active:         -1,
+		Visible:        false,
+		WrapNavigation: true,
+		ShadowEnabled:  true,
+		ShadowOffset:   1,
+		ItemPadding:    1,
+		MinWidth:       8,
+		viewRows:       -1,
+		lastActive:     -1,
+		inlineActive:   menuRow{-1, -1},
+		hoverActive:    menuRow{-1, -1},
+		flipOffset:     -1,
 	}
 }
 
 // SetItems sets the items for this dropdown menu
 func (d *DropdownMenu) SetItems(items []DropdownItem) {
 	d.Items = items
+	d.hoverActive = menuRow{-1, -1}
+	d.arrangeItems()
 	d.calculateSize()
 }
 
+// AppendItem adds an item at the end of the dropdown. Unlike SetItems the
+// dropdown isn't resized until Invalidate is called or it is shown or
+// drawn, so that many items can be added at once cheaply
+func (d *DropdownMenu) AppendItem(item DropdownItem) {
+	d.Items = append(d.Items, item)
+	d.sizeStale = true
+}
+
+// UpdateItems replaces the items with the ones returned by mutator, which
+// is passed the current items and may modify them in place. The dropdown is
+// resized later as with AppendItem
+func (d *DropdownMenu) UpdateItems(mutator func([]DropdownItem) []DropdownItem) {
+	d.Items = mutator(d.Items)
+	d.hoverActive = menuRow{-1, -1}
+	d.sizeStale = true
+}
+
+// Invalidate resizes the dropdown to fit its items after they were changed
+// with AppendItem or UpdateItems, sorting them first, see SortMode
+func (d *DropdownMenu) Invalidate() {
+	d.arrangeItems()
+	d.calculateSize()
+}
+
+// ensureSize resizes the dropdown if its items changed since it was last
+// sized, see Invalidate
+func (d *DropdownMenu) ensureSize() {
+	if d.sizeStale {
+		d.Invalidate()
+	}
+}
+
+// arrangeItems sorts and groups the items according to SortMode, keeping
+// the same items selected
+func (d *DropdownMenu) arrangeItems() {
+	if d.SortMode == SortNone {
+		return
+	}
+
+	var pinned, rest []int
+	for i, item := range d.Items {
+		if item.Separator {
+			continue
+		}
+		if item.Pinned {
+			pinned = append(pinned, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+
+	group := func(i int) string {
+		if d.GroupBy == nil {
+			return ""
+		}
+		return d.GroupBy(d.Items[i])
+	}
+	less := d.Less
+	if d.SortMode == SortAlpha || less == nil {
+		less = func(a, b DropdownItem) bool {
+			return strings.ToLower(d.translate(a.Text)) < strings.ToLower(d.translate(b.Text))
+		}
+	}
+	sort.SliceStable(rest, func(a, b int) bool {
+		if ga, gb := group(rest[a]), group(rest[b]); ga != gb {
+			return ga < gb
+		}
+		return less(d.Items[rest[a]], d.Items[rest[b]])
+	})
+
+	items := make([]DropdownItem, 0, len(d.Items))
+	newIndex := make(map[int]int, len(d.Items))
+	for _, i := range pinned {
+		newIndex[i] = len(items)
+		items = append(items, d.Items[i])
+	}
+	for n, i := range rest {
+		g := group(i)
+		if n == 0 && (g != "" || len(items) > 0) || n > 0 && g != group(rest[n-1]) {
+			items = append(items, DropdownItem{Text: g, Separator: true})
+		}
+		newIndex[i] = len(items)
+		items = append(items, d.Items[i])
+	}
+
+	remap := func(i int) int {
+		if j, ok := newIndex[i]; ok {
+			return j
+		}
+		return -1
+	}
+	d.Active = remap(d.Active)
+	d.lastActive = remap(d.lastActive)
+	if d.inlineActive.item = remap(d.inlineActive.item); d.inlineActive.item < 0 {
+		d.inlineActive = menuRow{-1, -1}
+	}
+	d.hoverActive = menuRow{-1, -1}
+	d.Items = items
+}
+
+// shortcutGap is the minimum space between an item's label and its shortcut
+const shortcutGap = 2
+
 // calculateSize determines the width and height needed for the dropdown
 func (d *DropdownMenu) calculateSize() {
+	d.sizeStale = false
 	d.Width = 0
-	d.Height = len(d.Items) + 2 // +2 for top and bottom borders
+	d.columns = 1
+	if d.MaxColumns > 1 && len(d.Items) > columnMinRows && !d.Accordion && (d.fitRows <= 0 || len(d.Items) > d.fitRows) {
+		d.columns = (len(d.Items) + columnMinRows - 1) / columnMinRows
+		if d.columns > d.MaxColumns {
+			d.columns = d.MaxColumns
+		}
+	}
+	rows := len(d.Items)
+	for i, item := range d.Items {
+		if d.isExpanded(i) {
+			rows += len(item.Submenu.Items)
+		}
+	}
 
-	// Find the widest item
+	// Find the widest label and the widest shortcut, shortcuts are
+	// aligned in their own column on the right. Mnemonics are shown while
+	// sizing so that showing them later doesn't cut off the labels
+	hide := d.HideMnemonics
+	d.HideMnemonics = false
+	shortcutWidth := 0
 	for _, item := range d.Items {
+		text := d.translate(item.Text)
 		if item.Separator {
+			// Labeled separators need room for the label and some rule
+			if text != "" {
+				if w := textWidth(text) + 2; w > d.Width {
+					d.Width = w
+				}
+			}
 			continue
 		}
-		itemWidth := util.StringWidth([]byte(item.Text), util.CharacterCountInString(item.Text), 1)
-		if item.Hotkey != 0 {
-			itemWidth += 4 // Space for " (X)" hotkey display
+		label, shortcut := d.formatItem(item)
+		itemWidth := textWidth(label)
+		if item.Submenu != nil {
+			itemWidth += 2 // Space for " ▶" submenu indicator
 		}
 		if itemWidth > d.Width {
 			d.Width = itemWidth
 		}
+		if w := textWidth(shortcut); w > shortcutWidth {
+			shortcutWidth = w
+		}
+	}
+	d.HideMnemonics = hide
+	if shortcutWidth > 0 {
+		d.Width += shortcutGap + shortcutWidth
+	}
+	d.Width += d.markWidth() + d.iconWidth()
+
+	// Add padding and border, every column has its own padding
+	d.colWidth = d.Width + 2*d.ItemPadding
+	for d.columns > 1 && d.fitWidth > 0 && d.columns*d.colWidth+2+d.shadowSize() > d.fitWidth {
+		d.columns--
+	}
+	d.Width = d.columns*d.colWidth + 2          // +2 for borders
+	d.Height = (rows+d.columns-1)/d.columns + 2 // +2 for top and bottom borders
+	if d.Width < d.MinWidth {
+		d.Width = d.MinWidth
+		d.colWidth = d.Width - 2
+	}
+
+	// Leave room for the title, a space on each side of it and the
+	// corners. With several columns the last one extends to the border
+	if w := textWidth(d.Title) + 4; d.Title != "" && w > d.Width {
+		d.Width = w
+		if d.columnCount() == 1 {
+			d.colWidth = d.Width - 2
+		}
+	}
+
+	// Leave room for the indented inline items, whether they are shown or
+	// not so that the width doesn't change as items are expanded
+	if d.Accordion {
+		for _, item := range d.Items {
+			if item.Submenu != nil && accordionIndent+item.Submenu.Width > d.Width {
+				d.Width = accordionIndent + item.Submenu.Width
+				d.colWidth = d.Width - 2
+			}
+		}
+	}
+
+	d.autoWidth = d.Width
+	switch {
+	case d.WidthMode == WidthFixed && d.fixedWidth > 0:
+		d.Width = d.fixedWidth
+		if d.Width < d.MinWidth {
+			d.Width = d.MinWidth
+		}
+		d.colWidth = (d.Width - 2) / d.columnCount()
+	case d.WidthMode == WidthMatchParent && d.matchWidth > d.Width:
+		d.Width = d.matchWidth
+		if d.columnCount() == 1 {
+			d.colWidth = d.Width - 2
+		}
+	}
+
+	if d.MaxWidth > 2 && d.Width > d.MaxWidth {
+		d.Width = d.MaxWidth
+		d.colWidth = (d.Width - 2) / d.columnCount()
+	}
+}
+
+// SetFixedWidth gives the dropdown the given width, borders included,
+// instead of fitting it to its items, such as to give a column of related
+// dropdowns the same width. It is never narrower than MinWidth, and texts
+// that don't fit are shortened as set by Truncate
+func (d *DropdownMenu) SetFixedWidth(width int) {
+	d.WidthMode = WidthFixed
+	d.fixedWidth = width
+	d.sizeStale = true
+}
+
+// submenuMatchWidth returns the width the submenus of the dropdown in
+// WidthMatchParent mode are widened to: the widest of the dropdown and the
+// widths fitting those submenus
+func (d *DropdownMenu) submenuMatchWidth() int {
+	width := d.Width
+	for _, item := range d.Items {
+		if sub := item.Submenu; sub != nil && sub != d && sub.WidthMode == WidthMatchParent {
+			sub.ensureSize()
+			if sub.autoWidth > width {
+				width = sub.autoWidth
+			}
+		}
+	}
+	return width
+}
+
+// isExpanded returns whether the item with the given index shows the items
+// of its submenu inline, see Accordion
+func (d *DropdownMenu) isExpanded(index int) bool {
+	return d.Accordion && index >= 0 && index < len(d.Items) && d.Items[index].Expanded && d.Items[index].Submenu != nil
+}
+
+// columnCount returns the number of columns the items are laid out in
+func (d *DropdownMenu) columnCount() int {
+	if d.columns < 1 {
+		return 1
+	}
+	return d.columns
+}
+
+// gridRows returns the number of rows of the columns the matched items are
+// laid out in
+func (d *DropdownMenu) gridRows() int {
+	cols := d.columnCount()
+	return (len(d.rows()) + cols - 1) / cols
+}
+
+// cellBounds returns the horizontal range of the given column as offsets
+// from the dropdown's left edge, from its first cell up to the first cell
+// past it. The last column extends up to the right border
+func (d *DropdownMenu) cellBounds(col int) (left, right int) {
+	left = 1 + col*d.colWidth // +1 for the left border
+	right = left + d.colWidth
+	if col >= d.columnCount()-1 {
+		right = d.Width - 1
+	}
+	return left, right
+}
+
+// markWidth returns the width of the column reserved for checkmarks, which
+// is only present if the dropdown has checkable or radio items
+func (d *DropdownMenu) markWidth() int {
+	for _, item := range d.Items {
+		if (item.Checkable || item.RadioGroup != "") && !item.Separator {
+			return 2 // Checkmark and a space
+		}
+	}
+	return 0
+}
+
+// iconWidth returns the width of the column reserved for icons, which is
+// only present if the dropdown has items with an icon
+func (d *DropdownMenu) iconWidth() int {
+	width := 0
+	for _, item := range d.Items {
+		if item.Icon != 0 && !item.Separator {
+			if w := runewidth.RuneWidth(item.Icon); w > width {
+				width = w
+			}
+		}
+	}
+	if width == 0 {
+		return 0
 	}
+	return width + 1 // Icon and a space
+}
 
-	// Add padding and border
-	d.Width += 4 // 2 for borders + 2 for padding
-	if d.Width < 8 {
-		d.Width = 8 // Minimum width
+// truncateText shortens s to at most width cells as set by mode, see
+// TruncateMode
+func truncateText(s string, width int, mode TruncateMode) string {
+	if textWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	switch mode {
+	case TruncateNone:
+		return s
+	case TruncateMiddle:
+		// Keep the last path element whole after the ellipsis, or as
+		// much of the end as of the start
+		tailWidth := (width - 1) / 2
+		if i := strings.LastIndexByte(s, '/'); i >= 0 && textWidth(s[i:]) < width {
+			tailWidth = textWidth(s[i:])
+		}
+		head := truncateWidth(s, width-tailWidth)
+		head = strings.TrimSuffix(head, "…")
+		return head + "…" + textTail(s, width-textWidth(head)-1)
+	}
+	return truncateWidth(s, width)
+}
+
+// textTail returns the longest end of s that is at most width cells wide
+func textTail(s string, width int) string {
+	runes := []rune(s)
+	i := len(runes)
+	for i > 0 && textWidth(string(runes[i-1:])) <= width {
+		i--
+	}
+	return string(runes[i:])
+}
+
+// truncateWidth shortens s to at most width cells, replacing the end of
+// the string with an ellipsis if it had to be cut
+func truncateWidth(s string, width int) string {
+	if textWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	w, end := 1, 0 // Room for the ellipsis
+	for end < len(runes) && w+runewidth.RuneWidth(runes[end]) <= width {
+		w += runewidth.RuneWidth(runes[end])
+		end++
+	}
+	return string(runes[:end]) + "…"
+}
+
+// hotkeyIndex returns the rune index of the first character of text that
+// matches hotkey (ignoring case), or -1 if the hotkey does not appear
+func hotkeyIndex(text string, hotkey rune) int {
+	hotkey = unicode.ToLower(hotkey)
+	i := 0
+	for _, r := range text {
+		if unicode.ToLower(r) == hotkey {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// textWidth returns the number of columns drawText draws text in when it
+// fits. Unlike runewidth.StringWidth, which measures some sequences such as
+// emoji joined by zero-width joiners as a single character, every rune is
+// counted, so that the text takes up exactly the space it is laid out in
+func textWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += runewidth.RuneWidth(r)
+	}
+	return width
+}
+
+// drawText draws text from x on row y, stopping before maxX, and returns
+// the position after the last drawn character. Zero-width runes such as
+// combining marks are drawn together with the preceding character, and
+// wide characters that don't fit entirely are left out. The rune at index
+// underline is underlined, -1 underlines nothing
+func drawText(set setContentFunc, x, y, maxX int, text string, style tcell.Style, underline int) int {
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r, index := runes[i], i
+		var comb []rune
+		for i+1 < len(runes) && runewidth.RuneWidth(runes[i+1]) == 0 {
+			comb = append(comb, runes[i+1])
+			i++
+		}
+		width := runewidth.RuneWidth(r)
+		if x+width > maxX {
+			break
+		}
+		charStyle := style
+		if index == underline {
+			charStyle = charStyle.Underline(true)
+		}
+		set(x, y, r, comb, charStyle)
+		x += width
 	}
+	return x
 }
 
 // Show displays the dropdown at the specified position
 func (d *DropdownMenu) Show(x, y int) {
+	if d.PopulateFunc != nil {
+		d.populate()
+	}
+	d.ensureSize()
 	d.X = x
 	d.Y = y
+	d.anchorX = x
 	d.Visible = true
 
-	// Set the first selectable item as active
+	d.shownX, d.shownY = x, y
+	d.viewRows = -1
+	d.scrollOffset = 0
+	if d.AnimateOpen {
+		d.revealRows = 0
+	} else {
+		d.revealRows = d.Height
+	}
+	d.filter = ""
+
+	// Refresh the state of checkable and radio items
+	for i := range d.Items {
+		if item := &d.Items[i]; (item.Checkable || item.RadioGroup != "") && item.StateFunc != nil {
+			item.Checked = item.StateFunc()
+		}
+	}
+
+	// Set the first selectable item as active, or the remembered one
 	d.Active = -1
-	for i := 0; i < len(d.Items); i++ {
-		if d.Items[i].Enabled && !d.Items[i].Separator {
+	d.inlineActive = menuRow{-1, -1}
+	d.hoverActive = menuRow{-1, -1}
+	if d.RememberSelection && d.isSelectable(d.lastActive) && d.Items[d.lastActive].Enabled {
+		d.Active = d.lastActive
+	}
+	for i := 0; i < len(d.Items) && d.Active < 0; i++ {
+		if d.isSelectable(i) {
 			d.Active = i
+		}
+	}
+	if screen.Screen != nil {
+		// Clicks before the dropdown is drawn hit where it will be drawn
+		var height int
+		d.shownX, d.shownY, height = d.placement(screen.Screen.Size())
+		if height >= 2 {
+			d.viewRows = height - 2
+		}
+	}
+	d.scrollToActive()
+	d.syncSubmenu()
+}
+
+// populate replaces the items with the ones returned by PopulateFunc. The
+// item to highlight when RememberSelection is set is looked up again by its
+// action, since the items may have moved
+func (d *DropdownMenu) populate() {
+	last := ""
+	if d.lastActive >= 0 && d.lastActive < len(d.Items) {
+		last = d.Items[d.lastActive].Action
+	}
+	d.Active = -1
+	d.lastActive = -1
+	d.inlineActive = menuRow{-1, -1}
+	d.submenuFocused = false
+	d.SetItems(d.PopulateFunc())
+	for i, item := range d.Items {
+		if last != "" && !item.Separator && item.Action == last {
+			d.lastActive = i
 			break
 		}
 	}
+	if d.onPopulate != nil {
+		d.onPopulate()
+	}
+}
+
+// matchedItems returns the indices of the items shown in the dropdown's
+// rows, which are the items matching the filter if one has been typed
+func (d *DropdownMenu) matchedItems() []int {
+	rows := make([]int, 0, len(d.Items))
+	filter := strings.ToLower(d.filter)
+	for i, item := range d.Items {
+		if filter != "" && (item.Separator || !strings.Contains(strings.ToLower(d.translate(item.Text)), filter)) {
+			continue
+		}
+		rows = append(rows, i)
+	}
+	return rows
+}
+
+// rows returns the rows of the dropdown, which are the matched items
+// followed by the inline items of the expanded ones
+func (d *DropdownMenu) rows() []menuRow {
+	matched := d.matchedItems()
+	rows := make([]menuRow, 0, len(matched))
+	for _, i := range matched {
+		rows = append(rows, menuRow{i, -1})
+		if d.isExpanded(i) {
+			for c := range d.Items[i].Submenu.Items {
+				rows = append(rows, menuRow{i, c})
+			}
+		}
+	}
+	return rows
+}
+
+// rowIndex returns the position of the given row among the rows, or -1 if
+// it isn't shown
+func (d *DropdownMenu) rowIndex(r menuRow) int {
+	for pos, row := range d.rows() {
+		if row == r {
+			return pos
+		}
+	}
+	return -1
+}
+
+// activeRow returns the highlighted row, which in an accordion dropdown may
+// be an inline item of the active item
+func (d *DropdownMenu) activeRow() menuRow {
+	if r := d.inlineActive; r.item == d.Active && r.child >= 0 && d.isExpanded(r.item) && r.child < len(d.Items[r.item].Submenu.Items) {
+		return r
+	}
+	return menuRow{d.Active, -1}
+}
+
+// rowItem returns the item shown in the given row
+func (d *DropdownMenu) rowItem(r menuRow) *DropdownItem {
+	if r.child >= 0 {
+		return &d.Items[r.item].Submenu.Items[r.child]
+	}
+	return &d.Items[r.item]
+}
+
+// rowSelectable returns whether the given row can be highlighted, as for
+// isSelectable
+func (d *DropdownMenu) rowSelectable(r menuRow) bool {
+	if r.child < 0 {
+		return d.isSelectable(r.item)
+	}
+	item := d.rowItem(r)
+	return !item.Separator && item.Submenu == nil && (item.Enabled || d.AllowHighlightDisabled)
+}
+
+// rowAt returns the row drawn at the given offset from the top of an
+// accordion dropdown, taking scrolling into account
+func (d *DropdownMenu) rowAt(dy int) (menuRow, bool) {
+	return d.rowToItem(dy-1, 0) // -1 for top border
+}
+
+// rowToItem returns the row drawn in the given row and column of the
+// visible part of the dropdown, taking scrolling, filtering and the inline
+// items of accordions into account. Drawing and hit-testing both go through
+// it, so that clicks always land on the item drawn under the pointer
+func (d *DropdownMenu) rowToItem(row, col int) (menuRow, bool) {
+	if row < 0 || row >= d.visibleRows() || col < 0 || col >= d.columnCount() {
+		return menuRow{}, false
+	}
+	gridRows := d.gridRows()
+	row += d.scrollOffset
+	pos := col*gridRows + row
+	rows := d.rows()
+	if row >= gridRows || pos >= len(rows) {
+		return menuRow{}, false
+	}
+	return rows[pos], true
+}
+
+// itemRow returns the position of the item with the given index among the
+// matched items, or -1 if it isn't shown
+func (d *DropdownMenu) itemRow(index int) int {
+	for row, i := range d.matchedItems() {
+		if i == index {
+			return row
+		}
+	}
+	return -1
+}
+
+// itemCell returns the row and column the item with the given index is laid
+// out in, or -1, -1 if it isn't shown. Items fill the columns one by one
+func (d *DropdownMenu) itemCell(index int) (row, col int) {
+	pos := d.itemRow(index)
+	if pos < 0 {
+		return -1, -1
+	}
+	rows := d.gridRows()
+	return pos % rows, pos / rows
+}
+
+// cellItem returns the index of the item shown in the given row and column,
+// taking scrolling into account, or -1 if there is none
+func (d *DropdownMenu) cellItem(row, col int) int {
+	r, ok := d.rowToItem(row, col)
+	if !ok || r.child >= 0 {
+		return -1
+	}
+	return r.item
+}
+
+// itemAt returns the index of the item drawn at the given offset from the
+// dropdown's top left corner, or -1 if there is none
+func (d *DropdownMenu) itemAt(dx, dy int) int {
+	col := 0
+	if d.columnCount() > 1 && d.colWidth > 0 {
+		col = (dx - 1) / d.colWidth // -1 for left border
+		if col >= d.columnCount() {
+			col = d.columnCount() - 1
+		}
+	}
+	return d.cellItem(dy-1, col) // -1 for top border
+}
+
+// submenuX returns the offset from the dropdown's left edge at which the
+// submenu of an item in the given column is opened
+func (d *DropdownMenu) submenuX(col int) int {
+	if col >= d.columnCount()-1 {
+		return d.Width
+	}
+	_, right := d.cellBounds(col)
+	return right
+}
+
+// Filterable returns whether typing in the dropdown filters its items
+func (d *DropdownMenu) Filterable() bool {
+	return len(d.Items) >= filterMinItems
+}
+
+// Filter returns the filter typed into the dropdown
+func (d *DropdownMenu) Filter() string {
+	return d.filter
+}
+
+// SetFilter limits the shown items to the ones whose text contains filter,
+// ignoring case. The first matching item becomes active if the active item
+// is filtered out
+func (d *DropdownMenu) SetFilter(filter string) {
+	d.filter = filter
+	d.scrollOffset = 0
+	d.inlineActive = menuRow{-1, -1}
+	if d.itemRow(d.Active) < 0 || !d.isSelectable(d.Active) {
+		d.Active = -1
+		d.MoveDown()
+	}
+	d.scrollToActive()
+	d.syncSubmenu()
+}
+
+// isSelectable returns whether the item with the given index can be
+// highlighted, which disabled items only can with AllowHighlightDisabled.
+// Disabled items are never activated
+func (d *DropdownMenu) isSelectable(index int) bool {
+	if index < 0 || index >= len(d.Items) || d.Items[index].Separator {
+		return false
+	}
+	return d.Items[index].Enabled || d.AllowHighlightDisabled
+}
+
+// visibleRows returns the number of item rows that are shown at once
+func (d *DropdownMenu) visibleRows() int {
+	n := d.gridRows()
+	if d.viewRows < 0 || d.viewRows > n {
+		return n
+	}
+	return d.viewRows
+}
+
+// shownHeight returns the height of the dropdown as it is drawn, which is
+// less than Height if the items had to be scrolled
+func (d *DropdownMenu) shownHeight() int {
+	return d.visibleRows() + 2 // +2 for top and bottom borders
+}
+
+// scrollToActive adjusts the scroll offset so that the active item is shown
+func (d *DropdownMenu) scrollToActive() {
+	rows := d.visibleRows()
+	if pos := d.rowIndex(d.activeRow()); pos >= 0 && rows > 0 {
+		row := pos % d.gridRows()
+		if row < d.scrollOffset {
+			d.scrollOffset = row
+		} else if row >= d.scrollOffset+rows {
+			d.scrollOffset = row - rows + 1
+		}
+	}
+	d.clampScroll()
+}
+
+// clampScroll keeps the scroll offset within the range of items
+func (d *DropdownMenu) clampScroll() {
+	if max := d.gridRows() - d.visibleRows(); d.scrollOffset > max {
+		d.scrollOffset = max
+	}
+	if d.scrollOffset < 0 {
+		d.scrollOffset = 0
+	}
+}
+
+// ScrollBy scrolls the items by the given number of lines, without
+// changing the active item
+func (d *DropdownMenu) ScrollBy(lines int) {
+	d.scrollOffset += lines
+	d.clampScroll()
+}
+
+// ActiveSubmenu returns the submenu of the active item if it is shown
+func (d *DropdownMenu) ActiveSubmenu() *DropdownMenu {
+	if item := d.GetActiveItem(); item != nil && item.Submenu != nil && item.Submenu.Visible {
+		return item.Submenu
+	}
+	return nil
+}
+
+// syncSubmenu shows the submenu of the active item, next to its row, and
+// hides the submenus of all other items
+func (d *DropdownMenu) syncSubmenu() {
+	for i := range d.Items {
+		if sub := d.Items[i].Submenu; sub != nil && (i != d.Active || d.Accordion) && sub.Visible {
+			sub.Hide()
+		}
+	}
+	if d.Accordion {
+		// Submenus are shown inline instead
+		d.submenuFocused = false
+		return
+	}
+	if item := d.GetActiveItem(); item != nil && item.Submenu != nil && item.Enabled {
+		if !item.Submenu.Visible {
+			d.submenuFocused = false
+			// Align the first submenu item with the parent item's row,
+			// Display flips it to the left if there is no room on the right
+			row, col := d.itemCell(d.Active)
+			item.Submenu.minY = d.minY
+			item.Submenu.flipOffset = 3 // The last item lines up with the parent item
+			item.Submenu.AllowHighlightDisabled = d.AllowHighlightDisabled
+			item.Submenu.RememberSelection = d.RememberSelection
+			if sub := item.Submenu; sub.WidthMode == WidthMatchParent {
+				if width := d.submenuMatchWidth(); width != sub.matchWidth {
+					sub.matchWidth = width
+					sub.sizeStale = true
+				}
+			}
+			item.Submenu.Show(d.shownX+d.submenuX(col), d.shownY+row-d.scrollOffset)
+		}
+	} else {
+		d.submenuFocused = false
+	}
+}
+
+// ExpandSubmenu moves keyboard focus into the submenu of the active item,
+// or expands the active item of an accordion dropdown. It returns false if
+// the active item has no submenu
+func (d *DropdownMenu) ExpandSubmenu() bool {
+	if d.Accordion {
+		return d.activeRow().child < 0 && d.setExpanded(true)
+	}
+	d.syncSubmenu()
+	if sub := d.ActiveSubmenu(); sub != nil {
+		d.submenuFocused = true
+		return true
+	}
+	return false
+}
+
+// CollapseSubmenu moves keyboard focus from the deepest focused submenu
+// back to its parent, or collapses the active item of an accordion dropdown
+// and highlights it. It returns false if no submenu had focus
+func (d *DropdownMenu) CollapseSubmenu() bool {
+	if d.Accordion {
+		if d.activeRow().child < 0 && !d.isExpanded(d.Active) {
+			return false
+		}
+		d.inlineActive = menuRow{-1, -1}
+		return d.setExpanded(false)
+	}
+	if !d.submenuFocused {
+		return false
+	}
+	if sub := d.ActiveSubmenu(); sub != nil && sub.CollapseSubmenu() {
+		return true
+	}
+	d.submenuFocused = false
+	return true
+}
+
+// ToggleExpanded expands the active item of an accordion dropdown if it is
+// collapsed and collapses it otherwise. It returns false if the dropdown
+// isn't an accordion or the highlighted row isn't an item with a submenu
+func (d *DropdownMenu) ToggleExpanded() bool {
+	if !d.Accordion || d.activeRow().child >= 0 || d.Active < 0 || d.Active >= len(d.Items) {
+		return false
+	}
+	return d.setExpanded(!d.Items[d.Active].Expanded)
+}
+
+// setExpanded expands or collapses the active item of an accordion
+// dropdown. It returns false if the item is disabled or has no submenu
+func (d *DropdownMenu) setExpanded(expanded bool) bool {
+	item := d.GetActiveItem()
+	if item == nil || item.Submenu == nil || !item.Enabled {
+		return false
+	}
+	revealed := !d.Revealing()
+	item.Expanded = expanded
+	d.calculateSize()
+	if revealed {
+		d.revealRows = d.Height
+	}
+	d.scrollToActive()
+	return true
+}
+
+// selectAction highlights the item with the given action and returns it,
+// see MenuWindow.SelectDropdownItem
+func (d *DropdownMenu) selectAction(action string) *DropdownItem {
+	for i := range d.Items {
+		item := &d.Items[i]
+		if !d.isSelectable(i) || d.itemRow(i) < 0 {
+			continue
+		}
+		switch {
+		case item.Submenu == nil:
+			if item.Action != action {
+				continue
+			}
+			d.Active = i
+			d.inlineActive = menuRow{-1, -1}
+			d.submenuFocused = false
+			d.syncSubmenu()
+			d.scrollToActive()
+			return item
+		case d.Accordion:
+			for c := range item.Submenu.Items {
+				r := menuRow{i, c}
+				if d.rowItem(r).Action != action || !d.rowSelectable(r) {
+					continue
+				}
+				d.Active = i
+				if !item.Expanded {
+					d.setExpanded(true)
+				}
+				d.inlineActive = r
+				d.scrollToActive()
+				return d.rowItem(r)
+			}
+		case hasAction(item.Submenu, action):
+			d.Active = i
+			d.scrollToActive()
+			if !d.ExpandSubmenu() {
+				continue
+			}
+			if found := item.Submenu.selectAction(action); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// hasAction returns whether the dropdown or one of its submenus has an item
+// with the given action
+func hasAction(d *DropdownMenu, action string) bool {
+	for _, item := range d.Items {
+		if item.Separator {
+			continue
+		}
+		if item.Action == action || (item.Submenu != nil && hasAction(item.Submenu, action)) {
+			return true
+		}
+	}
+	return false
 }
 
-// Hide hides the dropdown
+// FocusedMenu returns the dropdown that currently receives keyboard input,
+// which is the deepest submenu that has been expanded with ExpandSubmenu
+func (d *DropdownMenu) FocusedMenu() *DropdownMenu {
+	if d.submenuFocused {
+		if sub := d.ActiveSubmenu(); sub != nil {
+			return sub.FocusedMenu()
+		}
+	}
+	return d
+}
+
+// SetBusy marks the item with the given action as running (or no longer
+// running) an async task, which shows a spinner on its row
+func (d *DropdownMenu) SetBusy(action string, busy bool) {
+	busyLock.Lock()
+	defer busyLock.Unlock()
+	if busy {
+		if d.busy == nil {
+			d.busy = make(map[string]bool)
+		}
+		d.busy[action] = true
+	} else {
+		delete(d.busy, action)
+	}
+}
+
+// isBusy returns whether the item with the given action is running an
+// async task, see SetBusy
+func (d *DropdownMenu) isBusy(action string) bool {
+	busyLock.Lock()
+	defer busyLock.Unlock()
+	return d.busy[action]
+}
+
+// Hide hides the dropdown along with any open submenu
 func (d *DropdownMenu) Hide() {
+	for i := range d.Items {
+		if sub := d.Items[i].Submenu; sub != nil && sub.Visible {
+			sub.Hide()
+		}
+	}
+	if d.Visible {
+		d.lastActive = d.Active
+	}
 	d.Visible = false
 	d.Active = -1
+	d.hoverActive = menuRow{-1, -1}
+	d.submenuFocused = false
+	d.filter = ""
+}
+
+// Step reveals one more row of a dropdown that is rolling down, and
+// returns whether rows are left to reveal
+func (d *DropdownMenu) Step() bool {
+	if d.revealRows < d.Height {
+		d.revealRows++
+	}
+	return d.Revealing()
+}
+
+// Revealing returns whether the dropdown is still rolling down, during
+// which it can't be navigated
+func (d *DropdownMenu) Revealing() bool {
+	return d.Visible && d.revealRows < d.Height
+}
+
+// IsVisible returns whether the dropdown is currently visible
+func (d *DropdownMenu) IsVisible() bool {
+	return d.Visible
+}
+
+// placement returns the on-screen position and height of the dropdown on a
+// terminal of the given size. The dropdown is moved left if it would go off
+// the right edge. If its items don't fit below the anchor it opens upward
+// instead, ending on the row flipOffset rows from the anchor, if there is
+// more room there than below. Otherwise it is shortened and scrolls,
+// unless there isn't even room for a single item in which case it is moved
+// up, but never above minY. If it still doesn't fit it is cut off at the
+// bottom of the terminal
+func (d *DropdownMenu) placement(termWidth, termHeight int) (x, y, height int) {
+	// Leave room for the shadow so that it stays on screen
+	shadow := d.shadowSize()
+
+	// The columns depend on the size of the terminal, see MaxColumns
+	if d.MaxColumns > 1 {
+		fit := termHeight - d.minY - shadow - 2
+		if fit < 1 {
+			fit = 1
+		}
+		if fit != d.fitRows || termWidth != d.fitWidth {
+			// A dropdown that was shown whole stays whole as it is resized
+			revealed := d.revealRows >= d.Height
+			d.fitRows, d.fitWidth = fit, termWidth
+			d.calculateSize()
+			if revealed {
+				d.revealRows = d.Height
+			}
+		}
+	}
+
+	x, y = d.X, d.Y
+	if x+d.Width+shadow > termWidth {
+		x = termWidth - d.Width - shadow
+		if x < 0 {
+			x = 0
+		}
+	}
+
+	height = d.gridRows() + 2 // +2 for top and bottom borders
+	if below := termHeight - y - shadow; height > below {
+		// The upward dropdown scrolls too if it still doesn't fit
+		upBottom := y + d.flipOffset
+		if above := upBottom - d.minY; above > below && above >= 3 {
+			if height > above {
+				height = above
+			}
+			return x, upBottom - height, height
+		}
+	}
+	if y+height+shadow > termHeight && termHeight-y-shadow < 3 {
+		y = termHeight - height - shadow
+		if y < d.minY {
+			y = d.minY
+		}
+		if y < 0 {
+			y = 0
+		}
+	}
+	if y+height+shadow > termHeight {
+		height = termHeight - y - shadow
+		if height < 3 {
+			// The content takes precedence over the shadow
+			height = termHeight - y
+		}
+	}
+	return x, y, height
+}
+
+// shadowSize returns how far the shadow extends past the dropdown
+func (d *DropdownMenu) shadowSize() int {
+	if !d.ShadowEnabled || d.ShadowOffset < 0 {
+		return 0
+	}
+	return d.ShadowOffset
+}
+
+// Bounds returns the rectangle the dropdown occupies on screen, which is
+// empty if the dropdown is hidden or the screen isn't initialized yet. Its
+// submenus are not included
+func (d *DropdownMenu) Bounds() (x, y, w, h int) {
+	d.ensureSize()
+	if !d.Visible || d.Height == 0 || screen.Screen == nil {
+		return 0, 0, 0, 0
+	}
+	termWidth, termHeight := screen.Screen.Size()
+	x, y, h = d.placement(termWidth, termHeight)
+	if h < 2 {
+		return 0, 0, 0, 0
+	}
+	return x, y, d.Width, h
 }
 
-// IsVisible returns whether the dropdown is currently visible
-func (d *DropdownMenu) IsVisible() bool {
-	return d.Visible
+// regions returns the rectangles of the dropdown and its visible submenus
+func (d *DropdownMenu) regions() []image.Rectangle {
+	x, y, w, h := d.Bounds()
+	if w == 0 {
+		return nil
+	}
+	regions := []image.Rectangle{image.Rect(x, y, x+w, y+h)}
+	for _, item := range d.Items {
+		if item.Submenu != nil && item.Submenu.Visible {
+			regions = append(regions, item.Submenu.regions()...)
+		}
+	}
+	return regions
 }
 
-// Display renders the dropdown menu
+// Display renders the dropdown menu. It does nothing before the screen is
+// initialized
 func (d *DropdownMenu) Display() {
-	if !d.Visible || d.Height == 0 {
+	if screen.Screen == nil {
 		return
 	}
 
 	// Get terminal size to ensure we don't draw outside bounds
 	termWidth, termHeight := screen.Screen.Size()
 
-	// Adjust position if dropdown would go off screen
-	adjustedX := d.X
-	adjustedY := d.Y
+	// Keep redrawing while a spinner is shown so that it animates
+	if d.draw(safeSetContent, termWidth, termHeight) && spinnerPending.CompareAndSwap(false, true) {
+		time.AfterFunc(spinnerInterval, func() {
+			spinnerPending.Store(false)
+			screen.Redraw()
+		})
+	}
+}
+
+// RenderToCells draws the dropdown and its open submenu into a grid of the
+// given size instead of the screen
+func (d *DropdownMenu) RenderToCells(width, height int) [][]Cell {
+	cells, set := newCellGrid(width, height)
+	d.draw(set, width, height)
+	return cells
+}
 
-	if adjustedX+d.Width > termWidth {
-		adjustedX = termWidth - d.Width
-		if adjustedX < 0 {
-			adjustedX = 0
-		}
+// draw renders the dropdown with set on a terminal of the given size, and
+// returns whether a busy spinner was drawn
+func (d *DropdownMenu) draw(set setContentFunc, termWidth, termHeight int) bool {
+	d.ensureSize()
+	if !d.Visible || d.Height == 0 {
+		return false
+	}
+
+	adjustedX, adjustedY, height := d.placement(termWidth, termHeight)
+	if height < 2 {
+		return false
 	}
+	d.shownX, d.shownY = adjustedX, adjustedY
+	d.viewRows = height - 2
+	d.clampScroll()
 
-	if adjustedY+d.Height > termHeight {
-		adjustedY = termHeight - d.Height
-		if adjustedY < 0 {
-			adjustedY = 0
+	// While rolling down, the dropdown is cut off below the revealed rows
+	if d.revealRows < height {
+		height = d.revealRows
+		if height < 2 {
+			return false
 		}
 	}
 
 	// Draw dropdown background and border with proper backdrop
 	// Use normal style for dropdown, reverse for highlighting
-	dropdownStyle := config.DefStyle
-	borderStyle := config.DefStyle
-	shadowStyle := config.DefStyle.Dim(true) // For drop shadow effect
+	glyphs := d.glyphs()
+	theme := CurrentMenuTheme()
+	dropdownStyle := theme.Dropdown
+	borderStyle := theme.Border
+	shadowStyle := theme.Shadow
 
-	// Draw shadow effect first (offset by 1 pixel)
-	for row := 1; row <= d.Height; row++ {
-		for col := 1; col <= d.Width; col++ {
-			x := adjustedX + col
-			y := adjustedY + row
-			if x < termWidth && y < termHeight {
-				screen.SetContent(x, y, ' ', nil, shadowStyle)
+	// Show whether keystrokes go to the dropdown with a focus ring
+	if config.GetGlobalOption("menufocusring").(bool) {
+		if d.KeyboardFocus {
+			borderStyle = theme.FocusedBorder
+		} else {
+			borderStyle = borderStyle.Dim(true)
+		}
+	}
+
+	// Draw shadow effect first, the dropdown is drawn over the part
+	// covered by it
+	if shadow := d.shadowSize(); shadow > 0 {
+		for row := shadow; row < height+shadow; row++ {
+			for col := shadow; col < d.Width+shadow; col++ {
+				x := adjustedX + col
+				y := adjustedY + row
+				if d.covered == nil || !d.covered(x, y) {
+					set(x, y, ' ', nil, shadowStyle)
+				}
 			}
 		}
 	}
 
-	for row := 0; row < d.Height; row++ {
+	for row := 0; row < height; row++ {
 		y := adjustedY + row
 		if y >= termHeight {
 			break
@@ -155,89 +1564,296 @@ func (d *DropdownMenu) Display() {
 			if col == 0 || col == d.Width-1 {
 				if row == 0 {
 					if col == 0 {
-						screen.SetContent(x, y, '┌', nil, borderStyle)
+						set(x, y, glyphs.topLeft, nil, borderStyle)
 					} else {
-						screen.SetContent(x, y, '┐', nil, borderStyle)
+						set(x, y, glyphs.topRight, nil, borderStyle)
 					}
-				} else if row == d.Height-1 {
+				} else if row == height-1 {
 					if col == 0 {
-						screen.SetContent(x, y, '└', nil, borderStyle)
+						set(x, y, glyphs.bottomLeft, nil, borderStyle)
 					} else {
-						screen.SetContent(x, y, '┘', nil, borderStyle)
+						set(x, y, glyphs.bottomRight, nil, borderStyle)
 					}
 				} else {
-					screen.SetContent(x, y, '│', nil, borderStyle)
+					set(x, y, glyphs.vertical, nil, borderStyle)
 				}
-			} else if row == 0 || row == d.Height-1 {
-				screen.SetContent(x, y, '─', nil, borderStyle)
+			} else if row == 0 || row == height-1 {
+				set(x, y, glyphs.horizontal, nil, borderStyle)
 			} else {
-				screen.SetContent(x, y, ' ', nil, dropdownStyle)
+				set(x, y, ' ', nil, dropdownStyle)
 			}
 		}
 	}
 
-	// Draw menu items
-	itemY := 0
-	for i, item := range d.Items {
-		if itemY >= d.Height-2 { // Account for top and bottom borders
-			break
-		}
+	// Draw the title in the top border
+	if d.Title != "" {
+		label := " " + truncateWidth(d.Title, d.Width-4) + " "
+		x := adjustedX + (d.Width-textWidth(label))/2
+		drawText(set, x, adjustedY, adjustedX+d.Width-1, label, borderStyle, -1)
+	}
 
-		y := adjustedY + 1 + itemY // +1 for top border
-		if y >= termHeight {
+	// Open the top border under the menu the dropdown was opened from,
+	// unless it was moved up to fit
+	if d.Notch && d.anchorWidth > 0 && adjustedY == d.Y {
+		d.drawNotch(set, glyphs, adjustedX, adjustedY, dropdownStyle, borderStyle)
+	}
+
+	spinner := spinnerFrames[(time.Now().UnixNano()/int64(spinnerInterval))%int64(len(spinnerFrames))]
+	spinning := false
+
+	// Draw the scroll indicators in the borders
+	ax := adjustedX + d.Width - 2
+	if d.scrollOffset > 0 {
+		set(ax, adjustedY, glyphs.scrollUp, nil, borderStyle)
+	}
+	if d.scrollOffset+d.viewRows < d.gridRows() {
+		set(ax, adjustedY+height-1, glyphs.scrollDown, nil, borderStyle)
+	}
+
+	// Draw the typed filter in the bottom border
+	if d.filter != "" {
+		drawText(set, adjustedX+1, adjustedY+height-1, adjustedX+d.Width-1, truncateWidth(d.filter, d.Width-4), borderStyle.Bold(true), -1)
+	}
+
+	// Draw menu items, filling the columns one by one
+	gridRows := d.gridRows()
+	active, hover := d.activeRow(), d.hoverActive
+	for row := 0; row < height-2; row++ { // Account for top and bottom borders
+		y := adjustedY + 1 + row // +1 for top border
+		if y >= termHeight || d.scrollOffset+row >= gridRows {
 			break
 		}
 
-		if item.Separator {
-			// Draw separator line
-			for x := adjustedX + 1; x < adjustedX+d.Width-1; x++ {
-				if x < termWidth {
-					screen.SetContent(x, y, '─', nil, borderStyle)
-				}
+		for col := 0; col < d.columnCount(); col++ {
+			r, ok := d.rowToItem(row, col)
+			if !ok {
+				break
 			}
-		} else {
-			// Draw menu item
-			itemStyle := dropdownStyle
-			if i == d.Active {
-				// Highlight active item
-				itemStyle = itemStyle.Reverse(true)
+			left, right := d.cellBounds(col)
+			left += adjustedX
+			right += adjustedX
+
+			// Inline items of expanded items are drawn indented by their
+			// submenu, with the frame of this dropdown
+			menu, i := d, r.item
+			if r.child >= 0 {
+				for x := left; x < left+accordionIndent; x++ {
+					set(x, y, ' ', nil, dropdownStyle)
+				}
+				menu, i = d.Items[r.item].Submenu, r.child
+				left += accordionIndent
 			}
-			if !item.Enabled {
-				// Dim disabled items
-				itemStyle = itemStyle.Dim(true)
+			if menu.drawItem(set, theme, glyphs, i, r == active, r == hover, left, right, y, spinner) {
+				spinning = true
 			}
+		}
+	}
 
-			// Clear the line first
-			for x := adjustedX + 1; x < adjustedX+d.Width-1; x++ {
-				if x < termWidth {
-					screen.SetContent(x, y, ' ', nil, itemStyle)
-				}
+	// Draw the open submenu on top, to the right of the parent unless
+	// there is no room there, in which case it is flipped to the left
+	if sub := d.ActiveSubmenu(); sub != nil {
+		row, col := d.itemCell(d.Active)
+		sub.X = adjustedX + d.submenuX(col)
+		if sub.X+sub.Width > termWidth && adjustedX-sub.Width >= 0 {
+			sub.X = adjustedX - sub.Width
+		}
+		sub.Y = adjustedY + row - d.scrollOffset
+		sub.UseASCIIBorders = d.UseASCIIBorders
+		sub.covered = func(x, y int) bool {
+			return d.Contains(x, y) || d.covered != nil && d.covered(x, y)
+		}
+		if sub.draw(set, termWidth, termHeight) {
+			spinning = true
+		}
+	}
+	return spinning
+}
+
+// drawItem draws the item with the given index, highlighted if it is
+// selected with the keyboard or under the mouse pointer, in the given row
+// between left and right. It returns whether a busy spinner was drawn
+func (d *DropdownMenu) drawItem(set setContentFunc, theme MenuTheme, glyphs dropdownGlyphs, i int, active, hover bool, left, right, y int, spinner rune) bool {
+	item := d.Items[i]
+	if item.Separator {
+		// Draw separator line
+		for x := left; x < right; x++ {
+			set(x, y, glyphs.horizontal, nil, theme.Separator)
+		}
+
+		// Center the label of labeled separators on the line
+		if text := d.translate(item.Text); text != "" {
+			label := " " + truncateWidth(text, right-left-4) + " "
+			x := left + (right-left-textWidth(label))/2
+			drawText(set, x, y, right, label, theme.Separator, -1)
+		}
+	} else {
+		// Draw menu item
+		itemStyle := d.resolvedStyle(theme, i, active, hover)
+
+		// Clear the line first
+		for x := left; x < right; x++ {
+			set(x, y, ' ', nil, itemStyle)
+		}
+
+		// Split the label into the item's text, with the hotkey underlined
+		// if it appears in it, and what was appended to it such as the
+		// hotkey hint, which is dimmed
+		label, shortcut := d.formatItem(item)
+		text, extra := label, ""
+		if t := d.translate(item.Text); strings.HasPrefix(label, t) {
+			text, extra = t, label[len(t):]
+		}
+		hkIndex := -1
+		if item.Hotkey != 0 && !d.HideMnemonics {
+			hkIndex = hotkeyIndex(text, item.Hotkey)
+		}
+		x := left + d.ItemPadding
+		if item.RadioGroup != "" {
+			mark := glyphs.radioOff
+			if item.Checked {
+				mark = glyphs.radioOn
 			}
+			set(x, y, mark, nil, itemStyle)
+		} else if item.Checkable && item.Checked {
+			set(x, y, glyphs.check, nil, itemStyle)
+		}
+		x += d.markWidth()
+		if item.Icon != 0 {
+			set(x, y, item.Icon, nil, itemStyle)
+		}
+		x += d.iconWidth()
+		end := right - d.ItemPadding
 
-			// Draw item text
-			x := adjustedX + 2 // +2 for border and padding
-			for _, r := range item.Text {
-				if x >= adjustedX+d.Width-2 || x >= termWidth {
-					break
-				}
-				screen.SetContent(x, y, r, nil, itemStyle)
-				x += runewidth.RuneWidth(r)
+		// Shorten the text to leave room for what was appended to it, the
+		// submenu indicator and the shortcut
+		labelEnd := end
+		if item.Submenu != nil {
+			labelEnd -= 2
+		}
+		if shortcut != "" {
+			labelEnd -= textWidth(shortcut) + shortcutGap
+		}
+		if avail := labelEnd - x; textWidth(text)+textWidth(extra) > avail {
+			if avail-textWidth(extra) < 1 {
+				extra = ""
+			}
+			text = truncateText(text, avail-textWidth(extra), d.Truncate)
+			if hkIndex >= 0 {
+				hkIndex = hotkeyIndex(text, item.Hotkey)
 			}
+		}
+		x = drawText(set, x, y, labelEnd, text, itemStyle, hkIndex)
 
-			// Draw hotkey if present
-			if item.Hotkey != 0 && x < adjustedX+d.Width-4 {
-				hotkeyText := " (" + string(item.Hotkey) + ")"
-				for _, r := range hotkeyText {
-					if x >= adjustedX+d.Width-2 || x >= termWidth {
-						break
-					}
-					screen.SetContent(x, y, r, nil, itemStyle.Dim(true))
-					x += runewidth.RuneWidth(r)
-				}
+		// Draw the submenu indicator at the end of the item, pointing
+		// down while its items are shown inline
+		if d.isExpanded(i) {
+			set(end-1, y, glyphs.expanded, nil, itemStyle)
+		} else if item.Submenu != nil {
+			set(end-1, y, glyphs.submenu, nil, itemStyle)
+		}
+
+		if x < labelEnd {
+			x = drawText(set, x, y, labelEnd, extra, itemStyle.Dim(true), -1)
+		}
+
+		// Draw the shortcut right-aligned, truncating it rather than
+		// letting it run into the label
+		if shortcut != "" {
+			if item.Submenu != nil {
+				end -= 2
 			}
+			shortcut = truncateWidth(shortcut, end-x-1)
+			sx := end - textWidth(shortcut)
+			drawText(set, sx, y, end, shortcut, itemStyle.Dim(true), -1)
+		}
+
+		// Draw the spinner in the right padding column so that it
+		// doesn't move the text
+		if d.isBusy(item.Action) {
+			set(right-1, y, spinner, nil, itemStyle)
+			return true
+		}
+	}
+	return false
+}
+
+// drawNotch opens the top border of the dropdown drawn at x, y below its
+// anchor menu and joins the border to the sides of the opening, which
+// reaches out to the corners when it comes within a column of them
+func (d *DropdownMenu) drawNotch(set setContentFunc, glyphs dropdownGlyphs, x, y int, style, borderStyle tcell.Style) {
+	left, right := d.anchorX-x, d.anchorX+d.anchorWidth-x
+	if left <= 1 {
+		left = 0
+	}
+	if right >= d.Width-1 {
+		right = d.Width
+	}
+	if left >= right {
+		return
+	}
+
+	for col := left; col < right; col++ {
+		set(x+col, y, ' ', nil, style)
+	}
+	if left == 0 {
+		set(x, y, glyphs.vertical, nil, borderStyle)
+	} else {
+		set(x+left-1, y, glyphs.bottomRight, nil, borderStyle)
+	}
+	if right == d.Width {
+		set(x+d.Width-1, y, glyphs.vertical, nil, borderStyle)
+	} else {
+		set(x+right, y, glyphs.bottomLeft, nil, borderStyle)
+	}
+}
+
+// ResolvedStyle returns the style the item at index is drawn with. The
+// style is built up in order of precedence: the dropdown's base style,
+// then reverse video for the active item, then dimming for disabled items
+func (d *DropdownMenu) ResolvedStyle(index int, active bool) tcell.Style {
+	return d.resolvedStyle(CurrentMenuTheme(), index, active, false)
+}
+
+// resolvedStyle returns the style the item at index is drawn with, as for
+// ResolvedStyle. Items under the mouse pointer that aren't selected with
+// the keyboard are drawn with the subtler hover style
+func (d *DropdownMenu) resolvedStyle(theme MenuTheme, index int, active, hover bool) tcell.Style {
+	if index < 0 || index >= len(d.Items) {
+		return theme.Dropdown
+	}
+	if style := d.Items[index].Style; style != nil {
+		return overrideStyle(*style, d.Items[index].Enabled, active, hover)
+	}
+	if !d.Items[index].Enabled {
+		if active {
+			return theme.SelectedDisabled
+		}
+		if hover {
+			return theme.Disabled.Bold(true)
 		}
-		itemY++
+		return theme.Disabled
+	}
+	if active {
+		return theme.Selected
+	}
+	if hover {
+		return theme.Hover
+	}
+	return theme.Dropdown
+}
+
+// overrideStyle returns the style an item with the given Style override is
+// drawn with, layering the states of the item over it like the defaults
+func overrideStyle(style tcell.Style, enabled, active, hover bool) tcell.Style {
+	if active {
+		style = style.Reverse(true)
+	} else if hover {
+		style = style.Bold(true)
+	}
+	if !enabled {
+		style = style.Dim(true)
 	}
+	return style
 }
 
 // HandleClick handles mouse clicks on the dropdown
@@ -246,24 +1862,67 @@ func (d *DropdownMenu) HandleClick(x, y int) *DropdownItem {
 		return nil
 	}
 
+	// Clicks on an open submenu are handled by the submenu
+	if sub := d.ActiveSubmenu(); sub != nil && sub.containsTree(x, y) {
+		item := sub.HandleClick(x, y)
+		if item != nil && !item.KeepOpenOnSelect {
+			d.Hide()
+		}
+		return item
+	}
+
 	// Check if click is inside dropdown bounds
-	if x < d.X || x >= d.X+d.Width || y < d.Y || y >= d.Y+d.Height {
+	height := d.shownHeight()
+	if !d.Contains(x, y) {
 		// Click outside dropdown - hide it
 		d.Hide()
 		return nil
 	}
 
 	// Check if click is on border
-	if x == d.X || x == d.X+d.Width-1 || y == d.Y || y == d.Y+d.Height-1 {
+	if x == d.shownX || x == d.shownX+d.Width-1 || y == d.shownY || y == d.shownY+height-1 {
+		return nil
+	}
+
+	// Items can't be chosen until the dropdown has rolled down
+	if d.Revealing() {
 		return nil
 	}
 
+	// Clicking an item with a submenu of an accordion dropdown toggles
+	// its inline items
+	if d.Accordion {
+		r, ok := d.rowAt(y - d.shownY)
+		if !ok || !d.rowSelectable(r) {
+			return nil
+		}
+		d.Active, d.inlineActive = r.item, r
+		item := d.rowItem(r)
+		if !item.Enabled {
+			return nil
+		}
+		if item.Submenu != nil {
+			d.ToggleExpanded()
+			return nil
+		}
+		d.choose(item)
+		return item
+	}
+
 	// Calculate which item was clicked
-	itemIndex := y - d.Y - 1 // -1 for top border
-	if itemIndex >= 0 && itemIndex < len(d.Items) {
+	itemIndex := d.itemAt(x-d.shownX, y-d.shownY)
+	if itemIndex >= 0 {
 		item := &d.Items[itemIndex]
 		if !item.Separator && item.Enabled {
-			d.Hide()
+			if item.Submenu != nil {
+				// Clicking a submenu item only opens the submenu
+				d.Active = itemIndex
+				d.syncSubmenu()
+				d.ExpandSubmenu()
+				return nil
+			}
+			d.Active = itemIndex
+			d.choose(item)
 			return item
 		}
 	}
@@ -271,6 +1930,140 @@ func (d *DropdownMenu) HandleClick(x, y int) *DropdownItem {
 	return nil
 }
 
+// choose hides the dropdown after the given item of it was chosen, unless
+// the item is KeepOpenOnSelect, in which case its check mark is updated
+// instead
+func (d *DropdownMenu) choose(item *DropdownItem) {
+	if !item.KeepOpenOnSelect {
+		d.Hide()
+		return
+	}
+	if item.RadioGroup != "" {
+		d.SetRadioSelection(item.RadioGroup, item.Action)
+	} else if item.Checkable {
+		item.Checked = !item.Checked
+	}
+}
+
+// Contains returns whether the given point lies within the dropdown as it
+// was last drawn
+func (d *DropdownMenu) Contains(x, y int) bool {
+	return d.Visible && x >= d.shownX && x < d.shownX+d.Width && y >= d.shownY && y < d.shownY+d.shownHeight()
+}
+
+// shadowContains returns whether the given point lies on the shadow of the
+// dropdown as it was last drawn, see ShadowEnabled
+func (d *DropdownMenu) shadowContains(x, y int) bool {
+	s := d.shadowSize()
+	return d.Visible && s > 0 && x >= d.shownX+s && x < d.shownX+d.Width+s && y >= d.shownY+s && y < d.shownY+d.shownHeight()+s
+}
+
+// containsTree returns whether the given point lies within the dropdown or
+// one of its open submenus
+func (d *DropdownMenu) containsTree(x, y int) bool {
+	if d.Contains(x, y) {
+		return true
+	}
+	if sub := d.ActiveSubmenu(); sub != nil {
+		return sub.containsTree(x, y)
+	}
+	return false
+}
+
+// HandleWheel scrolls the dropdown or submenu under the given point by
+// the given number of lines. It returns false if the point is outside
+func (d *DropdownMenu) HandleWheel(x, y, lines int) bool {
+	if sub := d.ActiveSubmenu(); sub != nil && sub.HandleWheel(x, y, lines) {
+		return true
+	}
+	if !d.Contains(x, y) {
+		return false
+	}
+	d.ScrollBy(lines)
+	return true
+}
+
+// HandleHover highlights the item under the given point with the hover
+// style. The item selected with the keyboard only follows the pointer onto
+// items with a submenu, which is opened
+func (d *DropdownMenu) HandleHover(x, y int) {
+	if !d.Visible || d.Revealing() {
+		return
+	}
+	if sub := d.ActiveSubmenu(); sub != nil && sub.containsTree(x, y) {
+		d.hoverActive = menuRow{-1, -1}
+		sub.HandleHover(x, y)
+		return
+	}
+	d.clearHover()
+	if x <= d.shownX || x >= d.shownX+d.Width-1 || y <= d.shownY || y >= d.shownY+d.shownHeight()-1 {
+		d.dwell.reset()
+		return
+	}
+	r, ok := d.rowAt(y - d.shownY)
+	if !d.Accordion {
+		i := d.itemAt(x-d.shownX, y-d.shownY)
+		r, ok = menuRow{i, -1}, i >= 0
+	}
+	if !ok || !d.rowSelectable(r) {
+		d.dwell.reset()
+		return
+	}
+	d.hoverActive = r
+	if !d.Accordion && d.Items[r.item].Submenu != nil && r.item != d.Active {
+		if d.dwell.rested(r.item, d.HoverDelayMs) {
+			d.openHoveredSubmenu(r.item)
+		}
+	} else {
+		d.dwell.reset()
+	}
+}
+
+// clearHover removes the hover highlight from the dropdown and its open
+// submenus
+func (d *DropdownMenu) clearHover() {
+	d.hoverActive = menuRow{-1, -1}
+	if sub := d.ActiveSubmenu(); sub != nil {
+		sub.clearHover()
+	}
+}
+
+// SetRadioSelection checks the item with the given action among the items
+// of the radio group, in the dropdown and its submenus, and unchecks the
+// others
+func (d *DropdownMenu) SetRadioSelection(group, action string) {
+	for i := range d.Items {
+		item := &d.Items[i]
+		if item.RadioGroup == group && !item.Separator {
+			item.Checked = item.Action == action
+		}
+		if item.Submenu != nil {
+			item.Submenu.SetRadioSelection(group, action)
+		}
+	}
+}
+
+// clone returns a deep copy of the dropdown and its submenus
+func (d *DropdownMenu) clone() *DropdownMenu {
+	c := *d
+	c.onPopulate = nil
+	c.Items = append([]DropdownItem(nil), d.Items...)
+	for i := range c.Items {
+		if c.Items[i].Submenu != nil {
+			c.Items[i].Submenu = c.Items[i].Submenu.clone()
+		}
+	}
+	busyLock.Lock()
+	defer busyLock.Unlock()
+	if d.busy != nil {
+		c.busy = make(map[string]bool, len(d.busy))
+		for action, busy := range d.busy {
+			c.busy[action] = busy
+		}
+	}
+	return &c
+}
+
 // HandleKey handles keyboard navigation in the dropdown
 func (d *DropdownMenu) HandleKey(key rune) *DropdownItem {
 	if !d.Visible {
@@ -278,11 +2071,13 @@ func (d *DropdownMenu) HandleKey(key rune) *DropdownItem {
 	}
 
 	// Check for hotkey matches
-	for _, item := range d.Items {
+	for i := range d.Items {
+		item := &d.Items[i]
 		if !item.Separator && item.Enabled {
-			if key == item.Hotkey || (key >= 'A' && key <= 'Z' && key-'A'+'a' == item.Hotkey) {
-				d.Hide()
-				return &item
+			if matchesHotkey(key, item.Hotkey) {
+				d.Active = i
+				d.choose(item)
+				return item
 			}
 		}
 	}
@@ -297,15 +2092,18 @@ func (d *DropdownMenu) NavigateUp() {
 	}
 
 	for i := d.Active - 1; i >= 0; i-- {
-		if !d.Items[i].Separator && d.Items[i].Enabled {
+		if d.isSelectable(i) {
 			d.Active = i
 			return
 		}
 	}
 
 	// Wrap to bottom
+	if !d.WrapNavigation {
+		return
+	}
 	for i := len(d.Items) - 1; i > d.Active; i-- {
-		if !d.Items[i].Separator && d.Items[i].Enabled {
+		if d.isSelectable(i) {
 			d.Active = i
 			return
 		}
@@ -319,112 +2117,295 @@ func (d *DropdownMenu) NavigateDown() {
 	}
 
 	for i := d.Active + 1; i < len(d.Items); i++ {
-		if !d.Items[i].Separator && d.Items[i].Enabled {
+		if d.isSelectable(i) {
 			d.Active = i
 			return
 		}
 	}
 
 	// Wrap to top
+	if !d.WrapNavigation {
+		return
+	}
 	for i := 0; i < d.Active; i++ {
-		if !d.Items[i].Separator && d.Items[i].Enabled {
+		if d.isSelectable(i) {
 			d.Active = i
 			return
 		}
 	}
 }
 
-// SelectActive returns the currently active item and hides the dropdown
+// SelectActive returns the currently active item and hides the dropdown,
+// unless the item is KeepOpenOnSelect
 func (d *DropdownMenu) SelectActive() *DropdownItem {
-	if !d.Visible || d.Active < 0 || d.Active >= len(d.Items) {
+	item := d.GetActiveItem()
+	if !d.Visible || item == nil {
 		return nil
 	}
 
-	item := &d.Items[d.Active]
 	if !item.Separator && item.Enabled {
-		d.Hide()
+		d.choose(item)
 		return item
 	}
 
 	return nil
 }
 
-// GetActiveItem returns the currently active item, or nil if none
+// GetActiveItem returns the currently active item, or nil if none. In an
+// accordion dropdown it may be an inline item of the active item's submenu
 func (d *DropdownMenu) GetActiveItem() *DropdownItem {
 	if d.Active >= 0 && d.Active < len(d.Items) {
-		return &d.Items[d.Active]
+		return d.rowItem(d.activeRow())
 	}
 	return nil
 }
 
-// MoveUp moves selection up to previous selectable item
-func (d *DropdownMenu) MoveUp() {
-	if d.Active < 0 {
-		// No item selected, select the last selectable item
-		for i := len(d.Items) - 1; i >= 0; i-- {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
-				return
-			}
+// ActiveDescription returns the description of the highlighted item, in
+// the submenu that has keyboard focus if there is one. For a disabled item
+// with a DisabledReason it is the item's text followed by the reason, such
+// as "Paste (clipboard is empty)". It is empty if no item is highlighted or
+// the item has no description
+func (d *DropdownMenu) ActiveDescription() string {
+	focused := d.FocusedMenu()
+	item := focused.GetActiveItem()
+	if item == nil || item.Separator {
+		return ""
+	}
+	if !item.Enabled && item.DisabledReason != "" {
+		return focused.translate(item.Text) + " (" + focused.translate(item.DisabledReason) + ")"
+	}
+	if item.Description == "" {
+		return ""
+	}
+	return focused.translate(item.Description)
+}
+
+// DefaultFormatItem formats items when FormatItem is nil. The label is the
+// item's text, followed by its HotkeyLabel or its hotkey in parentheses if
+// mnemonics are shown, the hotkey only if it doesn't appear in the text.
+// The right-aligned text is the item's shortcut
+func (d *DropdownMenu) DefaultFormatItem(item DropdownItem) (left, right string) {
+	left = d.translate(item.Text)
+	if !d.HideMnemonics {
+		if item.HotkeyLabel != "" {
+			left += " (" + item.HotkeyLabel + ")"
+		} else if item.Hotkey != 0 && hotkeyIndex(left, item.Hotkey) < 0 {
+			left += " (" + string(item.Hotkey) + ")"
 		}
-	} else if d.Active == 0 {
-		// At first item, wrap to last selectable item
-		for i := len(d.Items) - 1; i >= 0; i-- {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
-				return
-			}
+	}
+	return left, item.Shortcut
+}
+
+// formatItem formats an item with FormatItem, see DefaultFormatItem
+func (d *DropdownMenu) formatItem(item DropdownItem) (left, right string) {
+	if d.FormatItem == nil {
+		return d.DefaultFormatItem(item)
+	}
+	return d.FormatItem(item)
+}
+
+// translate returns the text shown for the given text, see Translate
+func (d *DropdownMenu) translate(text string) string {
+	if d.Translate == nil {
+		return text
+	}
+	return d.Translate(text)
+}
+
+// MoveToLetter moves the selection to the next selectable item after the
+// active one whose text starts with the given letter, ignoring case and
+// wrapping around. It returns whether such an item was found
+func (d *DropdownMenu) MoveToLetter(letter rune) bool {
+	rows := d.matchedItems()
+	start := d.itemRow(d.Active)
+	letter = unicode.ToLower(letter)
+	for n := 1; n <= len(rows); n++ {
+		i := rows[(start+n+len(rows))%len(rows)]
+		first, _ := utf8.DecodeRuneInString(d.translate(d.Items[i].Text))
+		if d.isSelectable(i) && unicode.ToLower(first) == letter {
+			d.Active = i
+			d.inlineActive = menuRow{-1, -1}
+			d.scrollToActive()
+			d.syncSubmenu()
+			return true
 		}
-	} else {
-		// Move to previous selectable item
-		for i := d.Active - 1; i >= 0; i-- {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
+	}
+	return false
+}
+
+// MoveUp moves selection up to previous selectable item
+func (d *DropdownMenu) MoveUp() {
+	if d.Accordion {
+		d.moveRows(-1)
+		return
+	}
+	defer d.syncSubmenu()
+	defer d.scrollToActive()
+	rows := d.matchedItems()
+	start := d.itemRow(d.Active)
+	if start < 0 {
+		// No item selected, start from the last selectable item
+		start = len(rows)
+	}
+	// Wrap around to the last selectable item at the top
+	for n := 1; n <= len(rows); n++ {
+		row := start - n
+		if row < 0 {
+			if !d.WrapNavigation {
 				return
 			}
+			row += len(rows)
 		}
-		// If no previous selectable item found, wrap to last
-		for i := len(d.Items) - 1; i >= 0; i-- {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
-				return
-			}
+		if i := rows[row]; d.isSelectable(i) {
+			d.Active = i
+			return
 		}
 	}
 }
 
 // MoveDown moves selection down to next selectable item
 func (d *DropdownMenu) MoveDown() {
-	if d.Active < 0 {
-		// No item selected, select the first selectable item
-		for i := 0; i < len(d.Items); i++ {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
+	if d.Accordion {
+		d.moveRows(1)
+		return
+	}
+	defer d.syncSubmenu()
+	defer d.scrollToActive()
+	rows := d.matchedItems()
+	start := d.itemRow(d.Active)
+	// Wrap around to the first selectable item at the bottom, with no
+	// item selected start from the first one
+	for n := 1; n <= len(rows); n++ {
+		row := start + n
+		if row >= len(rows) {
+			if !d.WrapNavigation {
 				return
 			}
+			row -= len(rows)
 		}
-	} else if d.Active >= len(d.Items)-1 {
-		// At last item, wrap to first selectable item
-		for i := 0; i < len(d.Items); i++ {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
-				return
-			}
+		if i := rows[row]; d.isSelectable(i) {
+			d.Active = i
+			return
 		}
-	} else {
-		// Move to next selectable item
-		for i := d.Active + 1; i < len(d.Items); i++ {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
+	}
+}
+
+// moveRows moves the selection of an accordion dropdown to the next
+// selectable row in the given direction, including the inline items
+func (d *DropdownMenu) moveRows(dir int) {
+	defer d.scrollToActive()
+	rows := d.rows()
+	start := d.rowIndex(d.activeRow())
+	if start < 0 && dir < 0 {
+		// No item selected, start from the last selectable row
+		start = len(rows)
+	}
+	for n := 1; n <= len(rows); n++ {
+		pos := start + dir*n
+		if pos < 0 || pos >= len(rows) {
+			if !d.WrapNavigation {
 				return
 			}
+			pos = (pos + len(rows)) % len(rows)
 		}
-		// If no next selectable item found, wrap to first
-		for i := 0; i < len(d.Items); i++ {
-			if d.Items[i].Enabled && !d.Items[i].Separator {
-				d.Active = i
+		if r := rows[pos]; d.rowSelectable(r) {
+			d.Active, d.inlineActive = r.item, r
+			return
+		}
+	}
+}
+
+// MoveToFirst moves the selection to the first selectable item and scrolls
+// to the top
+func (d *DropdownMenu) MoveToFirst() {
+	d.scrollOffset = 0
+	d.moveToRow(0, 1)
+}
+
+// MoveToLast moves the selection to the last selectable item, skipping
+// trailing separators and disabled items, and scrolls to the bottom
+func (d *DropdownMenu) MoveToLast() {
+	d.scrollOffset = d.gridRows()
+	d.moveToRow(len(d.rows())-1, -1)
+}
+
+// MovePageUp moves the selection up by the number of rows that are shown
+// at once, stopping at the first selectable item
+func (d *DropdownMenu) MovePageUp() {
+	d.moveToRow(d.rowIndex(d.activeRow())-d.pageRows(), -1)
+}
+
+// MovePageDown moves the selection down by the number of rows that are
+// shown at once, stopping at the last selectable item
+func (d *DropdownMenu) MovePageDown() {
+	d.moveToRow(d.rowIndex(d.activeRow())+d.pageRows(), 1)
+}
+
+// pageRows returns the number of rows MovePageUp and MovePageDown move by
+func (d *DropdownMenu) pageRows() int {
+	if rows := d.visibleRows(); rows > 1 {
+		return rows
+	}
+	return 1
+}
+
+// moveToRow moves the selection to the selectable row closest to the given
+// position in the given direction, or in the other one if there is none.
+// Positions past the rows are moved to the first or last row
+func (d *DropdownMenu) moveToRow(pos, dir int) {
+	rows := d.rows()
+	if len(rows) == 0 {
+		return
+	}
+	if pos < 0 {
+		pos = 0
+	} else if pos >= len(rows) {
+		pos = len(rows) - 1
+	}
+	for _, step := range []int{dir, -dir} {
+		for p := pos; p >= 0 && p < len(rows); p += step {
+			if r := rows[p]; d.rowSelectable(r) {
+				d.Active, d.inlineActive = r.item, r
+				d.scrollToActive()
+				d.syncSubmenu()
 				return
 			}
 		}
 	}
 }
+
+// MoveLeft moves the selection to the closest selectable item in the columns
+// to the left of the active item. It returns false if there is none, which
+// is always the case with a single column
+func (d *DropdownMenu) MoveLeft() bool {
+	return d.moveColumns(-1)
+}
+
+// MoveRight moves the selection to the closest selectable item in the
+// columns to the right of the active item. It returns false if there is none
+func (d *DropdownMenu) MoveRight() bool {
+	return d.moveColumns(1)
+}
+
+// moveColumns moves the selection by whole columns in the given direction,
+// staying in the same row and skipping items that can't be selected
+func (d *DropdownMenu) moveColumns(dir int) bool {
+	if d.columnCount() < 2 {
+		return false
+	}
+	rows := d.matchedItems()
+	gridRows := d.gridRows()
+	pos := d.itemRow(d.Active)
+	if pos < 0 {
+		return false
+	}
+	for pos += dir * gridRows; pos >= 0 && pos < len(rows); pos += dir * gridRows {
+		if i := rows[pos]; d.isSelectable(i) {
+			d.Active = i
+			d.scrollToActive()
+			d.syncSubmenu()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1336 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/config"
+	"github.com/zyedidia/micro/v2/internal/screen"
+)
+
+// initTestScreen sets up a simulation screen of the given size
+func initTestScreen(t *testing.T, w, h int) tcell.SimulationScreen {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSize(w, h)
+	screen.Screen = s
+	return s
+}
+
+func TestResolvedStyle(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Enabled", Action: "A", Enabled: true},
+		{Text: "Disabled", Action: "B", Enabled: false},
+	})
+
+	_, _, attr := d.ResolvedStyle(0, false).Decompose()
+	assert.Equal(t, tcell.AttrNone, attr)
+
+	_, _, attr = d.ResolvedStyle(0, true).Decompose()
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrReverse)
+
+	_, _, attr = d.ResolvedStyle(1, true).Decompose()
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrReverse)
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrDim)
+}
+
+func TestResolvedStyleColorscheme(t *testing.T) {
+	active := tcell.StyleDefault.Foreground(tcell.ColorRed)
+	disabled := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	if config.Colorscheme == nil {
+		config.Colorscheme = make(map[string]tcell.Style)
+	}
+	config.Colorscheme["dropdown.active"] = active
+	config.Colorscheme["dropdown.disabled"] = disabled
+	defer delete(config.Colorscheme, "dropdown.active")
+	defer delete(config.Colorscheme, "dropdown.disabled")
+
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Enabled", Action: "A", Enabled: true},
+		{Text: "Disabled", Action: "B", Enabled: false},
+	})
+
+	assert.Equal(t, config.DefStyle, d.ResolvedStyle(0, false))
+	assert.Equal(t, active, d.ResolvedStyle(0, true))
+	assert.Equal(t, disabled, d.ResolvedStyle(1, false))
+	assert.Equal(t, disabled.Reverse(true), d.ResolvedStyle(1, true))
+}
+
+func newSubmenuDropdown() (*DropdownMenu, *DropdownMenu) {
+	sub := NewDropdownMenu()
+	sub.SetItems([]DropdownItem{
+		{Text: "First", Action: "First", Enabled: true},
+		{Text: "Second", Action: "Second", Enabled: true},
+	})
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Open", Action: "Open", Enabled: true},
+		{Text: "Recent Files", Action: "Recent", Enabled: true, Submenu: sub},
+	})
+	return d, sub
+}
+
+func TestSubmenuNavigation(t *testing.T) {
+	d, sub := newSubmenuDropdown()
+	d.Show(0, 1)
+	assert.False(t, sub.IsVisible())
+
+	// Highlighting the parent item shows the submenu next to it
+	d.MoveDown()
+	assert.True(t, sub.IsVisible())
+	assert.Equal(t, d.X+d.Width, sub.X)
+	assert.Equal(t, d, d.FocusedMenu())
+
+	assert.True(t, d.ExpandSubmenu())
+	assert.Equal(t, sub, d.FocusedMenu())
+	sub.MoveDown()
+	assert.Equal(t, "Second", d.FocusedMenu().GetActiveItem().Action)
+
+	assert.True(t, d.CollapseSubmenu())
+	assert.Equal(t, d, d.FocusedMenu())
+	assert.False(t, d.CollapseSubmenu())
+
+	d.MoveUp()
+	assert.False(t, sub.IsVisible())
+}
+
+func TestSubmenuFlipsLeft(t *testing.T) {
+	initTestScreen(t, 40, 20)
+	d, sub := newSubmenuDropdown()
+	d.Show(30, 1)
+	d.MoveDown()
+	d.Display()
+
+	// There is no room on the right so the submenu opens on the left of
+	// the dropdown, which leaves room for its shadow
+	assert.Equal(t, 40-d.Width-1-sub.Width, sub.X)
+}
+
+func TestSubmenuShadowUnderParent(t *testing.T) {
+	initTestScreen(t, 40, 20)
+	d, sub := newSubmenuDropdown()
+	d.Show(30, 1)
+	d.MoveDown()
+	cells, set := newCellGrid(40, 20)
+	d.draw(set, 40, 20)
+
+	// The shadow of the submenu opened on the left falls on the left
+	// border of the dropdown, which stays drawn
+	assert.Equal(t, d.shownX, sub.shownX+sub.Width)
+	for y := d.shownY + 1; y < d.shownY+d.shownHeight()-1; y++ {
+		assert.Equal(t, '│', cells[y][d.shownX].Rune, "row %d", y)
+	}
+}
+
+func TestShortcutWidth(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Save", Action: "Save", Enabled: true, Shortcut: "Ctrl-s"},
+		{Text: "Save As", Action: "SaveAs", Enabled: true},
+	})
+	// Label, gap, shortcut, borders and padding
+	assert.Equal(t, 7+shortcutGap+6+4, d.Width)
+
+	assert.Equal(t, "Ctrl-s", truncateWidth("Ctrl-s", 6))
+	assert.Equal(t, "Ctr…", truncateWidth("Ctrl-s", 4))
+	assert.Equal(t, "", truncateWidth("Ctrl-s", 0))
+}
+
+func TestJoinedRunesWidth(t *testing.T) {
+	// Emoji joined by a zero-width joiner are drawn as separate characters,
+	// so the dropdown is sized for all of them
+	coder := "\U0001F469\u200d\U0001F4BB"
+	d := NewDropdownMenu()
+	d.Title = coder + coder
+	d.SetItems([]DropdownItem{
+		{Text: "Dev " + coder, Action: "Dev", Enabled: true, Shortcut: coder},
+	})
+	assert.Equal(t, 4+4+shortcutGap+4+4, d.Width)
+
+	d.Show(0, 1)
+	cells, set := newCellGrid(40, 20)
+	d.draw(set, 40, 20)
+	row := ""
+	for _, c := range cells[2][:d.Width] {
+		row += string(c.Rune)
+	}
+	assert.Equal(t, "│ Dev \U0001F469 \U0001F4BB   \U0001F469 \U0001F4BB  │", row)
+	top := ""
+	for _, c := range cells[1][:d.Width] {
+		top += string(c.Rune)
+	}
+	assert.Equal(t, "┌─── \U0001F469─\U0001F4BB─\U0001F469─\U0001F4BB─ ───┐", top)
+}
+
+func TestCheckableState(t *testing.T) {
+	on := false
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Ruler", Action: "ToggleRuler", Enabled: true, Checkable: true,
+			StateFunc: func() bool { return on }},
+		{Text: "Other", Action: "Other", Enabled: true},
+	})
+	// Room is reserved for the checkmark column
+	assert.Equal(t, 5+2+4, d.Width)
+
+	d.Show(0, 1)
+	assert.False(t, d.Items[0].Checked)
+	d.Hide()
+
+	on = true
+	d.Show(0, 1)
+	assert.True(t, d.Items[0].Checked)
+}
+
+func TestDropdownScrolling(t *testing.T) {
+	initTestScreen(t, 40, 8)
+	items := make([]DropdownItem, 20)
+	for i := range items {
+		items[i] = DropdownItem{Text: string(rune('a' + i)), Action: string(rune('A' + i)), Enabled: true}
+	}
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.SetItems(items)
+	d.Show(0, 1)
+	d.Display()
+
+	// 7 rows are left below the anchor, 5 of them for items
+	assert.Equal(t, 5, d.visibleRows())
+	assert.Equal(t, 0, d.scrollOffset)
+
+	for i := 0; i < 6; i++ {
+		d.MoveDown()
+	}
+	assert.Equal(t, 6, d.Active)
+	assert.Equal(t, 2, d.scrollOffset)
+
+	d.MoveUp()
+	d.MoveUp()
+	d.MoveUp()
+	assert.Equal(t, 3, d.Active)
+	assert.Equal(t, 2, d.scrollOffset)
+
+	// Clicks map to the scrolled items
+	item := d.HandleClick(2, 2)
+	assert.NotNil(t, item)
+	assert.Equal(t, "C", item.Action)
+
+	d.Show(0, 1)
+	d.Display()
+	assert.True(t, d.HandleWheel(2, 3, 1))
+	assert.Equal(t, 1, d.scrollOffset)
+	d.ScrollBy(100)
+	assert.Equal(t, 15, d.scrollOffset)
+	assert.False(t, d.HandleWheel(30, 3, 1))
+
+	// Redrawing keeps the items scrolled away from the active item, and
+	// moving the active item scrolls back to it
+	d.Show(0, 1)
+	d.Display()
+	for i := 0; i < 3; i++ {
+		assert.True(t, d.HandleWheel(2, 3, 1))
+	}
+	d.Display()
+	assert.Equal(t, 3, d.scrollOffset)
+	assert.Equal(t, 'd', d.RenderToCells(40, 8)[2][2].Rune)
+	d.MoveDown()
+	assert.Equal(t, 1, d.scrollOffset)
+}
+
+func TestDropdownFilter(t *testing.T) {
+	initTestScreen(t, 40, 20)
+	names := []string{"Alpha", "Beta", "Gamma", "Delta", "Epsilon", "Zeta", "Eta", "Theta", "Iota", "Kappa"}
+	items := make([]DropdownItem, len(names))
+	for i, name := range names {
+		items[i] = DropdownItem{Text: name, Action: name, Enabled: true}
+	}
+	d := NewDropdownMenu()
+	d.SetItems(items)
+	d.Show(0, 1)
+	assert.True(t, d.Filterable())
+
+	d.SetFilter("TA")
+	assert.Equal(t, []int{1, 3, 5, 6, 7, 8}, d.matchedItems())
+	assert.Equal(t, 1, d.Active)
+
+	// Navigation only visits the matching items and wraps around them
+	d.MoveDown()
+	assert.Equal(t, 3, d.Active)
+	d.MoveUp()
+	d.MoveUp()
+	assert.Equal(t, 8, d.Active)
+
+	// Clicks map to the filtered rows
+	d.Display()
+	item := d.HandleClick(2, 3)
+	assert.NotNil(t, item)
+	assert.Equal(t, "Delta", item.Action)
+
+	d.Show(0, 1)
+	assert.Equal(t, "", d.Filter())
+	assert.Len(t, d.matchedItems(), len(names))
+}
+
+func TestClickHitsDrawnItem(t *testing.T) {
+	s := initTestScreen(t, 40, 8)
+	items := []DropdownItem{{Text: "Recent", Separator: true}}
+	for i := 0; i < 12; i++ {
+		name := "item" + string(rune('a'+i))
+		items = append(items, DropdownItem{Text: name, Action: name, Enabled: true})
+		if i == 5 {
+			items = append(items, DropdownItem{Text: "More", Separator: true})
+		}
+	}
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.HideMnemonics = true
+	d.SetItems(items)
+
+	// In a filtered, scrolled dropdown whose height is clamped by the
+	// screen, every click chooses the item drawn under the pointer
+	open := func() {
+		d.Show(0, 1)
+		d.SetFilter("item")
+		d.ScrollBy(3)
+		d.Display()
+	}
+	open()
+	assert.Equal(t, 3, d.scrollOffset)
+	s.Show()
+	cells, width, _ := s.GetContents()
+	for y := 2; y < 7; y++ {
+		label := ""
+		for x := 2; x < 7; x++ {
+			label += string(cells[y*width+x].Runes)
+		}
+		open()
+		if item := d.HandleClick(2, y); assert.NotNil(t, item) {
+			assert.Equal(t, label, item.Text)
+		}
+	}
+}
+
+func TestLabeledSeparator(t *testing.T) {
+	s := initTestScreen(t, 40, 10)
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Open", Action: "Open", Enabled: true},
+		{Text: "Recent Files", Separator: true},
+		{Text: "Quit", Action: "Quit", Enabled: true},
+	})
+	assert.Equal(t, 18, d.Width)
+
+	d.Show(0, 1)
+	d.Display()
+	s.Show()
+	cells, width, _ := s.GetContents()
+	row := ""
+	for x := 1; x < d.Width-1; x++ {
+		row += string(cells[3*width+x].Runes)
+	}
+	assert.Equal(t, "─ Recent Files ─", row)
+
+	// Navigation and clicks skip the separator
+	d.MoveDown()
+	assert.Equal(t, 2, d.Active)
+	assert.Nil(t, d.HandleClick(2, 3))
+}
+
+func TestMoveToLetter(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Bold", Action: "Bold", Enabled: true},
+		{Text: "Italic", Action: "Italic", Enabled: true},
+		{Separator: true},
+		{Text: "blink", Action: "Blink", Enabled: false},
+		{Text: "Border", Action: "Border", Enabled: true},
+	})
+	d.Show(0, 1)
+	assert.Equal(t, 0, d.Active)
+
+	// Repeated presses cycle through the matches, skipping disabled items
+	assert.True(t, d.MoveToLetter('b'))
+	assert.Equal(t, 4, d.Active)
+	assert.True(t, d.MoveToLetter('B'))
+	assert.Equal(t, 0, d.Active)
+	assert.False(t, d.MoveToLetter('z'))
+	assert.Equal(t, 0, d.Active)
+}
+
+func TestStopAtEdgeNavigation(t *testing.T) {
+	d := NewDropdownMenu()
+	d.WrapNavigation = false
+	d.SetItems([]DropdownItem{
+		{Text: "First", Action: "First", Enabled: true},
+		{Separator: true},
+		{Text: "Disabled", Action: "Disabled", Enabled: false},
+	})
+	d.Show(0, 1)
+	assert.Equal(t, 0, d.Active)
+
+	// The only selectable item is the first one
+	d.MoveDown()
+	assert.Equal(t, 0, d.Active)
+	d.MoveUp()
+	assert.Equal(t, 0, d.Active)
+
+	// The only selectable item is the last one
+	d.SetItems([]DropdownItem{
+		{Text: "Disabled", Action: "Disabled", Enabled: false},
+		{Text: "Last", Action: "Last", Enabled: true},
+	})
+	d.Show(0, 1)
+	assert.Equal(t, 1, d.Active)
+	d.MoveDown()
+	assert.Equal(t, 1, d.Active)
+	d.MoveUp()
+	assert.Equal(t, 1, d.Active)
+
+	d.WrapNavigation = true
+	d.SetItems([]DropdownItem{
+		{Text: "First", Action: "First", Enabled: true},
+		{Text: "Last", Action: "Last", Enabled: true},
+	})
+	d.Show(0, 1)
+	d.MoveUp()
+	assert.Equal(t, 1, d.Active)
+}
+
+func TestDropdownBounds(t *testing.T) {
+	initTestScreen(t, 40, 20)
+	d, sub := newSubmenuDropdown()
+	x, y, w, h := d.Bounds()
+	assert.Equal(t, [4]int{0, 0, 0, 0}, [4]int{x, y, w, h})
+
+	// Dropdowns going off the right edge are moved left, along with
+	// their shadow
+	d.Show(35, 1)
+	x, y, w, h = d.Bounds()
+	assert.Equal(t, [4]int{40 - d.Width - 1, 1, d.Width, 4}, [4]int{x, y, w, h})
+
+	// Bounds agree with what is drawn
+	d.Display()
+	assert.Equal(t, 2, d.viewRows)
+
+	d.MoveDown()
+	d.ExpandSubmenu()
+	assert.True(t, sub.IsVisible())
+	assert.Len(t, d.regions(), 2)
+}
+
+func TestDropdownShadow(t *testing.T) {
+	s := initTestScreen(t, 40, 6)
+	d, _ := newSubmenuDropdown()
+	d.ShadowOffset = 2
+	d.Show(0, 1)
+	d.Display()
+	s.Show()
+
+	// The dropdown is shortened to keep its shadow on screen
+	_, _, _, h := d.Bounds()
+	assert.Equal(t, 3, h)
+	cells, width, _ := s.GetContents()
+	_, _, attr := cells[5*width+d.Width+1].Style.Decompose()
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrDim)
+
+	// Without a shadow the dropdown keeps its full height
+	d.ShadowEnabled = false
+	_, _, _, h = d.Bounds()
+	assert.Equal(t, 4, h)
+}
+
+func TestIconColumn(t *testing.T) {
+	s := initTestScreen(t, 40, 10)
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Save", Action: "Save", Icon: '💾', Enabled: true},
+		{Text: "Quit", Action: "Quit", Enabled: true},
+	})
+	// The icon column has room for the wide glyph and a space
+	assert.Equal(t, 3, d.iconWidth())
+	assert.Equal(t, 4+3+4, d.Width)
+
+	d.Show(0, 1)
+	d.Display()
+	s.Show()
+	cells, width, _ := s.GetContents()
+	assert.Equal(t, '💾', cells[2*width+2].Runes[0])
+
+	// Labels stay aligned for items without an icon
+	assert.Equal(t, 'S', cells[2*width+5].Runes[0])
+	assert.Equal(t, 'Q', cells[3*width+5].Runes[0])
+}
+
+func TestActiveDescription(t *testing.T) {
+	d, sub := newSubmenuDropdown()
+	d.Items[0].Description = "Open a file"
+	sub.Items[0].Description = "Open the most recent file"
+	d.Show(0, 1)
+	assert.Equal(t, "Open a file", d.ActiveDescription())
+
+	// Items without a description clear it
+	d.MoveDown()
+	assert.Equal(t, "", d.ActiveDescription())
+
+	// The focused submenu's item is described
+	d.ExpandSubmenu()
+	assert.Equal(t, "Open the most recent file", d.ActiveDescription())
+
+	d.Hide()
+	assert.Equal(t, "", d.ActiveDescription())
+}
+
+func TestWideAndCombiningLabels(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "日本語", Action: "A", Hotkey: '字', Enabled: true},
+		{Text: "Cafe\u0301", Action: "B", Hotkey: 'C', Enabled: true},
+		{Text: "Party 🎉", Action: "C", Enabled: true},
+	})
+	// The widest label is the CJK one with its wide " (字)" hint
+	assert.Equal(t, 6+5+4, d.Width)
+
+	d.ShadowEnabled = false
+	d.Show(0, 0)
+	cells := d.RenderToCells(30, 10)
+
+	// The right border isn't pushed out of place by any of the labels
+	assert.Equal(t, '┐', cells[0][d.Width-1].Rune)
+	for y := 1; y <= 3; y++ {
+		assert.Equal(t, '│', cells[y][d.Width-1].Rune)
+	}
+	assert.Equal(t, '┘', cells[4][d.Width-1].Rune)
+
+	assert.Equal(t, '日', cells[1][2].Rune)
+	assert.Equal(t, '語', cells[1][6].Rune)
+	assert.Equal(t, '字', cells[1][10].Rune)
+	assert.Equal(t, ')', cells[1][12].Rune)
+
+	// The combining accent is drawn along with its base letter
+	assert.Equal(t, 'e', cells[2][5].Rune)
+	assert.Equal(t, []rune{'\u0301'}, cells[2][5].Comb)
+	assert.Equal(t, ' ', cells[2][6].Rune)
+
+	assert.Equal(t, '🎉', cells[3][8].Rune)
+}
+
+func TestMultiColumnLayout(t *testing.T) {
+	d := NewDropdownMenu()
+	d.MaxColumns = 3
+	items := make([]DropdownItem, 25)
+	for i := range items {
+		name := fmt.Sprintf("Item%02d", i+1)
+		items[i] = DropdownItem{Text: name, Action: name, Enabled: true}
+	}
+	d.SetItems(items)
+	// 25 items fill three columns of nine rows
+	assert.Equal(t, 3, d.columnCount())
+	assert.Equal(t, 9+2, d.Height)
+	assert.Equal(t, 3*(6+2)+2, d.Width)
+
+	d.ShadowEnabled = false
+	d.Show(0, 0)
+	cells := d.RenderToCells(40, 20)
+	row := func(y int) string {
+		s := ""
+		for _, c := range cells[y][:d.Width] {
+			s += string(c.Rune)
+		}
+		return s
+	}
+	// Items are laid out column by column
+	assert.Equal(t, "│ Item01  Item10  Item19 │", row(1))
+	assert.Equal(t, "│ Item09  Item18         │", row(9))
+
+	// Moving down from the bottom of a column continues in the next one
+	for i := 0; i < 8; i++ {
+		d.MoveDown()
+	}
+	assert.Equal(t, 8, d.Active)
+	d.MoveDown()
+	assert.Equal(t, 9, d.Active)
+
+	// Left and right move between the columns in the same row
+	assert.True(t, d.MoveRight())
+	assert.Equal(t, 18, d.Active)
+	assert.False(t, d.MoveRight())
+	assert.True(t, d.MoveLeft())
+	assert.True(t, d.MoveLeft())
+	assert.Equal(t, 0, d.Active)
+	assert.False(t, d.MoveLeft())
+
+	// Clicks hit the item in the column under the pointer
+	item := d.HandleClick(d.X+1+2*8+2, d.Y+2)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Item20", item.Action)
+	}
+
+	// Short lists stay in a single column
+	d.SetItems(items[:10])
+	assert.Equal(t, 1, d.columnCount())
+	assert.False(t, d.MoveRight())
+}
+
+func TestMultiColumnTerminalSize(t *testing.T) {
+	d := NewDropdownMenu()
+	d.MaxColumns = 3
+	d.ShadowEnabled = false
+	items := make([]DropdownItem, 25)
+	for i := range items {
+		name := fmt.Sprintf("Item%02d", i+1)
+		items[i] = DropdownItem{Text: name, Action: name, Enabled: true}
+	}
+	d.SetItems(items)
+	d.Show(0, 0)
+
+	// On a terminal tall enough for all the items they stay in a column
+	d.RenderToCells(80, 40)
+	assert.Equal(t, 1, d.columnCount())
+	assert.Equal(t, 25+2, d.Height)
+
+	// On a short terminal they are split into columns
+	d.RenderToCells(80, 20)
+	assert.Equal(t, 3, d.columnCount())
+	assert.Equal(t, 9+2, d.Height)
+
+	// As many columns as fit on a narrow terminal are used
+	d.RenderToCells(20, 20)
+	assert.Equal(t, 2, d.columnCount())
+	assert.Equal(t, 2*(6+2)+2, d.Width)
+	assert.Equal(t, 13+2, d.Height)
+}
+
+func TestMultiColumnShownWhole(t *testing.T) {
+	initTestScreen(t, 80, 60)
+	d := NewDropdownMenu()
+	d.MaxColumns = 3
+	items := make([]DropdownItem, 25)
+	for i := range items {
+		name := fmt.Sprintf("Item%02d", i+1)
+		items[i] = DropdownItem{Text: name, Action: name, Enabled: true}
+	}
+	d.SetItems(items)
+
+	// The dropdown is split into columns before it knows the terminal,
+	// and shown whole in a single one once it does
+	assert.Equal(t, 3, d.columnCount())
+	d.Show(0, 1)
+	assert.Equal(t, 1, d.columnCount())
+	assert.Equal(t, 25+2, d.Height)
+	assert.False(t, d.Revealing())
+	d.MoveDown()
+	assert.Equal(t, 1, d.Active)
+	item := d.HandleClick(d.shownX+2, d.shownY+20)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Item20", item.Action)
+	}
+
+	// Resizing the terminal while it is shown keeps it whole
+	d.Show(0, 1)
+	d.RenderToCells(80, 20)
+	assert.Equal(t, 3, d.columnCount())
+	assert.False(t, d.Revealing())
+}
+
+func TestAnimatedReveal(t *testing.T) {
+	d, _ := newSubmenuDropdown()
+	d.ShadowEnabled = false
+
+	// Without animation the dropdown is shown at once
+	d.Show(0, 0)
+	assert.False(t, d.Revealing())
+
+	d.AnimateOpen = true
+	d.Show(0, 0)
+	assert.True(t, d.Revealing())
+	cells := d.RenderToCells(20, 10)
+	assert.Equal(t, ' ', cells[0][0].Rune)
+
+	// Every step rolls the dropdown down by a row
+	d.Step()
+	d.Step()
+	d.Step()
+	cells = d.RenderToCells(20, 10)
+	assert.Equal(t, '┌', cells[0][0].Rune)
+	assert.Equal(t, 'O', cells[1][2].Rune)
+	assert.Equal(t, '└', cells[2][0].Rune)
+	assert.Equal(t, ' ', cells[3][0].Rune)
+
+	assert.False(t, d.Step())
+	assert.False(t, d.Revealing())
+	cells = d.RenderToCells(20, 10)
+	assert.Equal(t, 'R', cells[2][2].Rune)
+	assert.Equal(t, '└', cells[3][0].Rune)
+}
+
+func TestASCIIBorders(t *testing.T) {
+	d, _ := newSubmenuDropdown()
+	d.ShadowEnabled = false
+	d.Items[0].Checkable = true
+	d.Items[0].Checked = true
+	d.SetItems(d.Items)
+	d.Show(0, 0)
+	row := func(cells [][]Cell, y int) string {
+		s := ""
+		for _, c := range cells[y][:d.Width] {
+			s += string(c.Rune)
+		}
+		return s
+	}
+
+	cells := d.RenderToCells(40, 10)
+	assert.Equal(t, "┌──────────────────┐", row(cells, 0))
+	assert.Equal(t, "│ ✓ Open           │", row(cells, 1))
+	assert.Equal(t, "│   Recent Files ▶ │", row(cells, 2))
+	assert.Equal(t, "└──────────────────┘", row(cells, 3))
+
+	d.UseASCIIBorders = true
+	cells = d.RenderToCells(40, 10)
+	assert.Equal(t, "+------------------+", row(cells, 0))
+	assert.Equal(t, "| * Open           |", row(cells, 1))
+	assert.Equal(t, "|   Recent Files > |", row(cells, 2))
+	assert.Equal(t, "+------------------+", row(cells, 3))
+}
+
+func TestHighlightDisabled(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Copy", Action: "Copy", Enabled: true},
+		{Text: "Paste", Action: "Paste", Enabled: false, DisabledReason: "clipboard is empty"},
+		{Text: "Find", Action: "Find", Enabled: true},
+	})
+
+	// Disabled items are skipped by default
+	d.Show(0, 1)
+	d.MoveDown()
+	assert.Equal(t, 2, d.Active)
+
+	d.AllowHighlightDisabled = true
+	d.Show(0, 1)
+	d.MoveDown()
+	assert.Equal(t, 1, d.Active)
+	assert.Equal(t, "Paste (clipboard is empty)", d.ActiveDescription())
+
+	// Highlighted disabled items still can't be chosen
+	assert.Nil(t, d.SelectActive())
+	assert.True(t, d.IsVisible())
+}
+
+func TestHandleKeyReturnsItem(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Alpha", Action: "alpha", Hotkey: 'a', Enabled: true},
+		{Text: "Beta", Action: "beta", Hotkey: 'b', Enabled: true},
+		{Text: "Gamma", Action: "gamma", Hotkey: 'g', Enabled: true},
+	})
+	d.Show(0, 1)
+
+	// The returned item is the matching entry itself, not a later one
+	item := d.HandleKey('b')
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "beta", item.Action)
+		assert.Same(t, &d.Items[1], item)
+	}
+}
+
+func TestRadioGroup(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "LF", Action: "set fileformat unix", Enabled: true, RadioGroup: "eol"},
+		{Text: "CRLF", Action: "set fileformat dos", Enabled: true, RadioGroup: "eol"},
+		{Separator: true},
+		{Text: "Other", Action: "other", Enabled: true},
+	})
+	d.SetRadioSelection("eol", "set fileformat dos")
+	assert.False(t, d.Items[0].Checked)
+	assert.True(t, d.Items[1].Checked)
+	assert.False(t, d.Items[3].Checked)
+
+	d.ShadowEnabled = false
+	d.Show(0, 0)
+	cells := d.RenderToCells(30, 10)
+	row := func(y int) string {
+		s := ""
+		for _, c := range cells[y][:d.Width] {
+			s += string(c.Rune)
+		}
+		return s
+	}
+	assert.Equal(t, "│ ○ LF    │", row(1))
+	assert.Equal(t, "│ ● CRLF  │", row(2))
+
+	// Radio items are selected like any other item
+	d.MoveDown()
+	assert.Equal(t, 1, d.Active)
+	d.MoveDown()
+	assert.Equal(t, 3, d.Active)
+}
+
+func TestRememberSelection(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "One", Action: "one", Enabled: true},
+		{Text: "Two", Action: "two", Enabled: true},
+		{Text: "Three", Action: "three", Enabled: true},
+	})
+
+	// Without the flag the first item is highlighted again
+	d.Show(0, 1)
+	d.MoveDown()
+	d.Hide()
+	d.Show(0, 1)
+	assert.Equal(t, 0, d.Active)
+
+	d.RememberSelection = true
+	d.MoveDown()
+	d.MoveDown()
+	d.Hide()
+	d.Hide()
+	d.Show(0, 1)
+	assert.Equal(t, 2, d.Active)
+
+	// A remembered item that was disabled in the meantime isn't restored
+	d.Hide()
+	d.Items[2].Enabled = false
+	d.Show(0, 1)
+	assert.Equal(t, 0, d.Active)
+
+	// Neither is one that was removed
+	d.MoveDown()
+	d.Hide()
+	d.SetItems(d.Items[:1])
+	d.Show(0, 1)
+	assert.Equal(t, 0, d.Active)
+}
+
+func TestAccordion(t *testing.T) {
+	d, sub := newSubmenuDropdown()
+	d.Accordion = true
+	d.SetItems(append(d.Items, DropdownItem{Text: "Quit", Action: "Quit", Enabled: true}))
+	d.ShadowEnabled = false
+	d.Show(0, 0)
+	assert.Equal(t, 3+2, d.Height)
+
+	// Expanding the parent pushes the following items down
+	d.MoveDown()
+	assert.True(t, d.ToggleExpanded())
+	assert.False(t, sub.IsVisible())
+	assert.Equal(t, 5+2, d.Height)
+	cells := d.RenderToCells(30, 10)
+	row := func(y int) string {
+		s := ""
+		for _, c := range cells[y][:d.Width] {
+			s += string(c.Rune)
+		}
+		return s
+	}
+	assert.Equal(t, "│ Recent Files ▼ │", row(2))
+	assert.Equal(t, "│   First        │", row(3))
+	assert.Equal(t, "│   Second       │", row(4))
+	assert.Equal(t, "│ Quit           │", row(5))
+
+	// The inline items are navigated like the others
+	d.MoveDown()
+	d.MoveDown()
+	if item := d.GetActiveItem(); assert.NotNil(t, item) {
+		assert.Equal(t, "Second", item.Action)
+	}
+	d.MoveDown()
+	assert.Equal(t, 2, d.Active)
+	d.MoveUp()
+	assert.Equal(t, "Second", d.GetActiveItem().Action)
+
+	// Collapsing from an inline item highlights its parent again
+	assert.True(t, d.CollapseSubmenu())
+	assert.Equal(t, 1, d.Active)
+	assert.Equal(t, "Recent", d.GetActiveItem().Action)
+	assert.Equal(t, 3+2, d.Height)
+	assert.False(t, d.CollapseSubmenu())
+
+	// Clicking the parent toggles it and clicking an inline item chooses it
+	assert.Nil(t, d.HandleClick(2, 2))
+	assert.True(t, d.Items[1].Expanded)
+	d.RenderToCells(30, 10)
+	if item := d.HandleClick(4, 3); assert.NotNil(t, item) {
+		assert.Equal(t, "First", item.Action)
+	}
+	assert.False(t, d.IsVisible())
+}
+
+func TestHomeEndPaging(t *testing.T) {
+	initTestScreen(t, 40, 8)
+	items := make([]DropdownItem, 20)
+	for i := range items {
+		items[i] = DropdownItem{Text: string(rune('a' + i)), Action: string(rune('A' + i)), Enabled: true}
+	}
+	items[0].Enabled = false
+	items[17].Enabled = false
+	items[18] = DropdownItem{Separator: true}
+	items[19] = DropdownItem{Separator: true}
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.SetItems(items)
+	d.Show(0, 1)
+	d.Display()
+	assert.Equal(t, 5, d.visibleRows())
+
+	// End skips the trailing separators and the disabled item before them,
+	// which are still scrolled into view
+	d.MoveToLast()
+	assert.Equal(t, 16, d.Active)
+	assert.Equal(t, 15, d.scrollOffset)
+
+	// Home skips the disabled first item
+	d.MoveToFirst()
+	assert.Equal(t, 1, d.Active)
+	assert.Equal(t, 0, d.scrollOffset)
+
+	// Paging moves by the visible rows and stops at the edges
+	d.MovePageDown()
+	assert.Equal(t, 6, d.Active)
+	assert.Equal(t, 2, d.scrollOffset)
+	d.MovePageDown()
+	d.MovePageDown()
+	assert.Equal(t, 16, d.Active)
+	d.MovePageDown()
+	assert.Equal(t, 16, d.Active)
+	d.MovePageUp()
+	assert.Equal(t, 11, d.Active)
+	d.MovePageUp()
+	d.MovePageUp()
+	assert.Equal(t, 1, d.Active)
+}
+
+func TestFormatItem(t *testing.T) {
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	items := []DropdownItem{
+		{Text: "Save", Action: "Save", Hotkey: 'S', Enabled: true, Shortcut: "Ctrl-s"},
+		{Text: "Open", Action: "Open", Hotkey: 'x', Enabled: true},
+	}
+
+	// The default formatter appends hotkeys missing from the text
+	left, right := d.DefaultFormatItem(items[1])
+	assert.Equal(t, "Open (x)", left)
+	assert.Equal(t, "", right)
+	d.HideMnemonics = true
+	left, _ = d.DefaultFormatItem(items[1])
+	assert.Equal(t, "Open", left)
+
+	// Hotkey labels replace the hotkey, and the dropdown is sized for them
+	d.HideMnemonics = false
+	palette := DropdownItem{Text: "Commands", Action: "Palette", Hotkey: 'C', HotkeyLabel: "Ctrl+Shift+P", Enabled: true}
+	left, _ = d.DefaultFormatItem(palette)
+	assert.Equal(t, "Commands (Ctrl+Shift+P)", left)
+	d.SetItems([]DropdownItem{palette})
+	assert.GreaterOrEqual(t, d.Width, len(left)+2) // +2 for the borders
+	d.HideMnemonics = true
+	left, _ = d.DefaultFormatItem(palette)
+	assert.Equal(t, "Commands", left)
+
+	// Custom formatters are used for sizing and drawing, and what they
+	// append to the text is dimmed
+	d.HideMnemonics = false
+	d.FormatItem = func(item DropdownItem) (string, string) {
+		return item.Text + " [beta]", "<" + item.Action + ">"
+	}
+	d.SetItems(items)
+	assert.Equal(t, 11+shortcutGap+6+4, d.Width)
+	d.Show(0, 0)
+	cells := d.RenderToCells(40, 10)
+	row := ""
+	for _, c := range cells[2][:d.Width] {
+		row += string(c.Rune)
+	}
+	assert.Equal(t, "│ Open [beta]  <Open> │", row)
+	_, _, attr := cells[2][7].Style.Decompose()
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrDim)
+	_, _, attr = cells[2][2].Style.Decompose()
+	assert.Equal(t, tcell.AttrNone, attr&tcell.AttrDim)
+}
+
+func TestHoverHighlight(t *testing.T) {
+	d, sub := newSubmenuDropdown()
+	d.SetItems(append([]DropdownItem{{Text: "New", Action: "New", Enabled: true}}, d.Items...))
+	d.ShadowEnabled = false
+	d.Show(0, 0)
+	d.RenderToCells(30, 10)
+
+	// Hovering highlights an item without moving the keyboard selection
+	d.HandleHover(2, 2)
+	assert.Equal(t, 0, d.Active)
+	assert.Equal(t, menuRow{1, -1}, d.hoverActive)
+	cells := d.RenderToCells(30, 10)
+	_, _, attr := cells[1][2].Style.Decompose()
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrReverse)
+	_, _, attr = cells[2][2].Style.Decompose()
+	assert.Equal(t, tcell.AttrNone, attr&tcell.AttrReverse)
+	assert.NotEqual(t, tcell.AttrNone, attr&tcell.AttrBold)
+
+	// Arrows move the keyboard selection, which Enter chooses
+	d.MoveDown()
+	assert.Equal(t, 1, d.Active)
+	assert.Equal(t, "Open", d.GetActiveItem().Action)
+
+	// The selection follows the pointer onto items with a submenu
+	d.HandleHover(2, 3)
+	assert.Equal(t, 2, d.Active)
+	assert.True(t, sub.IsVisible())
+
+	// Leaving the items clears the hover highlight
+	d.HandleHover(0, 0)
+	assert.Equal(t, menuRow{-1, -1}, d.hoverActive)
+}
+
+func TestItemStyle(t *testing.T) {
+	red := tcell.StyleDefault.Foreground(tcell.ColorRed)
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Quit", Action: "Quit", Enabled: true, Style: &red},
+		{Text: "Close", Action: "Close", Enabled: false, Style: &red},
+	})
+
+	// The item's colors are kept in every state
+	for _, active := range []bool{false, true} {
+		for i := range d.Items {
+			fg, _, _ := d.ResolvedStyle(i, active).Decompose()
+			assert.Equal(t, tcell.ColorRed, fg)
+		}
+	}
+	_, _, attr := d.ResolvedStyle(0, true).Decompose()
+	assert.Equal(t, tcell.AttrReverse, attr)
+	_, _, attr = d.ResolvedStyle(1, false).Decompose()
+	assert.Equal(t, tcell.AttrDim, attr)
+}
+
+func TestOpenUpward(t *testing.T) {
+	initTestScreen(t, 40, 12)
+	items := make([]DropdownItem, 8)
+	for i := range items {
+		items[i] = DropdownItem{Text: string(rune('a' + i)), Action: string(rune('A' + i)), Enabled: true}
+	}
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.flipOffset = 3
+	d.SetItems(items)
+
+	// Without room below the dropdown opens upward, ending below the anchor
+	d.Show(0, 7)
+	x, y, _, h := d.Bounds()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+	assert.Equal(t, 10, h)
+
+	// Clicks hit the upward dropdown even before it is drawn
+	if item := d.HandleClick(2, 1); assert.NotNil(t, item) {
+		assert.Equal(t, "A", item.Action)
+	}
+
+	// When neither side fits the larger one is chosen and scrolls
+	d.Show(0, 4)
+	_, y, _, h = d.Bounds()
+	assert.Equal(t, 4, y)
+	assert.Equal(t, 8, h)
+	d.Show(0, 5)
+	_, y, _, h = d.Bounds()
+	assert.Equal(t, 0, y)
+	assert.Equal(t, 8, h)
+	d.Display()
+	assert.Equal(t, 6, d.visibleRows())
+}
+
+func TestDeferredResize(t *testing.T) {
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{{Text: "a", Action: "A", Enabled: true}})
+	height := d.Height
+
+	// Appended items are only sized once invalidated
+	d.AppendItem(DropdownItem{Text: "A much longer item", Action: "B", Enabled: true})
+	d.AppendItem(DropdownItem{Text: "c", Action: "C", Enabled: true})
+	assert.Equal(t, height, d.Height)
+	d.Invalidate()
+	assert.Equal(t, height+2, d.Height)
+	assert.Equal(t, 18+4, d.Width)
+
+	// Or when shown if the caller forgets to
+	d.UpdateItems(func(items []DropdownItem) []DropdownItem {
+		return items[:1]
+	})
+	assert.Equal(t, height+2, d.Height)
+	d.ShadowEnabled = false
+	d.Show(0, 0)
+	cells := d.RenderToCells(30, 10)
+	assert.Equal(t, height, d.Height)
+	assert.Equal(t, '└', cells[height-1][0].Rune)
+}
+
+func TestSortItems(t *testing.T) {
+	texts := func(d *DropdownMenu) []string {
+		var s []string
+		for _, item := range d.Items {
+			if item.Separator {
+				s = append(s, "--"+item.Text)
+			} else {
+				s = append(s, item.Text)
+			}
+		}
+		return s
+	}
+	items := func() []DropdownItem {
+		return []DropdownItem{
+			{Text: "New", Action: "new", Enabled: true, Pinned: true},
+			{Separator: true},
+			{Text: "zeta.go", Action: "z", Enabled: true},
+			{Text: "Alpha.md", Action: "a", Enabled: true},
+			{Text: "beta.go", Action: "b", Enabled: true},
+		}
+	}
+
+	// Separators are kept as given without sorting
+	d := NewDropdownMenu()
+	d.SetItems(items())
+	assert.Equal(t, []string{"New", "--", "zeta.go", "Alpha.md", "beta.go"}, texts(d))
+
+	// Sorting drops them and separates the pinned items again
+	d = NewDropdownMenu()
+	d.SortMode = SortAlpha
+	d.SetItems(items())
+	assert.Equal(t, []string{"New", "--", "Alpha.md", "beta.go", "zeta.go"}, texts(d))
+
+	// Groups are labeled, and the custom order applies within them
+	d = NewDropdownMenu()
+	d.SortMode = SortCustom
+	d.Less = func(a, b DropdownItem) bool { return a.Text > b.Text }
+	d.GroupBy = func(item DropdownItem) string {
+		return item.Text[strings.LastIndex(item.Text, ".")+1:]
+	}
+	d.SetItems(items()[2:])
+	assert.Equal(t, []string{"--go", "zeta.go", "beta.go", "--md", "Alpha.md"}, texts(d))
+
+	// The selected item follows the sort
+	d = NewDropdownMenu()
+	d.SetItems(items())
+	d.Active = 2
+	d.SortMode = SortAlpha
+	d.Invalidate()
+	assert.Equal(t, "zeta.go", d.Items[d.Active].Text)
+	d.Invalidate()
+	assert.Equal(t, []string{"New", "--", "Alpha.md", "beta.go", "zeta.go"}, texts(d))
+}
+
+func TestPopulateFunc(t *testing.T) {
+	calls := 0
+	branches := []string{"main", "dev"}
+	d := NewDropdownMenu()
+	d.RememberSelection = true
+	d.PopulateFunc = func() []DropdownItem {
+		calls++
+		items := []DropdownItem{{Text: "Refresh", Enabled: false}}
+		for _, b := range branches {
+			items = append(items, DropdownItem{Text: b, Action: "checkout " + b, Enabled: true})
+		}
+		return items
+	}
+	assert.Equal(t, 0, calls)
+
+	d.Show(0, 0)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, d.Items, 3)
+	assert.Equal(t, 1, d.Active)
+	d.MoveDown()
+	assert.Equal(t, "checkout dev", d.Items[d.Active].Action)
+	d.Hide()
+
+	// The items are rebuilt when shown again and the remembered one is
+	// found where it moved to
+	branches = []string{"feature/menus", "main", "dev"}
+	d.Show(0, 0)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, d.Items, 4)
+	assert.Equal(t, "checkout dev", d.Items[d.Active].Action)
+	assert.Equal(t, len("feature/menus")+4, d.Width)
+	d.Hide()
+
+	// Or the first item is highlighted if it is gone
+	branches = []string{"feature/menus"}
+	d.Show(0, 0)
+	assert.Equal(t, "checkout feature/menus", d.Items[d.Active].Action)
+}
+
+func TestTruncateText(t *testing.T) {
+	path := "/home/user/src/micro/internal/display/dropdown.go"
+	assert.Equal(t, "short", truncateText("short", 5, TruncateEnd))
+	assert.Equal(t, "/home/user/src/micr…", truncateText(path, 20, TruncateEnd))
+	assert.Equal(t, "/home/u…/dropdown.go", truncateText(path, 20, TruncateMiddle))
+	assert.Equal(t, "Quite … text", truncateText("Quite a long items text", 12, TruncateMiddle))
+	assert.Equal(t, "dropd…wn.go", truncateText("dropdown.go/dropdown.go", 11, TruncateMiddle))
+	assert.Equal(t, path, truncateText(path, 20, TruncateNone))
+	assert.Equal(t, "文件…文件", truncateText("文件文件文件文件", 9, TruncateMiddle))
+
+	// Runes joined by a zero-width joiner are counted one by one, as they
+	// are drawn
+	assert.Equal(t, "a\U0001F469\u200d…", truncateText("a\U0001F469\u200d\U0001F4BBb", 4, TruncateEnd))
+}
+
+func TestWidthModes(t *testing.T) {
+	initTestScreen(t, 80, 24)
+
+	// A fixed width overrides the width fitting the items, shortening
+	// the texts that don't fit
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.SetItems([]DropdownItem{
+		{Text: "Open", Action: "a", Enabled: true},
+		{Text: "Open the selected file", Action: "b", Enabled: true},
+	})
+	auto := d.Width
+	d.SetFixedWidth(14)
+	d.Show(0, 0)
+	assert.Equal(t, 14, d.Width)
+	cells := d.RenderToCells(80, 24)
+	var b strings.Builder
+	for _, c := range cells[2][:d.Width] {
+		b.WriteRune(c.Rune)
+	}
+	assert.Equal(t, "│ Open the … │", b.String())
+	d.SetFixedWidth(30)
+	d.Invalidate()
+	assert.Equal(t, 30, d.Width)
+	d.SetFixedWidth(2)
+	d.Invalidate()
+	assert.Equal(t, d.MinWidth, d.Width)
+	d.WidthMode = WidthAuto
+	d.Invalidate()
+	assert.Equal(t, auto, d.Width)
+
+	// Submenus matching their parent are as wide as it and as the widest
+	// of them
+	short, long, own := NewDropdownMenu(), NewDropdownMenu(), NewDropdownMenu()
+	short.SetItems([]DropdownItem{{Text: "A", Action: "a", Enabled: true}})
+	long.SetItems([]DropdownItem{{Text: "A rather long submenu item", Action: "b", Enabled: true}})
+	own.SetItems([]DropdownItem{{Text: "B", Action: "c", Enabled: true}})
+	short.WidthMode = WidthMatchParent
+	long.WidthMode = WidthMatchParent
+	parent := NewDropdownMenu()
+	parent.SetItems([]DropdownItem{
+		{Text: "Short", Enabled: true, Submenu: short},
+		{Text: "Long", Enabled: true, Submenu: long},
+		{Text: "Own", Enabled: true, Submenu: own},
+	})
+	parent.Show(0, 0)
+	assert.True(t, short.Visible)
+	assert.Equal(t, long.autoWidth, short.Width)
+	assert.True(t, short.Width > parent.Width)
+	parent.MoveDown()
+	assert.True(t, long.Visible)
+	assert.Equal(t, short.Width, long.Width)
+	parent.MoveDown()
+	assert.True(t, own.Visible)
+	assert.True(t, own.Width < short.Width)
+
+	long.SetItems([]DropdownItem{{Text: "B", Action: "b", Enabled: true}})
+	parent.MoveUp()
+	parent.MoveUp()
+	assert.True(t, short.Visible)
+	assert.Equal(t, parent.Width, short.Width)
+}
+
+func TestTruncateItems(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.MaxWidth = 20
+	d.SetItems([]DropdownItem{
+		{Text: "/home/user/src/micro/main.go", Action: "a", Hotkey: 'x', Enabled: true, Shortcut: "F5"},
+		{Text: "Recent files and folders", Action: "b", Hotkey: 'f', Enabled: true, Submenu: NewDropdownMenu()},
+		{Text: "Short", Action: "c", Enabled: true},
+	})
+	assert.Equal(t, 20, d.Width)
+	d.Show(0, 0)
+	rows := func() []string {
+		cells := d.RenderToCells(80, 24)
+		var rows []string
+		for _, row := range cells[1:4] {
+			var b strings.Builder
+			for _, c := range row[:d.Width] {
+				b.WriteRune(c.Rune)
+			}
+			rows = append(rows, b.String())
+		}
+		return rows
+	}
+	// The texts are cut before the hotkey hints, shortcuts and submenu
+	// indicators, which are kept
+	assert.Equal(t, []string{
+		"│ /home/u… (x)  F5 │",
+		"│ Recent files … ▶ │",
+		"│ Short            │",
+	}, rows())
+
+	d.Truncate = TruncateMiddle
+	assert.Equal(t, []string{
+		"│ /hom….go (x)  F5 │",
+		"│ Recent …olders ▶ │",
+		"│ Short            │",
+	}, rows())
+
+	// Clipped texts run up to the shortcuts and submenu indicators
+	d.Truncate = TruncateNone
+	assert.Equal(t, []string{
+		"│ /home/user/s  F5 │",
+		"│ Recent files a ▶ │",
+		"│ Short            │",
+	}, rows())
+}
+
+func TestBusySpinner(t *testing.T) {
+	initTestScreen(t, 40, 10)
+	d := NewDropdownMenu()
+	d.ShadowEnabled = false
+	d.SetItems([]DropdownItem{
+		{Text: "Build", Action: "build", Enabled: true},
+		{Text: "Test", Action: "test", Enabled: true},
+	})
+	d.Show(0, 0)
+	idle := d.RenderToCells(40, 10)
+
+	// The spinner is drawn in the right padding, leaving the text alone
+	d.SetBusy("test", true)
+	busy := d.RenderToCells(40, 10)
+	assert.Equal(t, idle[1], busy[1])
+	assert.Contains(t, spinnerFrames, busy[2][d.Width-2].Rune)
+	assert.Equal(t, idle[2][:d.Width-2], busy[2][:d.Width-2])
+
+	// Redrawing while the spinner is shown keeps a single redraw pending
+	for i := 0; i < 5; i++ {
+		d.Display()
+	}
+	assert.True(t, spinnerPending.Load())
+	time.Sleep(2 * spinnerInterval)
+	assert.False(t, spinnerPending.Load())
+
+	// Async tasks may finish while the dropdown is drawn
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			d.SetBusy("test", i%2 == 0)
+		}
+		d.SetBusy("test", false)
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		d.RenderToCells(40, 10)
+	}
+	<-done
+	assert.Equal(t, idle, d.RenderToCells(40, 10))
+	d.Display()
+	assert.False(t, spinnerPending.Load())
+}
@@ -0,0 +1,76 @@
+package display
+
+import "errors"
+
+// MenuBatch groups changes to the menus so that they can be reverted all
+// at once, see MenuWindow.BeginBatch
+type MenuBatch struct {
+	w        *MenuWindow
+	snapshot *MenuWindow // copy of the menus when the batch began, nil once it is finished
+}
+
+// BeginBatch starts a batch of changes to the menus. Changes made through
+// the returned batch are applied right away, Rollback reverts the menus to
+// how they were when the batch began, for example when a plugin fails
+// halfway through setting up its menus, and Commit keeps the changes.
+// Changes made directly on the menu bar while the batch is open are
+// reverted along with it. Beginning a batch leaves an open menu open
+func (w *MenuWindow) BeginBatch() *MenuBatch {
+	return &MenuBatch{w: w, snapshot: w.Clone()}
+}
+
+// AddMenu adds a menu as part of the batch, see MenuWindow.AddMenu
+func (b *MenuBatch) AddMenu(item MenuItem, items []DropdownItem) {
+	b.w.AddMenu(item, items)
+}
+
+// RemoveMenu removes a menu as part of the batch, see
+// MenuWindow.RemoveMenu
+func (b *MenuBatch) RemoveMenu(menuAction string) error {
+	return b.w.RemoveMenu(menuAction)
+}
+
+// SetMenuEnabled enables or disables a menu as part of the batch, see
+// MenuWindow.SetMenuEnabled
+func (b *MenuBatch) SetMenuEnabled(menuAction string, enabled bool) {
+	b.w.SetMenuEnabled(menuAction, enabled)
+}
+
+// AddDropdownItem adds an item as part of the batch, see
+// MenuWindow.AddDropdownItem
+func (b *MenuBatch) AddDropdownItem(menuAction string, item DropdownItem) error {
+	return b.w.AddDropdownItem(menuAction, item)
+}
+
+// RemoveDropdownItem removes an item as part of the batch, see
+// MenuWindow.RemoveDropdownItem
+func (b *MenuBatch) RemoveDropdownItem(menuAction, itemAction string) error {
+	return b.w.RemoveDropdownItem(menuAction, itemAction)
+}
+
+// Commit keeps the changes of the batch and finishes it
+func (b *MenuBatch) Commit() error {
+	if b.snapshot == nil {
+		return errors.New("Menu batch already finished")
+	}
+	b.snapshot = nil
+	return nil
+}
+
+// Rollback reverts the menus to how they were when the batch began and
+// finishes it. Any open menu is closed
+func (b *MenuBatch) Rollback() error {
+	if b.snapshot == nil {
+		return errors.New("Menu batch already finished")
+	}
+	w, s := b.w, b.snapshot
+	w.SetActive(-1)
+	w.SetOpen(false)
+	w.MenuItems = s.MenuItems
+	w.dropdownMenus = s.dropdownMenus
+	w.EnabledFuncs = s.EnabledFuncs
+	w.overflowMenu = nil
+	w.indexActions()
+	b.snapshot = nil
+	return nil
+}
@@ -0,0 +1,77 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// renderMenus renders every menu of the menu bar opened in turn
+func renderMenus(w *MenuWindow) [][][]Cell {
+	var renders [][][]Cell
+	for _, m := range w.MenuItems {
+		w.OpenMenu(m.Action)
+		renders = append(renders, w.RenderToCells(80, 24))
+	}
+	w.CloseAll()
+	return renders
+}
+
+func TestMenuBatchRollback(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	before := renderMenus(w)
+	items := append([]MenuItem(nil), w.MenuItems...)
+
+	b := w.BeginBatch()
+	b.AddMenu(MenuItem{Name: "Plugin", Action: "plugin", Enabled: true}, []DropdownItem{
+		{Text: "Run", Action: "run", Enabled: true},
+	})
+	assert.NoError(t, b.AddDropdownItem("file", DropdownItem{Text: "Extra", Action: "extra", Enabled: true}))
+	assert.NoError(t, b.RemoveDropdownItem("edit", "Undo"))
+	assert.NoError(t, b.RemoveMenu("help"))
+	b.SetMenuEnabled("view", false)
+	assert.Error(t, b.RemoveMenu("nonexistent"))
+	assert.NotEqual(t, before, renderMenus(w))
+
+	assert.NoError(t, b.Rollback())
+	assert.Equal(t, items, w.MenuItems)
+	assert.Equal(t, before, renderMenus(w))
+	assert.Empty(t, w.SearchActions("run"))
+	assert.NotEmpty(t, w.SearchActions("undo"))
+
+	// A finished batch can't be finished again
+	assert.Error(t, b.Rollback())
+	assert.Error(t, b.Commit())
+}
+
+func TestMenuBatchCommit(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	b := w.BeginBatch()
+	b.AddMenu(MenuItem{Name: "Plugin", Action: "plugin", Enabled: true}, nil)
+	assert.NoError(t, b.Commit())
+	assert.GreaterOrEqual(t, w.menuIndex("plugin"), 0)
+	assert.Error(t, b.Rollback())
+	assert.GreaterOrEqual(t, w.menuIndex("plugin"), 0)
+}
+
+func TestMenuBatchWithOpenMenu(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	var events []string
+	w.OnMenuOpen = func(action string) { events = append(events, "open "+action) }
+	w.OnMenuClose = func(action string) { events = append(events, "close "+action) }
+	w.OpenMenu("file")
+
+	// Beginning a batch leaves the open menu alone
+	b := w.BeginBatch()
+	assert.Equal(t, []string{"open file"}, events)
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "file", w.GetMenuAction())
+	assert.NoError(t, b.AddDropdownItem("file", DropdownItem{Text: "Extra", Action: "extra", Enabled: true}))
+
+	// Rolling it back closes the menu once
+	assert.NoError(t, b.Rollback())
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, []string{"open file", "close file"}, events)
+}
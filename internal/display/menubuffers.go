@@ -0,0 +1,95 @@
+package display
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WindowMenuAction is the action of the menu listing the open buffers, see
+// SetBufferList
+const WindowMenuAction = "window"
+
+// bufferRadioGroup is the radio group of the items of the open buffers,
+// the active buffer's item is checked
+const bufferRadioGroup = "buffers"
+
+// bufferNameWidth is the width buffer names are shortened to, from the left
+// so that the file name stays visible
+const bufferNameWidth = 40
+
+// SetBufferList lists the given buffer names in the Window menu, which is
+// added to the menu bar the first time. The buffer with the given index is
+// marked as the active one, and choosing an item runs the switchbuffer
+// command with its number. Names are shortened to their file name, with as
+// many parent directories as needed to tell identical file names apart
+func (w *MenuWindow) SetBufferList(names []string, activeIndex int) {
+	labels := bufferLabels(names)
+	items := make([]DropdownItem, 0, len(names))
+	for i, name := range names {
+		items = append(items, DropdownItem{
+			Text:        labels[i],
+			Action:      "switchbuffer " + strconv.Itoa(i+1),
+			Enabled:     true,
+			Description: name,
+			RadioGroup:  bufferRadioGroup,
+			Checked:     i == activeIndex,
+		})
+	}
+	if len(items) == 0 {
+		items = append(items, DropdownItem{Text: "(no open files)"})
+	}
+
+	dropdown, exists := w.dropdownMenus[WindowMenuAction]
+	if !exists {
+		w.AddMenu(MenuItem{Name: "Window", Action: WindowMenuAction, Hotkey: 'n', Enabled: true}, items)
+		return
+	}
+	dropdown.SetItems(items)
+	if dropdown.Active >= len(items) {
+		dropdown.Active = -1
+	}
+	w.indexActions()
+}
+
+// bufferLabels returns the labels of the given buffer names: their last
+// path element, extended by parent directories for as long as several
+// names have the same label, and shortened to bufferNameWidth
+func bufferLabels(names []string) []string {
+	parts := make([][]string, len(names))
+	depth := make([]int, len(names))
+	for i, name := range names {
+		parts[i] = strings.Split(filepath.ToSlash(name), "/")
+		depth[i] = 1
+	}
+	label := func(i int) string {
+		p := parts[i]
+		return filepath.FromSlash(strings.Join(p[len(p)-depth[i]:], "/"))
+	}
+
+	for grown := true; grown; {
+		grown = false
+		same := make(map[string][]int)
+		for i := range names {
+			l := label(i)
+			same[l] = append(same[l], i)
+		}
+		for _, indices := range same {
+			if len(indices) < 2 {
+				continue
+			}
+			for _, i := range indices {
+				if depth[i] < len(parts[i]) {
+					depth[i]++
+					grown = true
+				}
+			}
+		}
+	}
+
+	labels := make([]string, len(names))
+	for i := range names {
+		labels[i] = truncateLeftWidth(label(i), bufferNameWidth)
+	}
+	return labels
+}
@@ -0,0 +1,47 @@
+package display
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetBufferList(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	menus := len(w.MenuItems)
+
+	// The Window menu is added the first time
+	w.SetBufferList([]string{"/home/a/main.go", "/home/a/README.md"}, 1)
+	assert.Len(t, w.MenuItems, menus+1)
+	items := w.dropdownMenus[WindowMenuAction].Items
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "main.go", items[0].Text)
+		assert.Equal(t, "switchbuffer 1", items[0].Action)
+		assert.Equal(t, "/home/a/main.go", items[0].Description)
+		assert.False(t, items[0].Checked)
+		assert.True(t, items[1].Checked)
+		assert.Equal(t, bufferRadioGroup, items[1].RadioGroup)
+	}
+
+	// And rebuilt afterwards
+	w.SetBufferList(nil, -1)
+	assert.Len(t, w.MenuItems, menus+1)
+	items = w.dropdownMenus[WindowMenuAction].Items
+	if assert.Len(t, items, 1) {
+		assert.False(t, items[0].Enabled)
+	}
+}
+
+func TestBufferLabels(t *testing.T) {
+	// Identical file names show as much of their path as tells them apart
+	assert.Equal(t, []string{"a/x/main.go", "b/x/main.go", "util.go", "No name"},
+		bufferLabels([]string{"/src/a/x/main.go", "/src/b/x/main.go", "/src/a/util.go", "No name"}))
+
+	// Identical paths can't be told apart
+	assert.Equal(t, []string{"/a.txt", "/a.txt"}, bufferLabels([]string{"/a.txt", "/a.txt"}))
+
+	// Long names are shortened from the left
+	long := strings.Repeat("d", 50) + ".txt"
+	assert.Equal(t, []string{"…" + long[len(long)-39:]}, bufferLabels([]string{"/x/" + long}))
+}
@@ -0,0 +1,256 @@
+package display
+
+import (
+	"errors"
+	"log"
+	"os"
+	"unicode/utf8"
+
+	"github.com/micro-editor/json5"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+// menuConfig is the definition of a top-level menu in a menu config file
+type menuConfig struct {
+	Name      string           `json:"name"`
+	Action    string           `json:"action"`
+	Hotkey    string           `json:"hotkey,omitempty"`
+	Enabled   *bool            `json:"enabled,omitempty"`
+	Align     string           `json:"align,omitempty"`
+	Columns   int              `json:"columns,omitempty"`
+	Accordion bool             `json:"accordion,omitempty"`
+	Items     []menuItemConfig `json:"items"`
+}
+
+// menuItemConfig is the definition of a dropdown item in a menu config file
+type menuItemConfig struct {
+	Text             string           `json:"text,omitempty"`
+	Action           string           `json:"action,omitempty"`
+	AltAction        string           `json:"altaction,omitempty"`
+	Hotkey           string           `json:"hotkey,omitempty"`
+	HotkeyLabel      string           `json:"hotkeylabel,omitempty"`
+	Icon             string           `json:"icon,omitempty"`
+	Shortcut         string           `json:"shortcut,omitempty"`
+	URL              string           `json:"url,omitempty"`
+	Prompt           string           `json:"prompt,omitempty"`
+	Description      string           `json:"description,omitempty"`
+	DisabledReason   string           `json:"disabledreason,omitempty"`
+	SuccessMessage   string           `json:"successmessage,omitempty"`
+	Style            string           `json:"style,omitempty"`
+	Enabled          *bool            `json:"enabled,omitempty"`
+	Separator        bool             `json:"separator,omitempty"`
+	Checkable        bool             `json:"checkable,omitempty"`
+	Checked          bool             `json:"checked,omitempty"`
+	RadioGroup       string           `json:"radiogroup,omitempty"`
+	KeepOpenOnSelect bool             `json:"keepopen,omitempty"`
+	Repeatable       bool             `json:"repeatable,omitempty"`
+	Pinned           bool             `json:"pinned,omitempty"`
+	Submenu          bool             `json:"submenu,omitempty"`
+	Columns          int              `json:"columns,omitempty"`
+	Accordion        bool             `json:"accordion,omitempty"`
+	Items            []menuItemConfig `json:"items,omitempty"`
+}
+
+// parseRune converts a single character field such as a hotkey or an icon
+// from a menu config file to a rune
+func parseRune(field, s string) (rune, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, errors.New(field + " must be a single character: " + s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
+
+// buildDropdown creates a dropdown menu from its item definitions, laid out
+// in up to the given number of columns, or as an accordion
+func buildDropdown(items []menuItemConfig, columns int, accordion bool) (*DropdownMenu, error) {
+	dropdownItems := make([]DropdownItem, 0, len(items))
+	for _, ic := range items {
+		if ic.Separator {
+			dropdownItems = append(dropdownItems, DropdownItem{Separator: true, Text: ic.Text})
+			continue
+		}
+		if ic.Text == "" {
+			return nil, errors.New("menu item is missing its text")
+		}
+		hotkey, err := parseRune("hotkey", ic.Hotkey)
+		if err != nil {
+			return nil, err
+		}
+		icon, err := parseRune("icon", ic.Icon)
+		if err != nil {
+			return nil, err
+		}
+		item := DropdownItem{
+			Text:             ic.Text,
+			Action:           ic.Action,
+			AltAction:        ic.AltAction,
+			Hotkey:           hotkey,
+			HotkeyLabel:      ic.HotkeyLabel,
+			Icon:             icon,
+			Enabled:          ic.Enabled == nil || *ic.Enabled,
+			Shortcut:         ic.Shortcut,
+			URL:              ic.URL,
+			Prompt:           ic.Prompt,
+			Description:      ic.Description,
+			DisabledReason:   ic.DisabledReason,
+			SuccessMessage:   ic.SuccessMessage,
+			Checkable:        ic.Checkable,
+			Checked:          ic.Checked,
+			RadioGroup:       ic.RadioGroup,
+			KeepOpenOnSelect: ic.KeepOpenOnSelect,
+			Repeatable:       ic.Repeatable,
+			Pinned:           ic.Pinned,
+		}
+		if ic.Style != "" {
+			style := config.StringToStyle(ic.Style)
+			item.Style = &style
+		}
+		if item.URL != "" && item.Action == "" {
+			item.Action = "OpenURL"
+		}
+		if len(ic.Items) > 0 || ic.Submenu {
+			item.Submenu, err = buildDropdown(ic.Items, ic.Columns, ic.Accordion)
+			if err != nil {
+				return nil, err
+			}
+		} else if item.Action == "" && item.Enabled {
+			return nil, errors.New("menu item " + ic.Text + " is missing its action")
+		}
+		dropdownItems = append(dropdownItems, item)
+	}
+
+	dropdown := NewDropdownMenu()
+	dropdown.MaxColumns = columns
+	dropdown.Accordion = accordion
+	dropdown.SetItems(dropdownItems)
+	return dropdown, nil
+}
+
+// LoadMenuConfig replaces the menus with the ones defined in the given
+// JSON file, which holds a list of menus such as
+//
+//	[
+//	    {"name": "File", "action": "file", "hotkey": "i", "items": [
+//	        {"text": "Save", "action": "Save", "hotkey": "S", "shortcut": "Ctrl-s"},
+//	        {"separator": true},
+//	        {"text": "Quit", "action": "Quit", "hotkey": "Q"}
+//	    ]}
+//	]
+//
+// Items with nested "items" become submenus, and menus with "align" set to
+// "right" are pinned to the right edge of the menu bar. Menus and submenus
+// with "columns" set lay long lists of items out in up to that many
+// columns, and the ones with "accordion" set show their submenus inline
+// below their parent item when it is chosen. Items with "style" set, in
+// the format of colorscheme entries such as "bold red", are drawn with
+// those colors, and the "altaction" of items is run instead of their
+// action when they are middle-clicked or clicked with a modifier held.
+// Items with "hotkeylabel" set show it instead of their hotkey, such as
+// "Ctrl+Shift+P" for items run by a key chord. Items can also be made
+// "checkable" or put in a "radiogroup", with "checked" set initially, and
+// "keepopen" leaves the menu open when they are chosen. Disabled items,
+// such as placeholders, need no action, and items with "submenu" set open
+// a submenu even without items. If the file cannot be read or is invalid
+// an error is returned and the current menus are kept. Menus and items
+// without a hotkey are assigned one, and actions rejected by ValidAction
+// and conflicting hotkeys are logged as warnings
+func (w *MenuWindow) LoadMenuConfig(path string) error {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return errors.New("Error reading menu config: " + err.Error())
+	}
+
+	var menus []menuConfig
+	if err := json5.Unmarshal(input, &menus); err != nil {
+		return errors.New("Error parsing menu config: " + err.Error())
+	}
+	if err := w.setMenuConfig(menus); err != nil {
+		return errors.New("Error in menu config: " + err.Error())
+	}
+
+	if w.ValidAction != nil {
+		for _, m := range w.MenuItems {
+			warnUnknownActions(w.dropdownMenus[m.Action], w.ValidAction)
+		}
+	}
+
+	w.AutoAssignHotkeys()
+	for _, err := range w.ValidateHotkeys() {
+		log.Println("Warning:", err)
+	}
+	return nil
+}
+
+// setMenuConfig replaces the menus with the given definitions, or returns
+// an error and keeps the current menus if they are invalid
+func (w *MenuWindow) setMenuConfig(menus []menuConfig) error {
+	menuItems := make([]MenuItem, 0, len(menus))
+	dropdownMenus := make(map[string]*DropdownMenu)
+	for _, mc := range menus {
+		if mc.Name == "" || mc.Action == "" {
+			return errors.New("menus need a name and an action")
+		}
+		if _, exists := dropdownMenus[mc.Action]; exists {
+			return errors.New("duplicate menu " + mc.Action)
+		}
+		hotkey, err := parseRune("hotkey", mc.Hotkey)
+		if err != nil {
+			return err
+		}
+		var align MenuAlign
+		switch mc.Align {
+		case "", "left":
+			align = AlignLeft
+		case "right":
+			align = AlignRight
+		default:
+			return errors.New("invalid align " + mc.Align)
+		}
+		dropdown, err := buildDropdown(mc.Items, mc.Columns, mc.Accordion)
+		if err != nil {
+			return err
+		}
+		menuItems = append(menuItems, MenuItem{
+			Name:    mc.Name,
+			Action:  mc.Action,
+			Hotkey:  hotkey,
+			Enabled: mc.Enabled == nil || *mc.Enabled,
+			Align:   align,
+		})
+		dropdownMenus[mc.Action] = dropdown
+	}
+
+	w.SetActive(-1)
+	w.SetOpen(false)
+	w.MenuItems = menuItems
+	w.dropdownMenus = dropdownMenus
+	w.overflowMenu = nil
+	if err := w.validateNoCycles(); err != nil {
+		log.Println("Warning:", err)
+	}
+	w.SetWrapNavigation(w.WrapNavigation)
+	w.indexActions()
+	return nil
+}
+
+// warnUnknownActions logs a warning for every item of the dropdown and its
+// submenus whose action is not valid
+func warnUnknownActions(d *DropdownMenu, valid func(string) bool) {
+	for _, item := range d.Items {
+		if item.Separator {
+			continue
+		}
+		if item.Submenu != nil {
+			warnUnknownActions(item.Submenu, valid)
+		} else if !valid(item.Action) {
+			log.Println("Warning: menu item", item.Text, "has unknown action", item.Action)
+		}
+		if item.AltAction != "" && !valid(item.AltAction) {
+			log.Println("Warning: menu item", item.Text, "has unknown action", item.AltAction)
+		}
+	}
+}
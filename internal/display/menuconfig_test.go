@@ -0,0 +1,64 @@
+package display
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+func writeMenuConfig(t *testing.T, text string) string {
+	path := filepath.Join(t.TempDir(), "menus.json")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMenuConfig(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	path := writeMenuConfig(t, `[
+		{"name": "Tools", "action": "tools", "hotkey": "t", "items": [
+			{"text": "Format", "action": "Format", "hotkey": "F", "style": "red", "altaction": "FormatAll", "hotkeylabel": "Ctrl+Alt+F"},
+			{"separator": true},
+			{"text": "More", "items": [
+				{"text": "Lint", "action": "Lint", "enabled": false}
+			]}
+		]},
+		{"name": "File", "action": "file", "hotkey": "i", "items": []}
+	]`)
+
+	assert.NoError(t, w.LoadMenuConfig(path))
+	assert.Len(t, w.MenuItems, 2)
+	assert.Equal(t, "Tools", w.MenuItems[0].Name)
+	assert.Equal(t, 't', w.MenuItems[0].Hotkey)
+	assert.True(t, w.MenuItems[0].Enabled)
+
+	tools := w.dropdownMenus["tools"]
+	assert.Len(t, tools.Items, 3)
+	assert.Equal(t, 'F', tools.Items[0].Hotkey)
+	if assert.NotNil(t, tools.Items[0].Style) {
+		assert.Equal(t, config.StringToStyle("red"), *tools.Items[0].Style)
+	}
+	assert.Nil(t, tools.Items[1].Style)
+	assert.Equal(t, "FormatAll", tools.Items[0].AltAction)
+	assert.Equal(t, "Ctrl+Alt+F", tools.Items[0].HotkeyLabel)
+	assert.True(t, tools.Items[1].Separator)
+	assert.NotNil(t, tools.Items[2].Submenu)
+	assert.False(t, tools.Items[2].Submenu.Items[0].Enabled)
+}
+
+func TestLoadMenuConfigInvalid(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	names := len(w.MenuItems)
+
+	path := writeMenuConfig(t, `[{"name": "Tools", "action": "tools", "hotkey": "too long"}]`)
+	assert.Error(t, w.LoadMenuConfig(path))
+	assert.Error(t, w.LoadMenuConfig(filepath.Join(t.TempDir(), "missing.json")))
+
+	// The built-in menus are kept
+	assert.Len(t, w.MenuItems, names)
+	assert.Equal(t, "File", w.MenuItems[0].Name)
+}
@@ -0,0 +1,118 @@
+package display
+
+import "time"
+
+// hoverDwell tracks how long the pointer has rested on a menu of the bar or
+// an item of a dropdown, see MenuWindow.HoverDelayMs
+type hoverDwell struct {
+	pending bool      // whether the pointer rests on a target that isn't open yet
+	target  int       // index of the menu or item the pointer rests on
+	since   time.Time // when the pointer moved onto the target
+}
+
+// rested returns whether the pointer has rested on the target for the
+// given delay, starting to time it if it just moved onto the target
+func (h *hoverDwell) rested(target, delayMs int) bool {
+	if delayMs <= 0 {
+		h.pending = false
+		return true
+	}
+	if !h.pending || h.target != target {
+		h.pending, h.target, h.since = true, target, time.Now()
+		return false
+	}
+	return h.wait(delayMs) == 0
+}
+
+// wait returns the time left until the pointer has rested on the target
+// for the given delay, 0 if it has or nothing is pending
+func (h *hoverDwell) wait(delayMs int) time.Duration {
+	if !h.pending {
+		return 0
+	}
+	if left := time.Duration(delayMs)*time.Millisecond - time.Since(h.since); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// reset forgets the target, so that the pointer has to rest on it anew
+func (h *hoverDwell) reset() {
+	h.pending = false
+}
+
+// HoverWait returns how long until the pointer will have rested long
+// enough on a menu or an item with a submenu to open it, or 0 if it
+// doesn't rest on any. The event loop should call HoverStep after that
+// time, since no further mouse events may come while the pointer rests
+func (w *MenuWindow) HoverWait() time.Duration {
+	if !w.open {
+		return 0
+	}
+	if w.dwell.pending {
+		return maxDuration(w.dwell.wait(w.HoverDelayMs), time.Millisecond)
+	}
+	for d := w.GetActiveDropdown(); d != nil && d.IsVisible(); d = d.ActiveSubmenu() {
+		if d.dwell.pending {
+			return maxDuration(d.dwell.wait(d.HoverDelayMs), time.Millisecond)
+		}
+	}
+	return 0
+}
+
+// HoverStep opens the menu or submenu the pointer has rested on for long
+// enough, see HoverWait. It returns whether one was opened
+func (w *MenuWindow) HoverStep() bool {
+	if !w.open {
+		return false
+	}
+	if w.dwell.pending {
+		if w.dwell.wait(w.HoverDelayMs) > 0 {
+			return false
+		}
+		w.switchOnHover(w.dwell.target)
+		return true
+	}
+	for d := w.GetActiveDropdown(); d != nil && d.IsVisible(); d = d.ActiveSubmenu() {
+		if d.dwell.pending {
+			if d.dwell.wait(d.HoverDelayMs) > 0 {
+				return false
+			}
+			d.openHoveredSubmenu(d.dwell.target)
+			return true
+		}
+	}
+	return false
+}
+
+// switchOnHover opens the menu with the given index, which the pointer
+// moved onto or rested on while another menu was open
+func (w *MenuWindow) switchOnHover(index int) {
+	w.dwell.reset()
+	if index == w.Active || w.layout()[index] < 0 {
+		return
+	}
+	w.keyboardFocus = false
+	w.SetActive(index)
+	w.SetOpen(true)
+}
+
+// openHoveredSubmenu opens the submenu of the item with the given index,
+// which the pointer moved onto or rested on
+func (d *DropdownMenu) openHoveredSubmenu(index int) {
+	d.dwell.reset()
+	if index < 0 || index >= len(d.Items) || d.Items[index].Submenu == nil || index == d.Active {
+		return
+	}
+	d.Active = index
+	d.submenuFocused = false
+	d.syncSubmenu()
+}
+
+// maxDuration returns the longer of the two durations
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,146 @@
+package display
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+// MarshalJSON encodes the menus and their items, including the ones added
+// by plugins, in the format of menu config files, see LoadMenuConfig, so
+// that they can be saved with the session. Which menu is open and the
+// functions of the items, such as their StateFunc, are not saved
+func (w *MenuWindow) MarshalJSON() ([]byte, error) {
+	menus := make([]menuConfig, 0, len(w.MenuItems))
+	for _, m := range w.MenuItems {
+		mc := menuConfig{
+			Name:   m.Name,
+			Action: m.Action,
+			Items:  []menuItemConfig{},
+		}
+		if m.Hotkey != 0 {
+			mc.Hotkey = string(m.Hotkey)
+		}
+		if !m.Enabled {
+			mc.Enabled = new(bool)
+		}
+		if m.Align == AlignRight {
+			mc.Align = "right"
+		}
+		if dropdown, exists := w.dropdownMenus[m.Action]; exists {
+			mc.Items = itemConfigs(dropdown)
+			mc.Columns = dropdown.MaxColumns
+			mc.Accordion = dropdown.Accordion
+		}
+		menus = append(menus, mc)
+	}
+	return json.Marshal(menus)
+}
+
+// UnmarshalJSON replaces the menus with the ones encoded by MarshalJSON.
+// The menu bar should have been created with NewMenuWindow. If the menus
+// are invalid an error is returned and the current menus are kept
+func (w *MenuWindow) UnmarshalJSON(data []byte) error {
+	var menus []menuConfig
+	if err := json.Unmarshal(data, &menus); err != nil {
+		return err
+	}
+	if err := w.setMenuConfig(menus); err != nil {
+		return errors.New("Error restoring menus: " + err.Error())
+	}
+	return nil
+}
+
+// itemConfigs returns the definitions of the items of the dropdown and its
+// submenus, the reverse of buildDropdown
+func itemConfigs(d *DropdownMenu) []menuItemConfig {
+	items := make([]menuItemConfig, 0, len(d.Items))
+	for _, item := range d.Items {
+		if item.Separator {
+			items = append(items, menuItemConfig{Text: item.Text, Separator: true})
+			continue
+		}
+		ic := menuItemConfig{
+			Text:             item.Text,
+			Action:           item.Action,
+			AltAction:        item.AltAction,
+			HotkeyLabel:      item.HotkeyLabel,
+			Shortcut:         item.Shortcut,
+			URL:              item.URL,
+			Prompt:           item.Prompt,
+			Description:      item.Description,
+			DisabledReason:   item.DisabledReason,
+			SuccessMessage:   item.SuccessMessage,
+			Checkable:        item.Checkable,
+			Checked:          item.Checked,
+			RadioGroup:       item.RadioGroup,
+			KeepOpenOnSelect: item.KeepOpenOnSelect,
+			Repeatable:       item.Repeatable,
+			Pinned:           item.Pinned,
+		}
+		if item.Hotkey != 0 {
+			ic.Hotkey = string(item.Hotkey)
+		}
+		if item.Icon != 0 {
+			ic.Icon = string(item.Icon)
+		}
+		if !item.Enabled {
+			ic.Enabled = new(bool)
+		}
+		if item.Style != nil {
+			ic.Style = styleString(*item.Style)
+		}
+		if item.Submenu != nil {
+			ic.Items = itemConfigs(item.Submenu)
+			ic.Submenu = len(ic.Items) == 0
+			ic.Columns = item.Submenu.MaxColumns
+			ic.Accordion = item.Submenu.Accordion
+		}
+		items = append(items, ic)
+	}
+	return items
+}
+
+// styleString returns the style in the format of colorscheme entries, the
+// reverse of config.StringToStyle
+func styleString(style tcell.Style) string {
+	fg, bg, attr := style.Decompose()
+	var words []string
+	for _, a := range []struct {
+		mask tcell.AttrMask
+		name string
+	}{
+		{tcell.AttrBold, "bold"},
+		{tcell.AttrItalic, "italic"},
+		{tcell.AttrReverse, "reverse"},
+		{tcell.AttrUnderline, "underline"},
+	} {
+		if attr&a.mask != 0 {
+			words = append(words, a.name)
+		}
+	}
+	defFg, defBg, _ := config.DefStyle.Decompose()
+	words = append(words, colorString(fg, defFg)+","+colorString(bg, defBg))
+	return strings.Join(words, " ")
+}
+
+// colorString returns the color in the format of colorscheme entries, as
+// "default" if it is the given default color
+func colorString(c, def tcell.Color) string {
+	switch {
+	case c == def || c == tcell.ColorDefault:
+		return "default"
+	case c == tcell.ColorBlack:
+		return "black"
+	case c.IsRGB():
+		return fmt.Sprintf("#%06x", c.Hex())
+	case c >= tcell.ColorValid && c < tcell.ColorValid+256:
+		return strconv.Itoa(int(c - tcell.ColorValid))
+	}
+	return "default"
+}
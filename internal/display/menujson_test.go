@@ -0,0 +1,63 @@
+package display
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+func TestMenuJSON(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	style := config.DefStyle.Foreground(tcell.ColorMaroon).Background(tcell.NewRGBColor(0x10, 0x20, 0x30)).Bold(true)
+	sub := NewDropdownMenu()
+	sub.MaxColumns = 2
+	w.AddMenu(MenuItem{Name: "Plugin", Action: "plugin", Hotkey: 'p', Enabled: true, Align: AlignRight}, []DropdownItem{
+		{Text: "Run", Action: "lua:plugin.run", AltAction: "lua:plugin.runall", Hotkey: 'R', Icon: '▶', Enabled: true, Style: &style},
+		{Separator: true, Text: "Options"},
+		{Text: "Verbose", Action: "set verbose", Enabled: true, Checkable: true, Checked: true, KeepOpenOnSelect: true},
+		{Text: "Later", Enabled: true, Submenu: sub},
+		{Text: "(nothing yet)"},
+	})
+	w.SetMenuEnabled("help", false)
+	w.OpenMenu("file")
+
+	data, err := json.Marshal(w)
+	assert.NoError(t, err)
+
+	// The menus are rebuilt closed, without the ones they replace
+	restored := NewMenuWindow(0, 0, 80, 1)
+	restored.MenuItems = restored.MenuItems[:1]
+	restored.OpenMenu("file")
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.False(t, restored.IsOpen())
+	assert.Equal(t, w.MenuItems, restored.MenuItems)
+
+	var paths, restoredPaths []string
+	w.WalkItems(func(path string, _ DropdownItem) { paths = append(paths, path) })
+	restored.WalkItems(func(path string, _ DropdownItem) { restoredPaths = append(restoredPaths, path) })
+	assert.Equal(t, paths, restoredPaths)
+	assert.Equal(t, w.AccessibilityTree(), restored.AccessibilityTree())
+
+	items := restored.dropdownMenus["plugin"].Items
+	assert.Equal(t, "lua:plugin.runall", items[0].AltAction)
+	assert.Equal(t, '▶', items[0].Icon)
+	if assert.NotNil(t, items[0].Style) {
+		assert.Equal(t, style, *items[0].Style)
+	}
+	assert.True(t, items[2].KeepOpenOnSelect)
+	if assert.NotNil(t, items[3].Submenu) {
+		assert.Empty(t, items[3].Submenu.Items)
+		assert.Equal(t, 2, items[3].Submenu.MaxColumns)
+	}
+	assert.False(t, items[4].Enabled)
+	if item := restored.findItem("search", "goto"); assert.NotNil(t, item) {
+		assert.Equal(t, "Line: ", item.Prompt)
+	}
+
+	// Invalid menus keep the current ones
+	assert.Error(t, json.Unmarshal([]byte(`[{"name": "", "action": "x"}]`), restored))
+	assert.Equal(t, w.MenuItems, restored.MenuItems)
+}
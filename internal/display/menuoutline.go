@@ -0,0 +1,76 @@
+package display
+
+import "strings"
+
+// outlineIndent indents the items of each menu and submenu in the outline
+// returned by AccessibilityTree
+const outlineIndent = "  "
+
+// AccessibilityTree returns a text outline of the menus on the bar, for
+// screen readers and documentation. Each menu is followed by its items,
+// indented below it, and the items of submenus are indented below the
+// items opening them. Items show their check state, shortcut and whether
+// they are disabled, and separators are shown as divider lines. The
+// labels are translated as they are shown on screen
+func (w *MenuWindow) AccessibilityTree() string {
+	var b strings.Builder
+	for _, m := range w.MenuItems {
+		b.WriteString(w.translate(m.Name))
+		if !m.Enabled {
+			b.WriteString(" (disabled)")
+		}
+		b.WriteByte('\n')
+		if dropdown, exists := w.dropdownMenus[m.Action]; exists {
+			outlineDropdown(&b, dropdown, 1, w.translate)
+		}
+	}
+	return b.String()
+}
+
+// outlineDropdown writes the outline of the items of the dropdown and its
+// submenus, indented by the given depth and with their labels translated
+// by translate, see AccessibilityTree
+func outlineDropdown(b *strings.Builder, d *DropdownMenu, depth int, translate func(string) string) {
+	indent := strings.Repeat(outlineIndent, depth)
+	for _, item := range d.Items {
+		b.WriteString(indent)
+		if item.Separator {
+			b.WriteString("----")
+			if item.Text != "" {
+				b.WriteString(" " + translate(item.Text) + " ----")
+			}
+			b.WriteByte('\n')
+			continue
+		}
+
+		switch {
+		case item.RadioGroup != "" && item.Checked:
+			b.WriteString("(*) ")
+		case item.RadioGroup != "":
+			b.WriteString("( ) ")
+		case item.Checkable && item.Checked:
+			b.WriteString("[x] ")
+		case item.Checkable:
+			b.WriteString("[ ] ")
+		}
+		b.WriteString(translate(item.Text))
+		if item.Submenu != nil {
+			b.WriteString(" >")
+		}
+		if item.Shortcut != "" {
+			b.WriteString(" [" + item.Shortcut + "]")
+		}
+		if !item.Enabled {
+			b.WriteString(" (disabled")
+			if item.DisabledReason != "" {
+				b.WriteString(": " + item.DisabledReason)
+			}
+			b.WriteByte(')')
+		}
+		b.WriteByte('\n')
+
+		if item.Submenu != nil {
+			outlineDropdown(b, item.Submenu, depth+1, translate)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessibilityTree(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.MenuItems = nil
+	recent := NewDropdownMenu()
+	recent.SetItems([]DropdownItem{{Text: "a.txt", Action: "open a.txt", Enabled: true}})
+	w.AddMenu(MenuItem{Name: "File", Action: "file", Enabled: true}, []DropdownItem{
+		{Text: "Save", Action: "save", Enabled: false, Shortcut: "Ctrl-s", DisabledReason: "No changes"},
+		{Text: "Recent", Enabled: true, Submenu: recent},
+		{Separator: true},
+		{Text: "Quit", Action: "quit", Enabled: true},
+	})
+	w.AddMenu(MenuItem{Name: "View", Action: "view", Enabled: false}, []DropdownItem{
+		{Text: "Ruler", Action: "toggle ruler", Enabled: true, Checkable: true, Checked: true},
+		{Separator: true, Text: "Theme"},
+		{Text: "Dark", Action: "dark", Enabled: true, RadioGroup: "theme"},
+	})
+
+	assert.Equal(t, `File
+  Save [Ctrl-s] (disabled: No changes)
+  Recent >
+    a.txt
+  ----
+  Quit
+View (disabled)
+  [x] Ruler
+  ---- Theme ----
+  ( ) Dark
+`, w.AccessibilityTree())
+
+	// The labels are translated as on screen
+	w.Translate = func(s string) string { return "<" + s + ">" }
+	w.MenuItems = w.MenuItems[:1]
+	w.MenuItems[0].Enabled = false
+	recent.SetItems(nil)
+	w.dropdownMenus["file"].Items = w.dropdownMenus["file"].Items[1:2]
+	assert.Equal(t, "<File> (disabled)\n  <Recent> >\n", w.AccessibilityTree())
+}
@@ -0,0 +1,92 @@
+package display
+
+import (
+	shellquote "github.com/kballard/go-shellquote"
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+// OpenRecentAction is the action of the item whose submenu lists the
+// recently opened files, see SetRecentFiles
+const OpenRecentAction = "OpenRecent"
+
+// recentPathWidth is the width recent file paths are shortened to, from
+// the left so that the file name stays visible
+const recentPathWidth = 40
+
+// SetRecentFiles lists the given paths, most recent first, in the submenu
+// of the "Open Recent" item. At most as many as the menurecentfiles option
+// allows are listed, and choosing one runs the open command with its path
+func (w *MenuWindow) SetRecentFiles(paths []string) {
+	submenu := w.recentFilesMenu()
+	if submenu == nil {
+		return
+	}
+
+	if max := int(config.GetGlobalOption("menurecentfiles").(float64)); len(paths) > max {
+		paths = paths[:max]
+	}
+	items := make([]DropdownItem, 0, len(paths))
+	for _, path := range paths {
+		items = append(items, DropdownItem{
+			Text:        truncateLeftWidth(path, recentPathWidth),
+			Action:      "open " + shellquote.Join(path),
+			Enabled:     true,
+			Description: path,
+		})
+	}
+	if len(items) == 0 {
+		items = append(items, DropdownItem{Text: "(no recent files)"})
+	}
+	submenu.SetItems(items)
+	w.indexActions()
+}
+
+// recentFilesMenu returns the submenu of the "Open Recent" item, or nil if
+// no menu has one
+func (w *MenuWindow) recentFilesMenu() *DropdownMenu {
+	for _, m := range w.MenuItems {
+		if dropdown, exists := w.dropdownMenus[m.Action]; exists {
+			if sub := findSubmenu(dropdown, OpenRecentAction); sub != nil {
+				return sub
+			}
+		}
+	}
+	return nil
+}
+
+// findSubmenu returns the submenu of the item with the given action in the
+// dropdown or one of its submenus
+func findSubmenu(d *DropdownMenu, action string) *DropdownMenu {
+	for _, item := range d.Items {
+		if item.Submenu == nil {
+			continue
+		}
+		if item.Action == action {
+			return item.Submenu
+		}
+		if sub := findSubmenu(item.Submenu, action); sub != nil {
+			return sub
+		}
+	}
+	return nil
+}
+
+// truncateLeftWidth shortens s to the given display width by replacing its
+// beginning with "…"
+func truncateLeftWidth(s string, width int) string {
+	if textWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	w := runewidth.StringWidth("…")
+	start := len(runes)
+	for start > 0 && w+runewidth.RuneWidth(runes[start-1]) <= width {
+		start--
+		w += runewidth.RuneWidth(runes[start])
+	}
+	return "…" + string(runes[start:])
+}
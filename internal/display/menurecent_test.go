@@ -0,0 +1,51 @@
+package display
+
+import (
+	"strings"
+	"testing"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+func TestSetRecentFiles(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	submenu := w.recentFilesMenu()
+	if !assert.NotNil(t, submenu) {
+		return
+	}
+
+	// Without recent files there is a disabled placeholder
+	w.SetRecentFiles(nil)
+	if assert.Len(t, submenu.Items, 1) {
+		assert.Equal(t, "(no recent files)", submenu.Items[0].Text)
+		assert.False(t, submenu.Items[0].Enabled)
+	}
+
+	long := "/home/user/" + strings.Repeat("deeply/nested/", 5) + "notes.txt"
+	w.SetRecentFiles([]string{"/tmp/a b.txt", long})
+	if assert.Len(t, submenu.Items, 2) {
+		assert.Equal(t, "/tmp/a b.txt", submenu.Items[0].Text)
+		assert.Equal(t, "open '/tmp/a b.txt'", submenu.Items[0].Action)
+		assert.Equal(t, "open "+long, submenu.Items[1].Action)
+		assert.True(t, strings.HasPrefix(submenu.Items[1].Text, "…"))
+		assert.True(t, strings.HasSuffix(submenu.Items[1].Text, "nested/notes.txt"))
+		assert.Equal(t, recentPathWidth, runewidth.StringWidth(submenu.Items[1].Text))
+	}
+	assert.NotEmpty(t, w.SearchActions("a b.txt"))
+
+	// The list is limited by menurecentfiles
+	config.GlobalSettings["menurecentfiles"] = float64(1)
+	defer func() { config.GlobalSettings["menurecentfiles"] = float64(10) }()
+	w.SetRecentFiles([]string{"/tmp/1", "/tmp/2"})
+	assert.Len(t, submenu.Items, 1)
+}
+
+func TestTruncateLeftWidth(t *testing.T) {
+	assert.Equal(t, "short", truncateLeftWidth("short", 10))
+	assert.Equal(t, "…6789", truncateLeftWidth("123456789", 5))
+	assert.Equal(t, "…界", truncateLeftWidth("世界界", 4))
+	assert.Equal(t, "…\u200d\U0001F4BB", truncateLeftWidth("\U0001F469\u200d\U0001F4BB", 3))
+	assert.Equal(t, "", truncateLeftWidth("abc", 0))
+}
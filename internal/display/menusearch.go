@@ -0,0 +1,140 @@
+package display
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// menuPathSeparator separates the menus in the path of an indexed action
+const menuPathSeparator = " > "
+
+// indexedAction is an item that can be chosen from a menu along with the
+// path of menus leading to it
+type indexedAction struct {
+	item DropdownItem
+	path string
+}
+
+// indexActions rebuilds the index of all items reachable through a menu
+// that SearchActions looks through. It is called whenever menus or their
+// items are added or removed
+func (w *MenuWindow) indexActions() {
+	w.actionIndex = w.actionIndex[:0]
+	w.WalkItems(func(path string, item DropdownItem) {
+		w.actionIndex = append(w.actionIndex, indexedAction{item: item, path: path})
+	})
+}
+
+// WalkItems calls fn with every item that can be chosen from a menu, in
+// the order they are shown in, along with its path such as "File > Save".
+// Separators, placeholders without an action or URL and the items opening
+// submenus are skipped, the items of the submenus are visited instead
+func (w *MenuWindow) WalkItems(fn func(menuPath string, item DropdownItem)) {
+	for _, m := range w.MenuItems {
+		if dropdown, exists := w.dropdownMenus[m.Action]; exists {
+			walkDropdown(dropdown, m.Name, fn)
+		}
+	}
+}
+
+// walkDropdown calls fn with the items of the dropdown and its submenus,
+// with their paths starting with the given prefix, see WalkItems
+func walkDropdown(d *DropdownMenu, prefix string, fn func(menuPath string, item DropdownItem)) {
+	for _, item := range d.Items {
+		if item.Separator {
+			continue
+		}
+		path := prefix + menuPathSeparator + item.Text
+		if item.Submenu != nil {
+			walkDropdown(item.Submenu, path, fn)
+			continue
+		}
+		if item.Action == "" && item.URL == "" {
+			continue
+		}
+		fn(path, item)
+	}
+}
+
+// SearchActions returns the items of all menus whose path, such as
+// "File > Save As", or action matches query, best matches first. The query
+// matches if its characters appear in order, ignoring case, and substrings
+// rank above scattered matches. The returned items are copies whose Text
+// is their path and that have no hotkey, so that they can be listed in a
+// dropdown of their own. An empty query returns all items in menu order
+func (w *MenuWindow) SearchActions(query string) []DropdownItem {
+	type match struct {
+		item  DropdownItem
+		score int
+	}
+	var matches []match
+	for _, entry := range w.actionIndex {
+		score, ok := fuzzyScore(query, entry.path)
+		if actionScore, actionOk := fuzzyScore(query, entry.item.Action); actionOk && (!ok || actionScore > score) {
+			score, ok = actionScore, true
+		}
+		if !ok {
+			continue
+		}
+		item := entry.item
+		item.Text = entry.path
+		item.Hotkey = 0
+		matches = append(matches, match{item, score})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	items := make([]DropdownItem, len(matches))
+	for i, m := range matches {
+		items[i] = m.item
+	}
+	return items
+}
+
+// substringScore is the score of a query found as a substring, less its
+// position, which puts substrings above any scattered match
+const substringScore = 1000
+
+// fuzzyScore rates how well query matches target, ignoring case. It returns
+// false if the characters of query don't appear in target in order.
+// Substrings score highest, earlier ones higher, and otherwise characters
+// that follow each other or start a word score more than scattered ones
+func fuzzyScore(query, target string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(target))
+	if len(q) == 0 {
+		return 0, true
+	}
+
+	if i := strings.Index(string(t), string(q)); i >= 0 {
+		return substringScore - len([]rune(string(t)[:i])), true
+	}
+
+	score := 0
+	qi := 0
+	prev := -2
+	for ti, r := range t {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		switch {
+		case ti == prev+1:
+			score += 3
+		case ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]):
+			score += 2
+		default:
+			score++
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
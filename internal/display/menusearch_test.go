@@ -0,0 +1,93 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func searchPaths(items []DropdownItem) []string {
+	paths := make([]string, len(items))
+	for i, item := range items {
+		paths[i] = item.Text
+	}
+	return paths
+}
+
+func TestSearchActions(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Substrings rank above scattered matches, earlier ones first
+	results := w.SearchActions("save")
+	assert.Equal(t, []string{"File > Save", "File > Save As"}, searchPaths(results)[:2])
+	assert.Equal(t, "Save", results[0].Action)
+	assert.Equal(t, rune(0), results[0].Hotkey)
+
+	// Characters may be scattered as long as they are in order
+	results = w.SearchActions("fnxt")
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "FindNext", results[0].Action)
+	}
+
+	// Actions are matched as well as paths
+	results = w.SearchActions("VSplit")
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "VSplit", results[0].Action)
+	}
+
+	assert.Empty(t, w.SearchActions("zzz"))
+
+	// The index follows changes to the menus
+	w.AddMenu(MenuItem{Name: "Git", Action: "git", Enabled: true}, []DropdownItem{
+		{Text: "Blame", Action: "blame", Enabled: true},
+		{Separator: true},
+		{Text: "Log", Enabled: true, Submenu: NewDropdownMenu()},
+	})
+	w.dropdownMenus["git"].Items[2].Submenu.SetItems([]DropdownItem{
+		{Text: "Current File", Action: "log file", Enabled: true},
+	})
+	w.indexActions()
+	assert.Equal(t, []string{"Git > Blame"}, searchPaths(w.SearchActions("blame")))
+	assert.Equal(t, []string{"Git > Log > Current File"}, searchPaths(w.SearchActions("log cur")))
+
+	assert.NoError(t, w.RemoveMenu("git"))
+	assert.Empty(t, w.SearchActions("blame"))
+}
+
+func TestFuzzyScore(t *testing.T) {
+	_, ok := fuzzyScore("abc", "a-b-c")
+	assert.True(t, ok)
+	_, ok = fuzzyScore("cba", "a-b-c")
+	assert.False(t, ok)
+
+	prefix, _ := fuzzyScore("sa", "Save")
+	inner, _ := fuzzyScore("sa", "Find Save")
+	scattered, _ := fuzzyScore("sv", "Save")
+	assert.Greater(t, prefix, inner)
+	assert.Greater(t, inner, scattered)
+}
+
+func TestWalkItems(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.MenuItems = w.MenuItems[:1]
+	w.AddMenu(MenuItem{Name: "Git", Action: "git", Enabled: true}, []DropdownItem{
+		{Text: "Blame", Action: "blame", Enabled: true},
+		{Separator: true, Text: "History"},
+		{Text: "Log", Enabled: true, Submenu: NewDropdownMenu()},
+		{Text: "Push", Action: "push", Enabled: false},
+	})
+	w.dropdownMenus["git"].Items[2].Submenu.SetItems([]DropdownItem{
+		{Text: "Current File", Action: "log file", Enabled: true},
+	})
+
+	var paths, actions []string
+	w.WalkItems(func(menuPath string, item DropdownItem) {
+		paths = append(paths, menuPath)
+		actions = append(actions, item.Action)
+	})
+	assert.Equal(t, []string{
+		"File > New", "File > Open", "File > Save", "File > Save As", "File > Quit",
+		"Git > Blame", "Git > Log > Current File", "Git > Push",
+	}, paths)
+	assert.Equal(t, "log file", actions[6])
+}
@@ -0,0 +1,106 @@
+package display
+
+import (
+	"github.com/micro-editor/tcell/v2"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+// MenuTheme holds the styles the menu bar and all dropdowns are drawn with
+type MenuTheme struct {
+	Bar        tcell.Style // the menu bar
+	BarActive  tcell.Style // the open or highlighted menu on the bar
+	BarPressed tcell.Style // a menu on the bar in the frame after it is clicked, see MenuWindow.Pressed
+
+	Dropdown         tcell.Style // the items and background of dropdowns
+	Selected         tcell.Style // the highlighted item
+	Hover            tcell.Style // the item under the mouse pointer while dragging
+	Disabled         tcell.Style // disabled items
+	SelectedDisabled tcell.Style // the highlighted item while it is disabled
+	Border           tcell.Style // the borders of dropdowns
+	FocusedBorder    tcell.Style // the border of the dropdown keys go to, see the menufocusring option
+	Shadow           tcell.Style // the shadow of dropdowns
+	Separator        tcell.Style // separator lines and their labels
+}
+
+// menuTheme is the theme set with SetMenuTheme, nil to follow the
+// colorscheme
+var menuTheme *MenuTheme
+
+// SetMenuTheme sets the theme of every menu bar and dropdown, which are
+// restyled the next time they are drawn
+func SetMenuTheme(theme MenuTheme) {
+	menuTheme = &theme
+}
+
+// ResetMenuTheme makes the menus follow the colorscheme again after
+// SetMenuTheme, see DefaultMenuTheme
+func ResetMenuTheme() {
+	menuTheme = nil
+}
+
+// CurrentMenuTheme returns the theme the menus are drawn with
+func CurrentMenuTheme() MenuTheme {
+	if menuTheme != nil {
+		return *menuTheme
+	}
+	return DefaultMenuTheme()
+}
+
+// DefaultMenuTheme returns the theme defined by the menubar and dropdown
+// groups of the colorscheme. Groups the colorscheme doesn't define are
+// derived from the default style: the highlighted menu and item are
+// reversed, a clicked menu and the item under the mouse pointer are bold,
+// and disabled items and the shadow are dimmed. The background of the
+// dropdown.bg group, such as a shade set apart from the editor's, fills
+// the dropdowns and replaces the default background of the dropdown groups
+func DefaultMenuTheme() MenuTheme {
+	var t MenuTheme
+	t.Bar = menuStyle("menubar", config.DefStyle)
+	t.BarActive = menuStyle("menubar.active", t.Bar.Reverse(true))
+	t.BarPressed = menuStyle("menubar.pressed", t.BarActive.Bold(true))
+
+	dropdownStyle := func(group string, def tcell.Style) tcell.Style {
+		return onDropdownBackground(menuStyle(group, def))
+	}
+	t.Dropdown = dropdownStyle("dropdown", config.DefStyle)
+	t.Selected = dropdownStyle("dropdown.active", t.Dropdown.Reverse(true))
+	t.Hover = dropdownStyle("dropdown.hover", t.Dropdown.Bold(true))
+	if style, ok := config.Colorscheme["dropdown.disabled"]; ok {
+		style = onDropdownBackground(style)
+		t.Disabled = style
+		t.SelectedDisabled = style.Reverse(true)
+	} else {
+		t.Disabled = t.Dropdown.Dim(true)
+		t.SelectedDisabled = t.Selected.Dim(true)
+	}
+	t.Border = t.Dropdown
+	t.FocusedBorder = dropdownStyle("menu-dropdown-focused", t.Border.Bold(true))
+	t.Shadow = menuStyle("dropdown.shadow", config.DefStyle.Dim(true))
+	t.Separator = t.Dropdown
+	return t
+}
+
+// onDropdownBackground returns the style with the background of the
+// dropdown.bg group instead of the default background, or the style as it
+// is if it has a background of its own or the group isn't defined
+func onDropdownBackground(style tcell.Style) tcell.Style {
+	bgStyle, ok := config.Colorscheme["dropdown.bg"]
+	if !ok {
+		return style
+	}
+	_, defBg, _ := config.DefStyle.Decompose()
+	if _, bg, _ := style.Decompose(); bg != defBg {
+		return style
+	}
+	_, bg, _ := bgStyle.Decompose()
+	return style.Background(bg)
+}
+
+// menuStyle returns the style of the given colorscheme group, or def if
+// the colorscheme doesn't define the group
+func menuStyle(group string, def tcell.Style) tcell.Style {
+	if style, ok := config.Colorscheme[group]; ok {
+		return style
+	}
+	return def
+}
@@ -0,0 +1,88 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+func TestMenuTheme(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	defer ResetMenuTheme()
+	w := NewMenuWindow(0, 0, 80, 1)
+	other := NewMenuWindow(0, 0, 80, 1)
+	w.SetActive(0)
+	w.SetOpen(true)
+
+	assert.Equal(t, DefaultMenuTheme(), CurrentMenuTheme())
+	cells := w.RenderToCells(80, 24)
+	assert.Equal(t, DefaultMenuTheme().BarActive, cells[0][1].Style)
+
+	// Every menu is restyled on the next render
+	theme := DefaultMenuTheme()
+	theme.Bar = tcell.StyleDefault.Background(tcell.ColorNavy)
+	theme.BarActive = tcell.StyleDefault.Background(tcell.ColorTeal)
+	theme.Dropdown = tcell.StyleDefault.Background(tcell.ColorGreen)
+	theme.Border = tcell.StyleDefault.Background(tcell.ColorOlive)
+	SetMenuTheme(theme)
+
+	cells = w.RenderToCells(80, 24)
+	assert.Equal(t, theme.BarActive, cells[0][1].Style)
+	assert.Equal(t, theme.Bar, cells[0][79].Style)
+	x, y, _, _ := w.GetActiveDropdown().Bounds()
+	assert.Equal(t, theme.Border, cells[y][x].Style)
+	assert.Equal(t, theme.Dropdown, cells[y+2][x+2].Style)
+
+	cells = other.RenderToCells(80, 24)
+	assert.Equal(t, theme.Bar, cells[0][1].Style)
+
+	ResetMenuTheme()
+	cells = w.RenderToCells(80, 24)
+	assert.Equal(t, DefaultMenuTheme().BarActive, cells[0][1].Style)
+}
+
+func TestDropdownBackground(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	if config.Colorscheme == nil {
+		config.Colorscheme = make(map[string]tcell.Style)
+	}
+	bg := tcell.NewRGBColor(0x30, 0x30, 0x40)
+	disabled := config.DefStyle.Foreground(tcell.ColorGray)
+	own := config.DefStyle.Background(tcell.ColorMaroon)
+	config.Colorscheme["dropdown.bg"] = config.DefStyle.Background(bg)
+	config.Colorscheme["dropdown.disabled"] = disabled
+	config.Colorscheme["dropdown.hover"] = own
+	defer delete(config.Colorscheme, "dropdown.bg")
+	defer delete(config.Colorscheme, "dropdown.disabled")
+	defer delete(config.Colorscheme, "dropdown.hover")
+
+	// The dropdown and the styles derived from it take the background,
+	// as do groups without a background of their own
+	theme := DefaultMenuTheme()
+	assert.Equal(t, config.DefStyle.Background(bg), theme.Dropdown)
+	assert.Equal(t, theme.Dropdown, theme.Border)
+	assert.Equal(t, theme.Dropdown.Reverse(true), theme.Selected)
+	assert.Equal(t, disabled.Background(bg), theme.Disabled)
+	assert.Equal(t, own, theme.Hover)
+	assert.Equal(t, config.DefStyle.Dim(true), theme.Shadow)
+	assert.Equal(t, config.DefStyle, theme.Bar)
+
+	d := NewDropdownMenu()
+	d.SetItems([]DropdownItem{
+		{Text: "Enabled", Action: "A", Enabled: true},
+		{Text: "Longer disabled", Action: "B", Enabled: false},
+		{Text: "Other", Action: "C", Enabled: true},
+	})
+	d.Show(0, 0)
+	cells := d.RenderToCells(80, 24)
+	assert.Equal(t, theme.Border, cells[0][0].Style)
+	assert.Equal(t, theme.Selected, cells[1][12].Style)
+	assert.Equal(t, theme.Disabled, cells[2][2].Style)
+	assert.Equal(t, theme.Dropdown, cells[3][12].Style)
+	assert.Equal(t, theme.Shadow, cells[5][1].Style)
+
+	delete(config.Colorscheme, "dropdown.bg")
+	assert.Equal(t, config.DefStyle, DefaultMenuTheme().Dropdown)
+}
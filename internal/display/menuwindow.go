@@ -1,11 +1,17 @@
 package display
 
 import (
-	runewidth "github.com/mattn/go-runewidth"
+	"errors"
+	"image"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
 	"github.com/micro-editor/tcell/v2"
+	"github.com/zyedidia/micro/v2/internal/buffer"
 	"github.com/zyedidia/micro/v2/internal/config"
 	"github.com/zyedidia/micro/v2/internal/screen"
-	"github.com/zyedidia/micro/v2/internal/util"
 )
 
 // MenuItem represents a single menu item
@@ -14,36 +20,189 @@ type MenuItem struct {
 	Action  string
 	Hotkey  rune
 	Enabled bool
+	Align   MenuAlign // which edge of the menu bar the item is pinned to
+}
+
+// MenuAlign is the alignment of a menu on the menu bar
+type MenuAlign int
+
+const (
+	// AlignLeft menus are laid out from the left edge of the menu bar
+	AlignLeft MenuAlign = iota
+	// AlignRight menus are laid out against the right edge of the menu bar
+	AlignRight
+)
+
+// MenuOrientation is the direction the menus are laid out in
+type MenuOrientation int
+
+const (
+	// Horizontal menus are laid out in a bar along a row
+	Horizontal MenuOrientation = iota
+	// Vertical menus are stacked in a sidebar along the left edge, one
+	// per row, and their dropdowns open to the right of them
+	Vertical
+)
+
+// EditorState describes the parts of the editor state that affect which
+// menu items are currently applicable
+type EditorState struct {
+	HasSelection bool
+	Selection    [2]buffer.Loc // bounds of the selection while HasSelection
+	CanUndo      bool
+	CanRedo      bool
+	Modified     bool
+	HasClipboard bool
+}
+
+// Choice is a dropdown item chosen from the menus, returned by HandleClick,
+// HandleMouseUp and HandleKeyNavigation along with how to run it
+type Choice struct {
+	*DropdownItem
+
+	// Count is how many times the item's action should be run, the repeat
+	// count typed as digits before choosing a Repeatable item, otherwise 1
+	Count int
+
+	// Selection is the bounds of the editor's selection when the item was
+	// chosen, as reported by EditorState, for actions confined to it such
+	// as Replace in Selection. Both are the zero Loc without a selection
+	Selection [2]buffer.Loc
+}
+
+// defaultEnabledFuncs decide whether the built-in menu items are enabled
+var defaultEnabledFuncs = map[string]func(EditorState) bool{
+	"Undo":               func(s EditorState) bool { return s.CanUndo },
+	"Redo":               func(s EditorState) bool { return s.CanRedo },
+	"Save":               func(s EditorState) bool { return s.Modified },
+	"Cut":                func(s EditorState) bool { return s.HasSelection },
+	"Copy":               func(s EditorState) bool { return s.HasSelection },
+	"Paste":              func(s EditorState) bool { return s.HasClipboard },
+	"ReplaceInSelection": func(s EditorState) bool { return s.HasSelection },
 }
 
 // MenuWindow displays a horizontal menu bar at the top of the screen
 type MenuWindow struct {
-	MenuItems     []MenuItem
-	Active        int
-	Width         int
-	Height        int
-	Y             int
+	MenuItems []MenuItem
+	Active    int
+	Width     int
+	Height    int
+	Y         int
+
+	// ValidAction reports whether an action can be run from the menu, it
+	// is used to warn about unknown actions in menu config files
+	ValidAction func(action string) bool
+
+	// EditorState returns the current editor state, it is queried before a
+	// dropdown is shown to update which items are enabled
+	EditorState func() EditorState
+
+	// EnabledFuncs map item actions to predicates deciding whether the
+	// items are enabled in the given editor state
+	EnabledFuncs map[string]func(EditorState) bool
+
+	// AutoHide hides the menu bar until it is revealed with ShowTemporarily,
+	// and frees its row for the editor. Visible reports whether the bar is
+	// currently shown
+	AutoHide bool
+	Visible  bool
+
+	// WrapNavigation makes moving past the last menu go to the first one
+	// and vice versa, see SetWrapNavigation
+	WrapNavigation bool
+
+	// Orientation is the direction the menus are laid out in. Vertical
+	// menus take up Height rows from Y
+	Orientation MenuOrientation
+
+	// ItemPadding is the number of blank columns on each side of the menus
+	// on the menu bar and of the items in their dropdowns. MinDropdownWidth
+	// is the smallest width of a dropdown, borders included
+	ItemPadding      int
+	MinDropdownWidth int
+
+	// HoverDelayMs is how long in milliseconds the pointer has to rest on
+	// another menu while one is open before that menu is opened instead,
+	// and on an item with a submenu before the submenu is opened, see
+	// HoverWait. Zero opens them as soon as the pointer reaches them
+	HoverDelayMs int
+
+	// Translate returns the text shown for the names of the menus and the
+	// texts of their items, for localizing the labels. Actions are never
+	// translated. Nil shows names and texts as they are
+	Translate func(string) string
+
+	// OnMenuOpen and OnMenuClose are called with the action of a menu when
+	// its dropdown is opened and closed, OverflowAction for the overflow
+	// button. OnItemSelected is called with every item chosen from a menu.
+	// Any of them may be nil
+	OnMenuOpen     func(action string)
+	OnMenuClose    func(action string)
+	OnItemSelected func(item DropdownItem)
+
+	openAction    string                   // action of the menu whose dropdown is open
 	open          bool                     // whether a menu is currently open
+	modal         bool                     // whether a modal prompt is up
+	disabled      bool                     // whether the menus ignore all input, see SetEnabled
+	keyboardFocus bool                     // whether the menu was opened with the keyboard
+	altHeld       bool                     // whether Alt was held in the last key event
+	focused       bool                     // whether the bar has keyboard focus
+	dragging      bool                     // whether a button pressed on the menus is held, see HandleMouseDown
+	closeOnUp     bool                     // whether releasing the button on the pressed menu closes it
+	resized       bool                     // whether the bar was resized since it was last drawn, see Resize
+	compact       bool                     // whether all menus are behind a single button, see Resize
+	pressed       bool                     // whether the active menu was just clicked, see Pressed
+	dwell         hoverDwell               // menu the pointer rests on, see HoverDelayMs
+	count         int                      // numeric prefix typed in an open dropdown
+	actions       chan string              // actions of selected items, nil until subscribed with ActionChan
+	actionRunner  func(string) error       // runs selected actions, see SetActionRunner
+	lastAction    string                   // action of the last selected item, see LastAction
+	lastText      string                   // text of the last selected item
 	dropdownMenus map[string]*DropdownMenu // dropdown menus for each menu item
+	overflowMenu  *DropdownMenu            // dropdown of the "»" button, see overflowIndex
+	actionIndex   []indexedAction          // items reachable through the menus, see SearchActions
 }
 
+// OverflowAction is the action OnMenuOpen and OnMenuClose report for the
+// dropdown of the overflow button, which lists the menus that don't fit,
+// or all of them in compact mode
+const OverflowAction = "overflow"
+
+// actionChanSize is the number of selected actions buffered in ActionChan
+const actionChanSize = 16
+
+// RepeatLastAction is the action of menu items that run the last selected
+// action again, their text shows which action that is
+const RepeatLastAction = "RepeatLastAction"
+
 // NewMenuWindow creates a new MenuWindow
 func NewMenuWindow(x, y, w, h int) *MenuWindow {
 	mw := new(MenuWindow)
 	mw.MenuItems = []MenuItem{
-		{Name: "File", Action: "file", Hotkey: 'i', Enabled: true},      // Alt+i (was F)
-		{Name: "Edit", Action: "edit", Hotkey: 'd', Enabled: true},      // Alt+d (was E) 
-		{Name: "View", Action: "view", Hotkey: 'w', Enabled: true},      // Alt+w (was V)
-		{Name: "Search", Action: "search", Hotkey: 's', Enabled: true},  // Alt+s (was S)
-		{Name: "Tools", Action: "tools", Hotkey: 't', Enabled: true},    // Alt+t (was T)
-		{Name: "Help", Action: "help", Hotkey: 'h', Enabled: true},      // Alt+h (was H)
+		{Name: "File", Action: "file", Hotkey: 'i', Enabled: true},     // Alt+i (was F)
+		{Name: "Edit", Action: "edit", Hotkey: 'd', Enabled: true},     // Alt+d (was E)
+		{Name: "View", Action: "view", Hotkey: 'w', Enabled: true},     // Alt+w (was V)
+		{Name: "Search", Action: "search", Hotkey: 's', Enabled: true}, // Alt+s (was S)
+		{Name: "Tools", Action: "tools", Hotkey: 't', Enabled: true},   // Alt+t (was T)
+		{Name: "Help", Action: "help", Hotkey: 'h', Enabled: true},     // Alt+h (was H)
 	}
 	mw.Active = -1 // No active menu by default
 	mw.Width = w
 	mw.Height = h
 	mw.Y = y
 	mw.open = false // Menu is closed by default
+	mw.Visible = true
+	mw.WrapNavigation = true
+	mw.ItemPadding = 1
+	mw.MinDropdownWidth = 8
 	mw.dropdownMenus = make(map[string]*DropdownMenu)
+	mw.EnabledFuncs = make(map[string]func(EditorState) bool)
+	for action, f := range defaultEnabledFuncs {
+		mw.EnabledFuncs[action] = f
+	}
+	mw.EnabledFuncs[RepeatLastAction] = func(EditorState) bool {
+		return mw.lastAction != ""
+	}
 
 	// Initialize dropdown menus
 	mw.initializeDropdownMenus()
@@ -53,28 +212,37 @@ func NewMenuWindow(x, y, w, h int) *MenuWindow {
 
 // initializeDropdownMenus sets up the dropdown menus for each main menu item
 func (w *MenuWindow) initializeDropdownMenus() {
-	// File menu
+	// File menu, SetRecentFiles fills in the recent files
+	recentMenu := NewDropdownMenu()
+	recentMenu.SetItems([]DropdownItem{{Text: "(no recent files)"}})
 	fileMenu := NewDropdownMenu()
 	fileMenu.SetItems([]DropdownItem{
-		{Text: "New", Action: "NewTab", Hotkey: 'N', Enabled: true},
-		{Text: "Open", Action: "Open", Hotkey: 'O', Enabled: true},
+		{Text: "New", Action: "NewTab", Hotkey: 'N', Enabled: true, Shortcut: "Ctrl-t"},
+		{Text: "Open", Action: "Open", Hotkey: 'O', Enabled: true, Shortcut: "Ctrl-o"},
+		{Text: "Open Recent", Action: OpenRecentAction, Hotkey: 'R', Enabled: true, Submenu: recentMenu},
 		{Separator: true},
-		{Text: "Save", Action: "Save", Hotkey: 'S', Enabled: true},
+		{Text: "Save", Action: "Save", Hotkey: 'S', Enabled: true, Shortcut: "Ctrl-s",
+			DisabledReason: "no unsaved changes"},
 		{Text: "Save As", Action: "SaveAs", Hotkey: 'A', Enabled: true},
 		{Separator: true},
-		{Text: "Quit", Action: "Quit", Hotkey: 'Q', Enabled: true},
+		{Text: "Quit", Action: "Quit", Hotkey: 'Q', Enabled: true, Shortcut: "Ctrl-q"},
 	})
 	w.dropdownMenus["file"] = fileMenu
 
 	// Edit menu
 	editMenu := NewDropdownMenu()
 	editMenu.SetItems([]DropdownItem{
-		{Text: "Undo", Action: "Undo", Hotkey: 'U', Enabled: true},
-		{Text: "Redo", Action: "Redo", Hotkey: 'R', Enabled: true},
+		{Text: "Undo", Action: "Undo", Hotkey: 'U', Enabled: true, Shortcut: "Ctrl-z", Repeatable: true,
+			DisabledReason: "nothing to undo"},
+		{Text: "Redo", Action: "Redo", Hotkey: 'R', Enabled: true, Shortcut: "Ctrl-y", Repeatable: true,
+			DisabledReason: "nothing to redo"},
 		{Separator: true},
-		{Text: "Cut", Action: "Cut", Hotkey: 'X', Enabled: true},
-		{Text: "Copy", Action: "Copy", Hotkey: 'C', Enabled: true},
-		{Text: "Paste", Action: "Paste", Hotkey: 'V', Enabled: true},
+		{Text: "Cut", Action: "Cut", Hotkey: 'X', Enabled: true, Shortcut: "Ctrl-x",
+			DisabledReason: "no selection"},
+		{Text: "Copy", Action: "Copy", Hotkey: 'C', Enabled: true, Shortcut: "Ctrl-c",
+			DisabledReason: "no selection"},
+		{Text: "Paste", Action: "Paste", Hotkey: 'V', Enabled: true, Shortcut: "Ctrl-v",
+			DisabledReason: "clipboard is empty"},
 	})
 	w.dropdownMenus["edit"] = editMenu
 
@@ -84,25 +252,30 @@ func (w *MenuWindow) initializeDropdownMenus() {
 		{Text: "Split Horizontal", Action: "HSplit", Hotkey: 'H', Enabled: true},
 		{Text: "Split Vertical", Action: "VSplit", Hotkey: 'V', Enabled: true},
 		{Separator: true},
-		{Text: "Toggle Line Numbers", Action: "ToggleRuler", Hotkey: 'L', Enabled: true},
+		{Text: "Toggle Line Numbers", Action: "ToggleRuler", Hotkey: 'L', Enabled: true, Shortcut: "Ctrl-r", Checkable: true},
 	})
 	w.dropdownMenus["view"] = viewMenu
 
 	// Search menu
 	searchMenu := NewDropdownMenu()
 	searchMenu.SetItems([]DropdownItem{
-		{Text: "Find", Action: "Find", Hotkey: 'F', Enabled: true},
-		{Text: "Find Next", Action: "FindNext", Hotkey: 'N', Enabled: true},
-		{Text: "Find Previous", Action: "FindPrevious", Hotkey: 'P', Enabled: true},
+		{Text: "Find", Action: "Find", Hotkey: 'F', Enabled: true, Shortcut: "Ctrl-f"},
+		{Text: "Find Next", Action: "FindNext", Hotkey: 'N', Enabled: true, Shortcut: "Ctrl-n", Repeatable: true},
+		{Text: "Find Previous", Action: "FindPrevious", Hotkey: 'P', Enabled: true, Shortcut: "Ctrl-p", Repeatable: true},
+		{Text: "Go to Line", Action: "goto", Hotkey: 'G', Enabled: true, Prompt: "Line: "},
 		{Separator: true},
 		{Text: "Replace", Action: "Replace", Hotkey: 'R', Enabled: true},
+		{Text: "Replace in Selection", Action: "ReplaceInSelection", Hotkey: 'S', Enabled: false,
+			DisabledReason: "no selection"},
 	})
 	w.dropdownMenus["search"] = searchMenu
 
 	// Tools menu
 	toolsMenu := NewDropdownMenu()
 	toolsMenu.SetItems([]DropdownItem{
-		{Text: "Command Palette", Action: "CommandMode", Hotkey: 'C', Enabled: true},
+		{Text: "Repeat", Action: RepeatLastAction, Hotkey: 'R', Enabled: false},
+		{Separator: true},
+		{Text: "Command Palette", Action: "CommandMode", Hotkey: 'C', Enabled: true, Shortcut: "Ctrl-e"},
 		{Text: "Plugin Manager", Action: "PluginInstall", Hotkey: 'P', Enabled: true},
 	})
 	w.dropdownMenus["tools"] = toolsMenu
@@ -110,335 +283,1838 @@ func (w *MenuWindow) initializeDropdownMenus() {
 	// Help menu
 	helpMenu := NewDropdownMenu()
 	helpMenu.SetItems([]DropdownItem{
-		{Text: "Help", Action: "ToggleHelp", Hotkey: 'H', Enabled: true},
+		{Text: "Help", Action: "ToggleHelp", Hotkey: 'H', Enabled: true, Shortcut: "Ctrl-g"},
 		{Text: "Key Bindings", Action: "ShowKey", Hotkey: 'K', Enabled: true},
 		{Separator: true},
+		{Text: "Documentation", Action: "OpenURL", Hotkey: 'D', Enabled: true,
+			URL: "https://github.com/zyedidia/micro/tree/master/runtime/help"},
+		{Text: "Report Bug", Action: "OpenURL", Hotkey: 'R', Enabled: true,
+			URL: "https://github.com/zyedidia/micro/issues"},
+		{Separator: true},
 		{Text: "About", Action: "ShowAbout", Hotkey: 'A', Enabled: true},
 	})
 	w.dropdownMenus["help"] = helpMenu
+
+	w.indexActions()
 }
 
-// Resize adjusts the menu window size
-func (w *MenuWindow) Resize(width, height int) {
-	w.Width = width
+// UpdateEnabledStates enables or disables every dropdown item that has a
+// predicate in EnabledFuncs according to the given editor state
+func (w *MenuWindow) UpdateEnabledStates(ctx EditorState) {
+	for _, dropdown := range w.dropdownMenus {
+		w.updateEnabledStates(dropdown, ctx)
+	}
 }
 
-// SetActive sets the active menu item
-func (w *MenuWindow) SetActive(index int) {
-	if index >= 0 && index < len(w.MenuItems) {
-		w.Active = index
-	} else {
-		w.Active = -1
+func (w *MenuWindow) updateEnabledStates(d *DropdownMenu, ctx EditorState) {
+	for i := range d.Items {
+		item := &d.Items[i]
+		if item.Submenu != nil {
+			w.updateEnabledStates(item.Submenu, ctx)
+		} else if f, ok := w.EnabledFuncs[item.Action]; ok && !item.Separator {
+			item.Enabled = f(ctx)
+		}
 	}
 }
 
-// GetActive returns the currently active menu item
-func (w *MenuWindow) GetActive() int {
-	return w.Active
+// findItem returns the item with the given action in the dropdown of the
+// menu with the given action, or nil if there is no such item
+func (w *MenuWindow) findItem(menuAction, itemAction string) *DropdownItem {
+	dropdown, exists := w.dropdownMenus[menuAction]
+	if !exists {
+		return nil
+	}
+	for i := range dropdown.Items {
+		if item := &dropdown.Items[i]; !item.Separator && item.Action == itemAction {
+			return item
+		}
+	}
+	return nil
 }
 
-// IsOpen returns whether a menu is currently open
-func (w *MenuWindow) IsOpen() bool {
-	return w.open
+// menuIndex returns the index of the menu with the given action, or -1
+func (w *MenuWindow) menuIndex(menuAction string) int {
+	for i, item := range w.MenuItems {
+		if item.Action == menuAction {
+			return i
+		}
+	}
+	return -1
 }
 
-// SetOpen sets the menu open state
-func (w *MenuWindow) SetOpen(open bool) {
-	w.open = open
+// Clone returns a deep copy of the menu bar, with copies of its menus and
+// of all dropdowns and submenus, so that it can be kept as a snapshot while
+// the menus are changed. The copy starts out closed and has its own action
+// channel. It has no OnMenuOpen, OnMenuClose and OnItemSelected callbacks,
+// so that using it never notifies the original's listeners, and closing it
+// doesn't report the original's open menu as closed
+func (w *MenuWindow) Clone() *MenuWindow {
+	c := *w
+	c.MenuItems = append([]MenuItem(nil), w.MenuItems...)
+	c.dropdownMenus = make(map[string]*DropdownMenu, len(w.dropdownMenus))
+	for action, dropdown := range w.dropdownMenus {
+		c.dropdownMenus[action] = dropdown.clone()
+	}
+	c.EnabledFuncs = make(map[string]func(EditorState) bool, len(w.EnabledFuncs))
+	for action, f := range w.EnabledFuncs {
+		c.EnabledFuncs[action] = f
+	}
+	c.overflowMenu = nil
+	c.actions = nil
+	c.actionIndex = nil
+	c.indexActions()
+	c.openAction = ""
+	c.OnMenuOpen, c.OnMenuClose, c.OnItemSelected = nil, nil, nil
+	c.CloseAll()
+	return &c
+}
 
-	// Show/hide the appropriate dropdown menu
-	if open && w.Active >= 0 && w.Active < len(w.MenuItems) {
-		activeItem := w.MenuItems[w.Active]
-		if dropdown, exists := w.dropdownMenus[activeItem.Action]; exists {
-			// Calculate dropdown position
-			dropdownX := w.getMenuItemX(w.Active)
-			dropdownY := w.Y + 1 // Below the menu bar
-			dropdown.Show(dropdownX, dropdownY)
+// AddMenu adds a menu with the given dropdown items to the end of the menu
+// bar. A menu with the same action is replaced instead
+func (w *MenuWindow) AddMenu(item MenuItem, items []DropdownItem) {
+	dropdown := NewDropdownMenu()
+	dropdown.SetItems(items)
+
+	if i := w.menuIndex(item.Action); i >= 0 {
+		if i == w.Active {
+			w.SetOpen(false)
 		}
+		w.MenuItems[i] = item
 	} else {
-		// Hide all dropdown menus
-		for _, dropdown := range w.dropdownMenus {
-			dropdown.Hide()
+		// The overflow button's index moves along with the new menu
+		if w.Active == w.overflowIndex() {
+			w.SetActive(-1)
+			w.SetOpen(false)
 		}
+		w.MenuItems = append(w.MenuItems, item)
 	}
+	w.dropdownMenus[item.Action] = dropdown
+	if err := w.validateNoCycles(); err != nil {
+		log.Println("Warning:", err)
+	}
+	setWrapNavigation(dropdown, w.WrapNavigation)
+	w.indexActions()
 }
 
-// getMenuItemX calculates the X position of a menu item
-func (w *MenuWindow) getMenuItemX(index int) int {
-	x := 0
-	for i := 0; i < index && i < len(w.MenuItems); i++ {
-		item := w.MenuItems[i]
-		if !item.Enabled {
-			continue
-		}
-		itemWidth := util.StringWidth([]byte(item.Name), util.CharacterCountInString(item.Name), 1)
-		x += itemWidth + 2 // +2 for padding
+// RemoveMenu removes the menu with the given action from the menu bar
+func (w *MenuWindow) RemoveMenu(menuAction string) error {
+	i := w.menuIndex(menuAction)
+	if i < 0 {
+		return errors.New("No menu " + menuAction)
 	}
-	return x
+
+	// Indices of the following menus shift, so close any open menu
+	w.SetActive(-1)
+	w.SetOpen(false)
+	w.MenuItems = append(w.MenuItems[:i], w.MenuItems[i+1:]...)
+	delete(w.dropdownMenus, menuAction)
+	w.indexActions()
+	return nil
 }
 
-// Display renders the menu bar
-func (w *MenuWindow) Display() {
-	if w.Height <= 0 {
+// SetMenuEnabled enables or disables the menu with the given action.
+// Disabled menus are hidden from the menu bar and skipped by the keyboard,
+// and disabling the active menu closes it
+func (w *MenuWindow) SetMenuEnabled(menuAction string, enabled bool) {
+	i := w.menuIndex(menuAction)
+	if i < 0 {
 		return
 	}
+	if !enabled && i == w.Active {
+		w.SetActive(-1)
+		w.SetOpen(false)
+	}
+	w.MenuItems[i].Enabled = enabled
+}
 
-	// Clear the menu bar area
-	for x := 0; x < w.Width; x++ {
-		screen.SetContent(x, w.Y, ' ', nil, config.DefStyle)
+// AddDropdownItem appends an item to the dropdown of the menu with the
+// given action. If the item's submenu contains the dropdown it is added to
+// the submenu is detached and an error is returned, see validateNoCycles
+func (w *MenuWindow) AddDropdownItem(menuAction string, item DropdownItem) error {
+	dropdown, exists := w.dropdownMenus[menuAction]
+	if !exists {
+		return errors.New("No menu " + menuAction)
+	}
+	dropdown.SetItems(append(dropdown.Items, item))
+	err := w.validateNoCycles()
+	if submenu := dropdown.Items[len(dropdown.Items)-1].Submenu; submenu != nil {
+		setWrapNavigation(submenu, dropdown.WrapNavigation)
 	}
+	w.indexActions()
+	return err
+}
 
-	x := 0
-	for i, item := range w.MenuItems {
-		if !item.Enabled {
-			continue
+// RemoveDropdownItem removes the item with the given action from the
+// dropdown of the menu with the given action
+func (w *MenuWindow) RemoveDropdownItem(menuAction, itemAction string) error {
+	dropdown, exists := w.dropdownMenus[menuAction]
+	if !exists {
+		return errors.New("No menu " + menuAction)
+	}
+	for i, item := range dropdown.Items {
+		if !item.Separator && item.Action == itemAction {
+			if dropdown.IsVisible() {
+				w.SetOpen(false)
+			}
+			dropdown.SetItems(append(dropdown.Items[:i], dropdown.Items[i+1:]...))
+			w.indexActions()
+			return nil
 		}
+	}
+	return errors.New("No item " + itemAction + " in menu " + menuAction)
+}
 
-		// Calculate item display text
-		displayText := item.Name
-		itemWidth := util.StringWidth([]byte(displayText), util.CharacterCountInString(displayText), 1)
+// SetItemStateFunc sets the function that refreshes the checked state of
+// a checkable item whenever its dropdown is shown. It returns false if the
+// item does not exist
+func (w *MenuWindow) SetItemStateFunc(menuAction, itemAction string, state func() bool) bool {
+	item := w.findItem(menuAction, itemAction)
+	if item == nil {
+		return false
+	}
+	item.Checkable = true
+	item.StateFunc = state
+	return true
+}
 
-		// Add padding
-		padding := 2
-		totalWidth := itemWidth + padding
+// SetMenuPopulateFunc sets the function that builds the items of the
+// dropdown of the menu with the given action whenever it is opened, see
+// DropdownMenu.PopulateFunc. It returns false if the menu does not exist
+func (w *MenuWindow) SetMenuPopulateFunc(menuAction string, populate func() []DropdownItem) bool {
+	dropdown, exists := w.dropdownMenus[menuAction]
+	if !exists {
+		return false
+	}
+	dropdown.PopulateFunc = populate
+	return true
+}
 
-		// Check if we have space for this item
-		if x+totalWidth > w.Width {
-			break
-		}
+// SetItemBusy shows (or stops) a spinner on the item with the given action
+// in the dropdown of the menu with the given action, to indicate that the
+// item is running an async task
+func (w *MenuWindow) SetItemBusy(menuAction, itemAction string, busy bool) {
+	if dropdown, exists := w.dropdownMenus[menuAction]; exists {
+		dropdown.SetBusy(itemAction, busy)
+	}
+}
 
-		// Determine style based on active state
-		style := config.DefStyle
-		if i == w.Active {
-			// Highlight active menu item
-			style = style.Reverse(true)
-		}
+// Resize adjusts the menu window size. The open dropdown is moved below
+// its menu's new position, or closed if the menu no longer fits, when the
+// bar is drawn next, so that a burst of resizes only does so once. A
+// Horizontal bar narrower than the menucompactwidth option switches to
+// compact mode, where a single button opens a dropdown of all menus, and
+// the open menu is closed whenever the bar switches modes
+func (w *MenuWindow) Resize(width, height int) {
+	w.Width = width
+	w.Height = height
+	w.resized = true
 
-		// Add left padding
-		screen.SetContent(x, w.Y, ' ', nil, style)
-		x++
+	compact := w.Orientation == Horizontal && compactWanted(width)
+	if compact != w.compact {
+		w.compact = compact
+		w.SetActive(-1)
+		w.SetOpen(false)
+	}
+}
 
-		// Render the menu item text with hotkey highlighting
-		for j, r := range displayText {
-			charStyle := style
-			// Highlight the hotkey character
-			if r == item.Hotkey || (r >= 'A' && r <= 'Z' && r-'A'+'a' == item.Hotkey) {
-				charStyle = charStyle.Underline(true)
-			}
+// compactWanted returns whether a bar of the given width is shown in
+// compact mode, according to the menucompact and menucompactwidth options
+func compactWanted(width int) bool {
+	switch config.GetGlobalOption("menucompact").(string) {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return width < int(config.GetGlobalOption("menucompactwidth").(float64))
+}
 
-			screen.SetContent(x, w.Y, r, nil, charStyle)
-			x += runewidth.RuneWidth(r)
+// IsCompact returns whether the menus are shown behind a single button
+// because the bar is too narrow, see Resize
+func (w *MenuWindow) IsCompact() bool {
+	return w.compact
+}
 
-			// Handle zero-width characters
-			if runewidth.RuneWidth(r) == 0 && j > 0 {
-				x = x - 1
-			}
+// relayout moves the open dropdown below its menu after a resize, and
+// closes the menu if neither it nor the overflow button holding it are
+// shown on the bar anymore
+func (w *MenuWindow) relayout() {
+	w.resized = false
+	if w.Active < 0 {
+		return
+	}
+	if xs := w.layout(); xs[w.Active] < 0 && xs[w.overflowIndex()] < 0 {
+		w.SetActive(-1)
+		w.SetOpen(false)
+		return
+	}
+	if dropdown, exists := w.menuDropdown(w.Active); exists && w.open && dropdown.IsVisible() {
+		dropdown.X, dropdown.Y = w.dropdownOrigin(w.Active)
+		dropdown.anchorX = dropdown.X
+		if w.Orientation == Horizontal {
+			dropdown.anchorWidth = w.slotWidth(w.shownSlot(w.Active))
 		}
+	}
+}
 
-		// Add right padding
-		screen.SetContent(x, w.Y, ' ', nil, style)
-		x++
+// SetWrapNavigation sets whether navigation wraps around at the edges of
+// the menu bar and of all dropdowns
+func (w *MenuWindow) SetWrapNavigation(wrap bool) {
+	w.WrapNavigation = wrap
+	for _, dropdown := range w.dropdownMenus {
+		setWrapNavigation(dropdown, wrap)
 	}
+}
 
-	// Fill remaining space with default style
-	for x < w.Width {
-		screen.SetContent(x, w.Y, ' ', nil, config.DefStyle)
-		x++
+// setWrapNavigation sets the wrapping of a dropdown and its submenus
+func setWrapNavigation(d *DropdownMenu, wrap bool) {
+	d.WrapNavigation = wrap
+	for _, item := range d.Items {
+		if item.Submenu != nil {
+			setWrapNavigation(item.Submenu, wrap)
+		}
 	}
+}
 
-	// Note: Dropdown menus are now displayed separately in the main event loop
-	// to ensure they appear on top of all other content
+// translate returns the text shown for the given name, see Translate
+func (w *MenuWindow) translate(name string) string {
+	if w.Translate == nil {
+		return name
+	}
+	return w.Translate(name)
 }
 
-// HandleClick handles mouse clicks on the menu bar and dropdowns
-func (w *MenuWindow) HandleClick(x, y int) *DropdownItem {
-	// First check if click is on an open dropdown
-	if w.open && w.Active >= 0 && w.Active < len(w.MenuItems) {
-		activeItem := w.MenuItems[w.Active]
-		if dropdown, exists := w.dropdownMenus[activeItem.Action]; exists && dropdown.IsVisible() {
-			if clickedItem := dropdown.HandleClick(x, y); clickedItem != nil {
-				// A dropdown item was clicked - return it for execution
-				w.SetActive(-1)
-				w.SetOpen(false)
-				return clickedItem
-			}
-			// Click might have closed the dropdown, check if we should handle menu bar click
-			if !dropdown.IsVisible() {
-				w.SetActive(-1)
-				w.SetOpen(false)
+// configureDropdown passes the translation and the padding of the menu
+// bar on to a dropdown and its submenus and resizes them accordingly. With
+// the menubreadcrumbs option the submenus are titled with their path from
+// the given path of the dropdown
+func (w *MenuWindow) configureDropdown(d *DropdownMenu, path string) {
+	d.Translate = w.Translate
+	d.ItemPadding = w.ItemPadding
+	d.MinWidth = w.MinDropdownWidth
+	d.HoverDelayMs = w.HoverDelayMs
+	if d.PopulateFunc != nil {
+		// The items built when the dropdown is shown are new to the menus
+		d.onPopulate = func() {
+			w.configureDropdown(d, path)
+			if w.EditorState != nil {
+				w.updateEnabledStates(d, w.EditorState())
 			}
 		}
 	}
-
-	// Check if click is on menu bar
-	if y != w.Y {
-		// Click outside menu bar and dropdown - close any open menu
-		if w.open {
-			w.SetActive(-1)
-			w.SetOpen(false)
+	breadcrumbs := config.GetGlobalOption("menubreadcrumbs").(bool)
+	separator := " ▸ "
+	if d.UseASCIIBorders {
+		separator = " > "
+	}
+	for _, item := range d.Items {
+		if item.Submenu != nil {
+			subPath := w.translate(item.Text)
+			if path != "" {
+				subPath = path + separator + subPath
+			}
+			item.Submenu.Title = ""
+			if breadcrumbs {
+				item.Submenu.Title = subPath
+			}
+			item.Submenu.UseASCIIBorders = d.UseASCIIBorders
+			w.configureDropdown(item.Submenu, subPath)
 		}
-		return nil
 	}
+	// Accordion dropdowns are sized after their submenus
+	d.calculateSize()
+}
 
-	// Calculate which menu item was clicked
-	currentX := 0
-	for i, item := range w.MenuItems {
-		if !item.Enabled {
-			continue
-		}
+// SetAltHeld records whether Alt is held. Terminals only report Alt along
+// with another key, so the event loop updates it on every key event
+func (w *MenuWindow) SetAltHeld(held bool) {
+	w.altHeld = held
+}
 
-		itemWidth := util.StringWidth([]byte(item.Name), util.CharacterCountInString(item.Name), 1) + 2 // +2 for padding
+// mnemonicsShown returns whether hotkeys are underlined, which they are
+// while Alt is held or the menus are used with the keyboard
+func (w *MenuWindow) mnemonicsShown() bool {
+	return !w.disabled && (w.altHeld || w.focused || (w.open && w.keyboardFocus))
+}
 
-		if x >= currentX && x < currentX+itemWidth {
-			if w.Active == i && w.open {
-				// Close if clicking on already open menu
-				w.SetActive(-1)
-				w.SetOpen(false)
-			} else {
-				// Activate and open menu
-				w.SetActive(i)
-				w.SetOpen(true)
-			}
-			return nil
+// setHideMnemonics hides or shows the hotkeys of a dropdown and its submenus
+func setHideMnemonics(d *DropdownMenu, hide bool) {
+	d.HideMnemonics = hide
+	for _, item := range d.Items {
+		if item.Submenu != nil {
+			setHideMnemonics(item.Submenu, hide)
 		}
-
-		currentX += itemWidth
 	}
-
-	// Click outside menu items - close any open menu
-	w.SetActive(-1)
-	w.SetOpen(false)
-	return nil
 }
 
-// HandleKey handles keyboard input for menu navigation
-func (w *MenuWindow) HandleKey(key rune) bool {
-	// Check for hotkey matches
-	for i, item := range w.MenuItems {
-		if !item.Enabled {
-			continue
-		}
+// SetAutoHide turns auto-hiding of the menu bar on or off
+func (w *MenuWindow) SetAutoHide(autoHide bool) {
+	w.AutoHide = autoHide
+	w.Visible = !autoHide || w.open || w.focused
+}
 
-		if key == item.Hotkey || (key >= 'A' && key <= 'Z' && key-'A'+'a' == item.Hotkey) {
-			w.SetActive(i)
-			w.SetOpen(true)
-			return true
-		}
+// ReservedHeight returns the number of rows the editor must leave free for
+// the menu bar. An auto-hidden bar is drawn over the editor when revealed
+func (w *MenuWindow) ReservedHeight() int {
+	if w.AutoHide || w.Orientation == Vertical {
+		return 0
 	}
+	return w.Height
+}
 
-	return false
+// ReservedWidth returns the number of columns the editor must leave free
+// on the left for the sidebar of Vertical menus
+func (w *MenuWindow) ReservedWidth() int {
+	if w.AutoHide || w.Orientation == Horizontal {
+		return 0
+	}
+	return w.sidebarWidth()
 }
 
-// HandleKeyNavigation handles keyboard navigation for menu and dropdown
-func (w *MenuWindow) HandleKeyNavigation(key rune, keyCode int) *DropdownItem {
-	// If no menu is active, check for Alt+hotkey combinations
-	if !w.open || w.Active < 0 {
-		// Check for hotkey matches to open menus
-		for i, item := range w.MenuItems {
-			if !item.Enabled {
-				continue
-			}
+// ShowTemporarily reveals an auto-hidden menu bar
+func (w *MenuWindow) ShowTemporarily() {
+	w.Visible = true
+}
 
-			if key == item.Hotkey || (key >= 'A' && key <= 'Z' && key-'A'+'a' == item.Hotkey) {
-				w.SetActive(i)
-				w.SetOpen(true)
-				return nil
-			}
-		}
-		return nil
+// HideIfAuto hides the menu bar again if it auto-hides and is not in use
+func (w *MenuWindow) HideIfAuto() {
+	if w.AutoHide && !w.open && !w.focused {
+		w.Visible = false
 	}
+}
 
-	// If a menu is open, handle dropdown navigation
-	if w.Active >= 0 && w.Active < len(w.MenuItems) {
-		activeItem := w.MenuItems[w.Active]
-		if dropdown, exists := w.dropdownMenus[activeItem.Action]; exists && dropdown.IsVisible() {
-			// Use tcell key constants for proper key detection
-			switch keyCode {
-			case int(tcell.KeyEnter):
-				selectedItem := dropdown.GetActiveItem()
-				if selectedItem != nil && selectedItem.Enabled && !selectedItem.Separator {
-					w.SetActive(-1)
-					w.SetOpen(false)
-					return selectedItem
-				}
-			case int(tcell.KeyEscape):
-				w.SetActive(-1)
-				w.SetOpen(false)
-				return nil
-			case int(tcell.KeyUp):
-				dropdown.MoveUp()
-				return nil
-			case int(tcell.KeyDown):
-				dropdown.MoveDown()
-				return nil
-			case int(tcell.KeyLeft):
-				w.navigateToPreviousMenu()
-				return nil
-			case int(tcell.KeyRight):
-				w.navigateToNextMenu()
-				return nil
-			default:
-				// Check for dropdown item hotkeys
-				for _, item := range dropdown.Items {
-					if !item.Separator && item.Enabled {
-						if key == item.Hotkey || (key >= 'A' && key <= 'Z' && key-'A'+'a' == item.Hotkey) {
-							w.SetActive(-1)
-							w.SetOpen(false)
-							return &item
-						}
-					}
-				}
-			}
+// SetActive sets the active menu item. An out of range index deactivates
+// the menu bar, while an index pointing at a disabled menu is rejected and
+// leaves the active item unchanged so that an unusable menu is never
+// highlighted
+func (w *MenuWindow) SetActive(index int) {
+	w.pressed = false
+	if index >= 0 && index < len(w.MenuItems) {
+		if w.MenuItems[index].Enabled {
+			w.Active = index
 		}
+	} else if index == w.overflowIndex() && w.layout()[index] >= 0 {
+		w.Active = index
+	} else {
+		w.Active = -1
+		w.focused = false
 	}
+}
 
-	return nil
+// Pressed returns whether a menu was just clicked open and is drawn
+// pressed the next time the bar is drawn. The bar should be drawn again
+// shortly after that frame to show the menu as open
+func (w *MenuWindow) Pressed() bool {
+	return w.pressed
 }
 
-// navigateToPreviousMenu moves to the previous menu item
-func (w *MenuWindow) navigateToPreviousMenu() {
-	if w.Active <= 0 {
-		// Wrap to last menu
-		for i := len(w.MenuItems) - 1; i >= 0; i-- {
-			if w.MenuItems[i].Enabled {
-				w.SetActive(i)
-				w.SetOpen(true)
-				return
-			}
-		}
-	} else {
-		// Move to previous enabled menu
-		for i := w.Active - 1; i >= 0; i-- {
-			if w.MenuItems[i].Enabled {
-				w.SetActive(i)
-				w.SetOpen(true)
-				return
-			}
-		}
+// Focus gives the menu bar keyboard focus and highlights the first enabled
+// menu. If the menuautoopen option is set its dropdown is opened as well
+func (w *MenuWindow) Focus() {
+	if w.FocusBar() && config.GetGlobalOption("menuautoopen").(bool) {
+		w.SetOpen(true)
 	}
 }
 
-// navigateToNextMenu moves to the next menu item
-func (w *MenuWindow) navigateToNextMenu() {
-	if w.Active >= len(w.MenuItems)-1 {
-		// Wrap to first menu
-		for i := 0; i < len(w.MenuItems); i++ {
-			if w.MenuItems[i].Enabled {
-				w.SetActive(i)
-				w.SetOpen(true)
-				return
-			}
+// FocusBar gives the menu bar keyboard focus and highlights the first
+// enabled menu without opening its dropdown, so that the menus can be
+// browsed with the arrow keys and opened with Down or Enter. It returns
+// false if there is no enabled menu to focus
+func (w *MenuWindow) FocusBar() bool {
+	if w.disabled {
+		return false
+	}
+	for i, item := range w.MenuItems {
+		if item.Enabled {
+			w.SetOpen(false)
+			w.SetActive(i)
+			w.focused = true
+			w.keyboardFocus = true
+			return true
 		}
+	}
+	return false
+}
+
+// ToggleFocus focuses the menu bar, or closes it and gives focus back to
+// the editor if it is already focused or open
+func (w *MenuWindow) ToggleFocus() {
+	if w.focused || w.open {
+		w.CloseAll()
 	} else {
-		// Move to next enabled menu
-		for i := w.Active + 1; i < len(w.MenuItems); i++ {
-			if w.MenuItems[i].Enabled {
-				w.SetActive(i)
-				w.SetOpen(true)
-				return
-			}
-		}
+		w.Focus()
+	}
+}
+
+// CloseAll closes the open menu along with its dropdowns and submenus,
+// deactivates the highlighted menu and gives keyboard focus back to the
+// editor. Nothing happens if no menu is active
+func (w *MenuWindow) CloseAll() {
+	w.Active = -1
+	w.focused = false
+	w.keyboardFocus = false
+	w.dragging = false
+	w.SetOpen(false)
+}
+
+// IsFocused returns whether the menu bar has keyboard focus
+func (w *MenuWindow) IsFocused() bool {
+	return w.focused
+}
+
+// GetActive returns the currently active menu item
+func (w *MenuWindow) GetActive() int {
+	return w.Active
+}
+
+// IsOpen returns whether a menu is currently open
+func (w *MenuWindow) IsOpen() bool {
+	return w.open
+}
+
+// SetOpen sets the menu open state
+func (w *MenuWindow) SetOpen(open bool) {
+	w.open = open
+	w.count = 0
+
+	// Show/hide the appropriate dropdown menu
+	if open && w.Active == w.overflowIndex() {
+		w.overflowMenu = w.buildOverflowMenu()
+	}
+	shown := ""
+	if open && w.Active >= 0 {
+		if dropdown, exists := w.menuDropdown(w.Active); exists {
+			shown = w.slotAction(w.Active)
+			if w.EditorState != nil {
+				w.updateEnabledStates(dropdown, w.EditorState())
+			}
+
+			// Calculate dropdown position
+			dropdownX, dropdownY := w.dropdownOrigin(w.Active)
+			dropdown.minY = w.Y
+			dropdown.flipOffset = 1 // Above the bottom of the menu's row
+			dropdown.anchorWidth = 0
+			if w.Orientation == Horizontal {
+				dropdown.minY = dropdownY // Never over the menu bar
+				dropdown.flipOffset = -1
+				dropdown.anchorWidth = w.slotWidth(w.shownSlot(w.Active))
+			}
+			dropdown.AnimateOpen = config.GetGlobalOption("menuanimate").(bool)
+			dropdown.Notch = config.GetGlobalOption("menunotch").(bool)
+			dropdown.UseASCIIBorders = asciiBordersWanted()
+			dropdown.AllowHighlightDisabled = config.GetGlobalOption("menuhighlightdisabled").(bool)
+			dropdown.RememberSelection = config.GetGlobalOption("menurememberselection").(bool)
+			path := ""
+			if w.Active < len(w.MenuItems) {
+				path = w.translate(w.MenuItems[w.Active].Name)
+			}
+			w.configureDropdown(dropdown, path)
+			dropdown.Show(dropdownX, dropdownY)
+			dropdown.KeyboardFocus = w.keyboardFocus
+		}
+	} else {
+		// Hide all dropdown menus
+		for _, dropdown := range w.dropdownMenus {
+			dropdown.Hide()
+		}
+		if w.overflowMenu != nil {
+			w.overflowMenu.Hide()
+		}
+	}
+
+	if shown != w.openAction {
+		if w.openAction != "" && w.OnMenuClose != nil {
+			w.OnMenuClose(w.openAction)
+		}
+		w.openAction = shown
+		if shown != "" && w.OnMenuOpen != nil {
+			w.OnMenuOpen(shown)
+		}
+	}
+}
+
+// slotAction returns the action of the menu with the given index, or
+// OverflowAction for the overflow button
+func (w *MenuWindow) slotAction(index int) string {
+	if index == w.overflowIndex() {
+		return OverflowAction
+	}
+	return w.MenuItems[index].Action
+}
+
+// OpenMenu opens the dropdown of the enabled menu with the given action,
+// as if it was clicked. It returns false if there is no such menu
+func (w *MenuWindow) OpenMenu(menuAction string) bool {
+	i := w.menuIndex(menuAction)
+	if i < 0 || !w.MenuItems[i].Enabled {
+		return false
+	}
+	w.SetActive(i)
+	w.SetOpen(true)
+	return true
+}
+
+// SelectDropdownItem highlights the item with the given action in the
+// dropdown of the open menu, opening the submenus leading to it, and
+// returns it. Nil is returned if no menu is open or the item can't be
+// highlighted, for example because it is disabled or filtered out
+func (w *MenuWindow) SelectDropdownItem(action string) *DropdownItem {
+	dropdown := w.GetActiveDropdown()
+	if dropdown == nil || !dropdown.IsVisible() {
+		return nil
+	}
+	return dropdown.selectAction(action)
+}
+
+// OpenByPrefix opens the first enabled menu, in bar order, whose name
+// starts with the given prefix (ignoring case). It returns whether a
+// matching menu was found
+func (w *MenuWindow) OpenByPrefix(prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+	prefix = strings.ToLower(prefix)
+	for i, item := range w.MenuItems {
+		if item.Enabled && strings.HasPrefix(strings.ToLower(w.translate(item.Name)), prefix) {
+			w.keyboardFocus = true
+			w.SetActive(i)
+			w.SetOpen(true)
+			return true
+		}
+	}
+	return false
+}
+
+// menuItemWidth returns the width of a menu on the menu bar
+func (w *MenuWindow) menuItemWidth(item MenuItem) int {
+	return textWidth(w.translate(item.Name)) + 2*w.ItemPadding
+}
+
+// overflowText is the label of the button holding the menus that don't
+// fit on the menu bar, and compactText and compactASCIIText the labels of
+// the button holding all of them in compact mode
+const (
+	overflowText     = "»"
+	compactText      = "☰ Menu"
+	compactASCIIText = "Menu"
+)
+
+// overflowLabel returns the label of the overflow button
+func (w *MenuWindow) overflowLabel() string {
+	if !w.compact {
+		return overflowText
+	}
+	if asciiBordersWanted() {
+		return compactASCIIText
+	}
+	return compactText
+}
+
+// overflowIndex returns the index standing for the overflow button, which
+// comes after the indices of the menus
+func (w *MenuWindow) overflowIndex() int {
+	return len(w.MenuItems)
+}
+
+// slotWidth returns the width of the menu with the given index on the menu
+// bar, or of the overflow button
+func (w *MenuWindow) slotWidth(index int) int {
+	if index == w.overflowIndex() {
+		return textWidth(w.overflowLabel()) + 2*w.ItemPadding
+	}
+	return w.menuItemWidth(w.MenuItems[index])
+}
+
+// layout returns the X position of every menu on the menu bar, or -1 for
+// menus that are disabled or don't fit, followed by the position of the
+// overflow button, which is -1 unless some menus don't fit. Right-aligned
+// menus are placed against the right edge first, and left-aligned menus
+// that would collide with them are moved into the overflow button. In
+// compact mode the overflow button holds all menus
+func (w *MenuWindow) layout() []int {
+	xs := make([]int, len(w.MenuItems)+1)
+	for i := range xs {
+		xs[i] = -1
+	}
+	if w.compact {
+		if w.slotWidth(w.overflowIndex()) <= w.Width {
+			xs[w.overflowIndex()] = 0
+		}
+		return xs
+	}
+	if w.Orientation == Vertical {
+		w.layoutVertical(xs)
+		return xs
+	}
+	overflow := false
+
+	// Right-aligned menus keep their order, so lay them out backwards
+	right := w.Width
+	for i := len(w.MenuItems) - 1; i >= 0; i-- {
+		item := w.MenuItems[i]
+		if !item.Enabled || item.Align != AlignRight {
+			continue
+		}
+		width := w.menuItemWidth(item)
+		if right-width < 0 {
+			overflow = true
+			break
+		}
+		right -= width
+		xs[i] = right
+	}
+
+	x := 0
+	var placed []int
+	for i, item := range w.MenuItems {
+		if !item.Enabled || item.Align == AlignRight {
+			continue
+		}
+		width := w.menuItemWidth(item)
+		if x+width > right {
+			overflow = true
+			break
+		}
+		xs[i] = x
+		x += width
+		placed = append(placed, i)
+	}
+
+	// Make room for the overflow button after the left-aligned menus
+	if overflow {
+		width := w.slotWidth(w.overflowIndex())
+		for x+width > right && len(placed) > 0 {
+			last := placed[len(placed)-1]
+			placed = placed[:len(placed)-1]
+			x = xs[last]
+			xs[last] = -1
+		}
+		if x+width <= right {
+			xs[w.overflowIndex()] = x
+		}
+	}
+	return xs
+}
+
+// layoutVertical fills in the positions of Vertical menus for layout, which
+// are the rows of the sidebar they are drawn on counted from Y. If there
+// are more menus than rows, the last row holds the overflow button
+func (w *MenuWindow) layoutVertical(xs []int) {
+	var enabled []int
+	for i, item := range w.MenuItems {
+		if item.Enabled {
+			enabled = append(enabled, i)
+		}
+	}
+	if w.Height <= 0 {
+		return
+	}
+	if len(enabled) > w.Height {
+		enabled = enabled[:w.Height-1]
+		xs[w.overflowIndex()] = w.Height - 1
+	}
+	for row, i := range enabled {
+		xs[i] = row
+	}
+}
+
+// sidebarWidth returns the width of the sidebar of Vertical menus, which
+// fits the widest menu
+func (w *MenuWindow) sidebarWidth() int {
+	width := w.slotWidth(w.overflowIndex())
+	for _, item := range w.MenuItems {
+		if item.Enabled {
+			if iw := w.menuItemWidth(item); iw > width {
+				width = iw
+			}
+		}
+	}
+	return width
+}
+
+// hiddenMenus returns the indices of the enabled menus that don't fit on
+// the menu bar, which are listed by the overflow button
+func (w *MenuWindow) hiddenMenus() []int {
+	var hidden []int
+	xs := w.layout()
+	for i, item := range w.MenuItems {
+		if item.Enabled && xs[i] < 0 {
+			hidden = append(hidden, i)
+		}
+	}
+	return hidden
+}
+
+// buildOverflowMenu creates the dropdown of the overflow button, whose
+// items open the dropdowns of the menus that don't fit as submenus
+func (w *MenuWindow) buildOverflowMenu() *DropdownMenu {
+	var items []DropdownItem
+	for _, i := range w.hiddenMenus() {
+		item := w.MenuItems[i]
+		items = append(items, DropdownItem{
+			Text:    item.Name,
+			Action:  item.Action,
+			Hotkey:  item.Hotkey,
+			Enabled: true,
+			Submenu: w.dropdownMenus[item.Action],
+		})
+	}
+	dropdown := NewDropdownMenu()
+	dropdown.SetItems(items)
+	setWrapNavigation(dropdown, w.WrapNavigation)
+	return dropdown
+}
+
+// menuDropdown returns the dropdown of the menu with the given index, or of
+// the overflow button
+func (w *MenuWindow) menuDropdown(index int) (*DropdownMenu, bool) {
+	if index == w.overflowIndex() {
+		return w.overflowMenu, w.overflowMenu != nil
+	}
+	if index < 0 || index >= len(w.MenuItems) {
+		return nil, false
+	}
+	dropdown, exists := w.dropdownMenus[w.MenuItems[index].Action]
+	return dropdown, exists
+}
+
+// dropdownOrigin returns where the dropdown of the menu with the given
+// index opens, which is below the menu on a Horizontal bar and to the right
+// of it on a Vertical one. Menus that don't fit open at the overflow button
+func (w *MenuWindow) dropdownOrigin(index int) (x, y int) {
+	if w.Orientation == Horizontal {
+		return w.getMenuItemX(index), w.Y + 1
+	}
+	xs := w.layout()
+	row := 0
+	if index >= 0 && index < len(xs) && xs[index] >= 0 {
+		row = xs[index]
+	} else if xs[w.overflowIndex()] >= 0 {
+		row = xs[w.overflowIndex()]
+	}
+	return w.sidebarWidth(), w.Y + row
+}
+
+// shownSlot returns the index of the menu or overflow button on the bar
+// that the menu with the given index is opened from, which is the overflow
+// button for menus that don't fit
+func (w *MenuWindow) shownSlot(index int) int {
+	if index >= 0 && index < len(w.MenuItems) && w.layout()[index] < 0 {
+		return w.overflowIndex()
+	}
+	return index
+}
+
+// getMenuItemX calculates the X position of a menu item. Menus that don't
+// fit on the bar are opened below the overflow button
+func (w *MenuWindow) getMenuItemX(index int) int {
+	if index < 0 || index > len(w.MenuItems) {
+		return 0
+	}
+	if w.Orientation == Vertical {
+		x, _ := w.dropdownOrigin(index)
+		return x
+	}
+	xs := w.layout()
+	if xs[index] >= 0 {
+		return xs[index]
+	}
+	if x := xs[w.overflowIndex()]; x >= 0 {
+		return x
+	}
+	return 0
+}
+
+// Display renders the menu bar. It does nothing before the screen is
+// initialized
+func (w *MenuWindow) Display() {
+	if screen.Screen == nil {
+		return
+	}
+	w.draw(safeSetContent)
+
+	// Note: Dropdown menus are now displayed separately in the main event loop
+	// to ensure they appear on top of all other content
+}
+
+// RenderToCells draws the menu bar and its open dropdown into a grid of the
+// given size instead of the screen
+func (w *MenuWindow) RenderToCells(width, height int) [][]Cell {
+	cells, set := newCellGrid(width, height)
+	w.draw(set)
+	if dropdown := w.GetActiveDropdown(); dropdown != nil {
+		dropdown.draw(set, width, height)
+	}
+	return cells
+}
+
+// draw renders the menu bar with set
+func (w *MenuWindow) draw(set setContentFunc) {
+	if w.resized {
+		w.relayout()
+	}
+
+	// A clicked menu is drawn pressed for a single frame
+	pressed := w.pressed
+	w.pressed = false
+
+	showMnemonics := w.mnemonicsShown()
+	if dropdown := w.GetActiveDropdown(); dropdown != nil {
+		setHideMnemonics(dropdown, !showMnemonics)
+	}
+
+	if w.Height <= 0 || !w.Visible {
+		return
+	}
+
+	theme := CurrentMenuTheme()
+	barStyle := theme.Bar
+	activeStyle := theme.BarActive
+	if pressed {
+		activeStyle = theme.BarPressed
+	}
+	if w.disabled {
+		barStyle = barStyle.Dim(true)
+	}
+
+	// Clear the menu bar area
+	if w.Orientation == Vertical {
+		fillRect(set, 0, w.Y, w.sidebarWidth(), w.Height, barStyle)
+	} else {
+		fillRect(set, 0, w.Y, w.Width, 1, barStyle)
+	}
+
+	xs := w.layout()
+	for i, item := range w.MenuItems {
+		// Skip menus that are disabled or don't fit
+		if xs[i] < 0 {
+			continue
+		}
+		x, y, width := w.slotRect(xs, i)
+
+		// Calculate item display text
+		displayText := w.translate(item.Name)
+
+		// Determine style based on active state
+		style := barStyle
+		if i == w.Active {
+			// Highlight active menu item
+			style = activeStyle
+		}
+
+		// Render the menu item text with hotkey highlighting, between
+		// the padding
+		underline := -1
+		if showMnemonics && item.Hotkey != 0 {
+			underline = hotkeyIndex(displayText, item.Hotkey)
+		}
+		fillRect(set, x, y, width, 1, style)
+		drawText(set, x+w.ItemPadding, y, x+width, displayText, style, underline)
+	}
+
+	// The overflow button is highlighted while any of its menus is open
+	if xs[w.overflowIndex()] >= 0 {
+		x, y, width := w.slotRect(xs, w.overflowIndex())
+		style := barStyle
+		if w.Active == w.overflowIndex() || (w.Active >= 0 && w.Active < len(w.MenuItems) && xs[w.Active] < 0) {
+			style = activeStyle
+		}
+		fillRect(set, x, y, width, 1, style)
+		drawText(set, x+w.ItemPadding, y, x+width, w.overflowLabel(), style, -1)
+	}
+}
+
+// fillRect fills the given area with blanks of the given style
+func fillRect(set setContentFunc, x, y, width, height int, style tcell.Style) {
+	for row := y; row < y+height; row++ {
+		for col := x; col < x+width; col++ {
+			set(col, row, ' ', nil, style)
+		}
+	}
+}
+
+// HandleClick handles mouse clicks on the menu bar and dropdowns. It
+// returns the choice of the dropdown item that was clicked, if any, see
+// Choice, and whether the click
+// was consumed by the menus, in which case it must not be passed on to the
+// editor. Clicks on the bar or inside a dropdown, and clicks that close an
+// open menu, are consumed even if no item was chosen. Middle-clicking an
+// item or clicking it with a modifier held chooses its AltAction, if it has
+// one, in which case the returned item is a copy running AltAction
+func (w *MenuWindow) HandleClick(x, y int, button tcell.ButtonMask, mod tcell.ModMask) (*Choice, bool) {
+	if w.disabled {
+		return nil, false
+	}
+	consumed := false
+
+	// First check if click is on an open dropdown
+	if w.open {
+		if dropdown, exists := w.menuDropdown(w.Active); exists && dropdown.IsVisible() {
+			inside := dropdown.containsTree(x, y)
+			if clickedItem := dropdown.HandleClick(x, y); clickedItem != nil {
+				// A dropdown item was clicked - return it for execution
+				if clickedItem.AltAction != "" && (button&tcell.Button3 != 0 || mod&(tcell.ModCtrl|tcell.ModAlt|tcell.ModShift) != 0) {
+					variant := *clickedItem
+					variant.Action, variant.URL = variant.AltAction, ""
+					clickedItem = &variant
+				}
+				return w.chosen(clickedItem, 1), true
+			}
+			if inside {
+				// Clicks on borders, separators or submenu items keep the menu open
+				return nil, true
+			}
+			// Click might have closed the dropdown, check if we should handle menu bar click
+			if !dropdown.IsVisible() {
+				w.SetActive(-1)
+				w.SetOpen(false)
+				consumed = true
+			}
+		}
+	}
+
+	// Check if click is on menu bar
+	if !w.onBar(x, y) {
+		// Click outside menu bar and dropdown - close any open menu
+		if w.open {
+			w.SetActive(-1)
+			w.SetOpen(false)
+			consumed = true
+		}
+		return nil, consumed
+	}
+
+	// Calculate which menu item was clicked
+	if i := w.slotAt(x, y); i >= 0 {
+		if w.Active == i && w.open {
+			// Close if clicking on already open menu
+			w.SetActive(-1)
+			w.SetOpen(false)
+		} else {
+			// Activate and open menu
+			w.keyboardFocus = false
+			w.SetActive(i)
+			w.SetOpen(true)
+			w.pressed = w.open
+		}
+		return nil, true
+	}
+
+	// Click outside menu items - close any open menu
+	w.SetActive(-1)
+	w.SetOpen(false)
+	return nil, true
+}
+
+// HandleMotion handles the mouse pointer moving to the given point without
+// a button pressed, which terminals only report while asked to, see
+// WantsMotion. While a menu is open, moving over another menu on the bar
+// opens that one instead and moving over the dropdown highlights the item
+// under the pointer. It returns whether a menu is open, in which case the
+// event should not be handled further
+func (w *MenuWindow) HandleMotion(x, y int) bool {
+	if w.disabled || !w.open {
+		w.dwell.reset()
+		return false
+	}
+	if dropdown, exists := w.menuDropdown(w.Active); exists && dropdown.IsVisible() {
+		dropdown.HandleHover(x, y)
+	}
+	if i := w.slotAt(x, y); i >= 0 && i != w.Active {
+		if w.dwell.rested(i, w.HoverDelayMs) {
+			w.switchOnHover(i)
+		}
+	} else {
+		w.dwell.reset()
+	}
+	return true
+}
+
+// WantsMotion returns whether the menus need to be told about the mouse
+// pointer moving without a button pressed, see HandleMotion
+func (w *MenuWindow) WantsMotion() bool {
+	return !w.disabled && w.open
+}
+
+// slotAt returns the index of the menu or overflow button on the menu bar
+// at the given point, or -1 if there is none
+func (w *MenuWindow) slotAt(x, y int) int {
+	if !w.onBar(x, y) {
+		return -1
+	}
+	xs := w.layout()
+	for i := range xs {
+		if xs[i] < 0 {
+			continue
+		}
+		if sx, sy, width := w.slotRect(xs, i); y == sy && x >= sx && x < sx+width {
+			return i
+		}
+	}
+	return -1
+}
+
+// onBar returns whether the given point lies on the shown menu bar, or on
+// the sidebar for Vertical menus
+func (w *MenuWindow) onBar(x, y int) bool {
+	if !w.Visible {
+		return false
+	}
+	if w.Orientation == Vertical {
+		return x >= 0 && x < w.sidebarWidth() && y >= w.Y && y < w.Y+w.Height
+	}
+	return y == w.Y
+}
+
+// HitTest returns whether the given point lies on the menu bar or within
+// the open dropdown, its open submenus or their shadows, that is whether a
+// mouse event there belongs to the menus
+func (w *MenuWindow) HitTest(x, y int) bool {
+	if w.onBar(x, y) || w.openDropdownAt(x, y) != nil {
+		return true
+	}
+	for d := w.GetActiveDropdown(); d != nil && d.IsVisible(); d = d.ActiveSubmenu() {
+		if d.shadowContains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// slotRect returns the position and width of the menu or overflow button
+// with the given index on the bar, given the positions returned by layout
+func (w *MenuWindow) slotRect(xs []int, index int) (x, y, width int) {
+	if w.Orientation == Vertical {
+		return 0, w.Y + xs[index], w.sidebarWidth()
+	}
+	return xs[index], w.Y, w.slotWidth(index)
+}
+
+// openDropdownAt returns the open dropdown if the given point lies within
+// it or one of its submenus
+func (w *MenuWindow) openDropdownAt(x, y int) *DropdownMenu {
+	if !w.open {
+		return nil
+	}
+	if dropdown, exists := w.menuDropdown(w.Active); exists && dropdown.IsVisible() && dropdown.containsTree(x, y) {
+		return dropdown
+	}
+	return nil
+}
+
+// HandleMouseDown handles a mouse button being pressed at the given point.
+// Pressing on a menu opens it and pressing on a menu or an open dropdown
+// starts a drag, which HandleMouseDrag and HandleMouseUp continue. Pressing
+// elsewhere closes any open menu. It returns whether the press was consumed
+func (w *MenuWindow) HandleMouseDown(x, y int) bool {
+	w.dragging = false
+	if w.disabled {
+		return false
+	}
+	if w.openDropdownAt(x, y) != nil {
+		w.dragging = true
+		w.closeOnUp = false
+		return true
+	}
+	if i := w.slotAt(x, y); i >= 0 {
+		w.dragging = true
+		w.closeOnUp = w.open && w.Active == i
+		if !w.closeOnUp {
+			w.keyboardFocus = false
+			w.SetActive(i)
+			w.SetOpen(true)
+			w.pressed = w.open
+		}
+		return true
+	}
+	if w.open {
+		w.SetActive(-1)
+		w.SetOpen(false)
+		return true
+	}
+	return false
+}
+
+// HandleMouseDrag handles the pointer moving with the button held after
+// HandleMouseDown. Dragging over another menu opens it and dragging over
+// a dropdown highlights the item under the pointer. It returns whether
+// a drag is in progress
+func (w *MenuWindow) HandleMouseDrag(x, y int) bool {
+	if !w.dragging {
+		return false
+	}
+	if i := w.slotAt(x, y); i >= 0 {
+		if i != w.Active {
+			w.closeOnUp = false
+			w.keyboardFocus = false
+			w.SetActive(i)
+			w.SetOpen(true)
+		}
+	} else if dropdown := w.openDropdownAt(x, y); dropdown != nil {
+		w.closeOnUp = false
+		dropdown.HandleHover(x, y)
+	}
+	return true
+}
+
+// HandleMouseUp handles the button being released at the given point after
+// HandleMouseDown. Releasing on an item of the open dropdown chooses it
+// and returns it, releasing on the menu that was open when the button was
+// pressed closes it, and releasing off the menus closes them. It returns
+// whether the release ended a drag
+func (w *MenuWindow) HandleMouseUp(x, y int) (*Choice, bool) {
+	if !w.dragging {
+		return nil, false
+	}
+	w.dragging = false
+
+	if dropdown := w.openDropdownAt(x, y); dropdown != nil {
+		if item := dropdown.HandleClick(x, y); item != nil {
+			return w.chosen(item, 1), true
+		}
+		return nil, true
+	}
+	if i := w.slotAt(x, y); i >= 0 {
+		if w.closeOnUp && i == w.Active {
+			w.SetActive(-1)
+			w.SetOpen(false)
+		}
+		return nil, true
+	}
+	w.SetActive(-1)
+	w.SetOpen(false)
+	return nil, true
+}
+
+// IsDragging returns whether a mouse button pressed on the menus is held
+func (w *MenuWindow) IsDragging() bool {
+	return w.dragging
+}
+
+// SetModalActive marks whether a modal prompt is currently shown. While
+// it is, the menu bar ignores keyboard activation so that the prompt
+// receives the keys instead
+func (w *MenuWindow) SetModalActive(modal bool) {
+	w.modal = modal
+}
+
+// SetEnabled turns the menu bar on or off as a whole. A disabled bar stays
+// visible but dimmed, and ignores clicks and keys until it is enabled
+// again, so that it doesn't interfere with modal dialogs. Disabling it
+// closes the open menu
+func (w *MenuWindow) SetEnabled(enabled bool) {
+	w.disabled = !enabled
+	if w.disabled {
+		w.CloseAll()
+	}
+}
+
+// Enabled returns whether the menu bar responds to input, see SetEnabled
+func (w *MenuWindow) Enabled() bool {
+	return !w.disabled
+}
+
+// matchesHotkey returns whether key activates the given hotkey, ignoring
+// case so that a hotkey works with Shift held or Caps Lock on
+func matchesHotkey(key, hotkey rune) bool {
+	return hotkey != 0 && unicode.ToLower(key) == unicode.ToLower(hotkey)
+}
+
+// hotkeyMenuIndex returns the index of the enabled menu item whose hotkey
+// matches key, or -1 if there is none
+func (w *MenuWindow) hotkeyMenuIndex(key rune) int {
+	for i, item := range w.MenuItems {
+		if item.Enabled && matchesHotkey(key, item.Hotkey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hotkeysConflict returns whether a key could activate both hotkeys
+func hotkeysConflict(a, b rune) bool {
+	return unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// ValidateHotkeys returns an error for every hotkey shared by two menus on
+// the menu bar or by two items of the same dropdown, which would make the
+// second one unreachable
+func (w *MenuWindow) ValidateHotkeys() []error {
+	var errs []error
+	for i, item := range w.MenuItems {
+		if item.Hotkey == 0 {
+			continue
+		}
+		for _, other := range w.MenuItems[:i] {
+			if other.Hotkey != 0 && hotkeysConflict(item.Hotkey, other.Hotkey) {
+				errs = append(errs, errors.New("Hotkey "+string(item.Hotkey)+" of menu "+item.Name+" conflicts with menu "+other.Name))
+				break
+			}
+		}
+	}
+	for _, item := range w.MenuItems {
+		if dropdown, exists := w.dropdownMenus[item.Action]; exists {
+			errs = append(errs, validateDropdownHotkeys(item.Name, dropdown)...)
+		}
+	}
+	return errs
+}
+
+// validateNoCycles walks the submenus of every menu and detaches the ones
+// that contain the dropdown they are attached to, which would make drawing
+// and navigating the menus recurse forever. The returned error names the
+// first item whose submenu was detached
+func (w *MenuWindow) validateNoCycles() error {
+	var err error
+	open := make(map[*DropdownMenu]bool)
+	done := make(map[*DropdownMenu]bool)
+	for _, m := range w.MenuItems {
+		if dropdown, exists := w.dropdownMenus[m.Action]; exists {
+			if e := breakCycles(dropdown, m.Name, open, done); err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// breakCycles detaches the submenus of the dropdown and of its submenus
+// that lead back to one of the open dropdowns, the ones on the path from
+// the menu bar to it. Dropdowns that are done have already been checked
+func breakCycles(d *DropdownMenu, path string, open, done map[*DropdownMenu]bool) error {
+	if done[d] {
+		return nil
+	}
+	var err error
+	open[d] = true
+	for i := range d.Items {
+		item := &d.Items[i]
+		if item.Submenu == nil {
+			continue
+		}
+		itemPath := path + menuPathSeparator + item.Text
+		if open[item.Submenu] {
+			item.Submenu = nil
+			d.Invalidate()
+			if err == nil {
+				err = errors.New("Menu item " + itemPath + " (" + item.Action + ") has a submenu containing itself")
+			}
+		} else if e := breakCycles(item.Submenu, itemPath, open, done); err == nil {
+			err = e
+		}
+	}
+	delete(open, d)
+	done[d] = true
+	return err
+}
+
+// validateDropdownHotkeys returns an error for every hotkey shared by two
+// items of the dropdown or of one of its submenus
+func validateDropdownHotkeys(menu string, d *DropdownMenu) []error {
+	var errs []error
+	for i, item := range d.Items {
+		if item.Separator {
+			continue
+		}
+		if item.Hotkey != 0 {
+			for _, other := range d.Items[:i] {
+				if !other.Separator && other.Hotkey != 0 && hotkeysConflict(item.Hotkey, other.Hotkey) {
+					errs = append(errs, errors.New("Hotkey "+string(item.Hotkey)+" of "+item.Text+" conflicts with "+other.Text+" in menu "+menu))
+					break
+				}
+			}
+		}
+		if item.Submenu != nil {
+			errs = append(errs, validateDropdownHotkeys(menu+" > "+item.Text, item.Submenu)...)
+		}
+	}
+	return errs
+}
+
+// AutoAssignHotkeys gives every menu and dropdown item without a hotkey
+// the first letter of its text that no other menu or item next to it uses
+func (w *MenuWindow) AutoAssignHotkeys() {
+	used := make([]rune, 0, len(w.MenuItems))
+	for _, item := range w.MenuItems {
+		if item.Hotkey != 0 {
+			used = append(used, item.Hotkey)
+		}
+	}
+	for i := range w.MenuItems {
+		item := &w.MenuItems[i]
+		if item.Hotkey == 0 {
+			item.Hotkey = unusedHotkey(item.Name, used)
+			if item.Hotkey != 0 {
+				used = append(used, item.Hotkey)
+			}
+		}
+		if dropdown, exists := w.dropdownMenus[item.Action]; exists {
+			autoAssignDropdownHotkeys(dropdown)
+		}
+	}
+}
+
+// autoAssignDropdownHotkeys assigns hotkeys to the items of the dropdown
+// and its submenus, see AutoAssignHotkeys
+func autoAssignDropdownHotkeys(d *DropdownMenu) {
+	used := make([]rune, 0, len(d.Items))
+	for _, item := range d.Items {
+		if !item.Separator && item.Hotkey != 0 {
+			used = append(used, item.Hotkey)
+		}
+	}
+	for i := range d.Items {
+		item := &d.Items[i]
+		if item.Separator {
+			continue
+		}
+		if item.Hotkey == 0 {
+			item.Hotkey = unusedHotkey(item.Text, used)
+			if item.Hotkey != 0 {
+				used = append(used, item.Hotkey)
+			}
+		}
+		if item.Submenu != nil {
+			autoAssignDropdownHotkeys(item.Submenu)
+		}
+	}
+}
+
+// unusedHotkey returns the first letter of text that conflicts with none
+// of the used hotkeys, or 0 if there is none
+func unusedHotkey(text string, used []rune) rune {
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		free := true
+		for _, u := range used {
+			if hotkeysConflict(r, u) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return r
+		}
+	}
+	return 0
+}
+
+// WantsKey returns whether the menu would handle the given key
+func (w *MenuWindow) WantsKey(key rune) bool {
+	if w.disabled {
+		return false
+	}
+	if w.open {
+		return true
+	}
+	return !w.modal && w.hotkeyMenuIndex(key) >= 0
+}
+
+// HandleWheel scrolls the open dropdown under the given point by the given
+// number of lines. It returns whether the wheel event was used
+func (w *MenuWindow) HandleWheel(x, y, lines int) bool {
+	if dropdown := w.GetActiveDropdown(); dropdown != nil && dropdown.IsVisible() {
+		return dropdown.HandleWheel(x, y, lines)
+	}
+	return false
+}
+
+// HandleKey handles keyboard input for menu navigation. A key only opens a
+// menu if it is pressed with Alt, or if the menu bar has keyboard focus, so
+// that plain typing never opens a menu
+func (w *MenuWindow) HandleKey(key rune, mod tcell.ModMask) bool {
+	if w.disabled || w.modal || (mod&tcell.ModAlt == 0 && !w.focused) {
+		return false
+	}
+
+	// Check for hotkey matches
+	if i := w.hotkeyMenuIndex(key); i >= 0 {
+		w.keyboardFocus = true
+		w.SetActive(i)
+		w.SetOpen(true)
+		return true
+	}
+
+	return false
+}
+
+// HandleKeyNavigation handles keyboard navigation for menu and dropdown.
+// While a dropdown is open, Alt with the hotkey of another menu switches to
+// that menu and plain keys choose the dropdown's items. It returns the
+// chosen item, if any, and whether the key was consumed. An open menu
+// consumes every key, while a closed one only consumes the hotkeys opening
+// menus and, while the bar is focused, the keys moving between menus. Any
+// other key gives focus back to the editor, which should handle the key
+func (w *MenuWindow) HandleKeyNavigation(key rune, keyCode int, mod tcell.ModMask) (choice *Choice, consumed bool) {
+	if w.disabled {
+		return nil, false
+	}
+
+	// If no menu is active, check for Alt+hotkey combinations
+	if !w.open || w.Active < 0 {
+		if w.focused && w.Active >= 0 {
+			// The bar is focused but closed, navigate between menus along
+			// the bar and open them towards their dropdowns
+			prevKey, nextKey, openKey := tcell.KeyLeft, tcell.KeyRight, tcell.KeyDown
+			if w.Orientation == Vertical {
+				prevKey, nextKey, openKey = tcell.KeyUp, tcell.KeyDown, tcell.KeyRight
+			}
+			switch keyCode {
+			case int(prevKey):
+				w.navigateToPreviousMenu()
+				return nil, true
+			case int(nextKey):
+				w.navigateToNextMenu()
+				return nil, true
+			case int(openKey), int(tcell.KeyEnter):
+				w.keyboardFocus = true
+				w.SetOpen(true)
+				return nil, true
+			case int(tcell.KeyEscape):
+				w.SetActive(-1)
+				return nil, true
+			}
+		}
+
+		// Check for hotkey matches to open menus
+		if w.HandleKey(key, mod) {
+			return nil, true
+		}
+		if w.focused {
+			w.CloseAll()
+		}
+		return nil, false
+	}
+
+	// If a menu is open, handle dropdown navigation
+	if w.Active >= 0 {
+		if top, exists := w.menuDropdown(w.Active); exists && top.IsVisible() {
+			w.keyboardFocus = true
+			top.KeyboardFocus = true
+
+			// Only Escape works until the dropdown has rolled down
+			if top.Revealing() && keyCode != int(tcell.KeyEscape) {
+				return nil, true
+			}
+
+			// Keys go to the deepest expanded submenu
+			dropdown := top.FocusedMenu()
+
+			// Use tcell key constants for proper key detection
+			switch keyCode {
+			case int(tcell.KeyEnter):
+				if dropdown.ToggleExpanded() || dropdown.ExpandSubmenu() {
+					return nil, true
+				}
+				selectedItem := dropdown.GetActiveItem()
+				if selectedItem != nil && selectedItem.Enabled && !selectedItem.Separator {
+					count := w.repeatCount()
+					dropdown.choose(selectedItem)
+					return w.chosen(selectedItem, count), true
+				}
+			case int(tcell.KeyEscape):
+				// The first press clears a typed filter
+				if dropdown.Filter() != "" {
+					dropdown.SetFilter("")
+					return nil, true
+				}
+				w.SetActive(-1)
+				w.SetOpen(false)
+				return nil, true
+			case int(tcell.KeyBackspace), int(tcell.KeyBackspace2):
+				if filter := []rune(dropdown.Filter()); len(filter) > 0 {
+					dropdown.SetFilter(string(filter[:len(filter)-1]))
+				}
+				return nil, true
+			case int(tcell.KeyUp):
+				dropdown.MoveUp()
+				return nil, true
+			case int(tcell.KeyDown):
+				dropdown.MoveDown()
+				return nil, true
+			case int(tcell.KeyHome):
+				dropdown.MoveToFirst()
+				return nil, true
+			case int(tcell.KeyEnd):
+				dropdown.MoveToLast()
+				return nil, true
+			case int(tcell.KeyPgUp):
+				dropdown.MovePageUp()
+				return nil, true
+			case int(tcell.KeyPgDn):
+				dropdown.MovePageDown()
+				return nil, true
+			case int(tcell.KeyLeft):
+				if !top.CollapseSubmenu() && !dropdown.MoveLeft() {
+					w.navigateToPreviousMenu()
+				}
+				return nil, true
+			case int(tcell.KeyRight):
+				if !dropdown.ExpandSubmenu() && !dropdown.MoveRight() {
+					w.navigateToNextMenu()
+				}
+				return nil, true
+			default:
+				// Alt may still be held from opening the menu, so Alt+letter
+				// chooses items like the letter alone does. It switches to
+				// another menu only if no item has the hotkey, and doesn't
+				// type into the filter or the repeat count
+				alt := mod&tcell.ModAlt != 0
+
+				// Typing in long dropdowns filters their items
+				if !alt && dropdown.Filterable() && keyCode == int(tcell.KeyRune) && unicode.IsPrint(key) {
+					dropdown.SetFilter(dropdown.Filter() + string(key))
+					return nil, true
+				}
+
+				// Digits build up a repeat count for the next selection
+				if !alt && key >= '0' && key <= '9' && (w.count > 0 || key != '0') {
+					if w.count < maxRepeatCount {
+						w.count = w.count*10 + int(key-'0')
+					}
+					return nil, true
+				}
+
+				// Check for dropdown item hotkeys
+				for i := range dropdown.Items {
+					item := &dropdown.Items[i]
+					if !item.Separator && item.Enabled {
+						if matchesHotkey(key, item.Hotkey) {
+							if item.Submenu != nil {
+								dropdown.Active = i
+								dropdown.ExpandSubmenu()
+								return nil, true
+							}
+							count := w.repeatCount()
+							dropdown.Active = i
+							dropdown.choose(item)
+							return w.chosen(item, count), true
+						}
+					}
+				}
+
+				if alt {
+					if i := w.hotkeyMenuIndex(key); i >= 0 && i != w.Active {
+						w.SetActive(i)
+						w.SetOpen(true)
+					}
+					return nil, true
+				}
+
+				// Without a matching hotkey jump to the next item
+				// starting with the typed letter
+				if unicode.IsPrint(key) {
+					dropdown.MoveToLetter(key)
+				}
+			}
+		}
+	}
+
+	return nil, true
+}
+
+// SetActionRunner sets the function that runs the actions of selected
+// items. Actions may be full command lines with arguments, such as
+// "set colorscheme monokai", which are passed to the runner verbatim
+func (w *MenuWindow) SetActionRunner(runner func(cmdline string) error) {
+	w.actionRunner = runner
+}
+
+// RunAction runs the given action with the action runner
+func (w *MenuWindow) RunAction(cmdline string) error {
+	if w.actionRunner == nil {
+		return errors.New("No action runner for " + cmdline)
+	}
+	return w.actionRunner(cmdline)
+}
+
+// ActionChan returns a channel receiving the action of every dropdown item
+// chosen with the mouse or the keyboard, in addition to the item being
+// returned by HandleClick and HandleKeyNavigation. The channel is created
+// by the first call, so that nothing is buffered for it before anyone
+// listens, and items chosen before then aren't sent. Actions are dropped
+// if the channel's buffer is full
+func (w *MenuWindow) ActionChan() <-chan string {
+	if w.actions == nil {
+		w.actions = make(chan string, actionChanSize)
+	}
+	return w.actions
+}
+
+// chosen closes the menu after the given item was chosen from it, unless
+// the item is KeepOpenOnSelect, emits the item's action and returns the
+// choice to run it with the given repeat count
+func (w *MenuWindow) chosen(item *DropdownItem, count int) *Choice {
+	if !item.KeepOpenOnSelect {
+		w.SetActive(-1)
+		w.SetOpen(false)
+	}
+	w.emitAction(item)
+	if !item.Repeatable {
+		count = 1
+	}
+	choice := &Choice{DropdownItem: item, Count: count}
+	if w.EditorState != nil {
+		if state := w.EditorState(); state.HasSelection {
+			choice.Selection = state.Selection
+		}
+	}
+	return choice
+}
+
+// emitAction remembers the action of a selected item for LastAction and
+// sends it to ActionChan without blocking
+func (w *MenuWindow) emitAction(item *DropdownItem) {
+	// Items opening a URL can't be run by action, items asking for input
+	// need it again, and repeating the repeat item would only run itself
+	if item.URL == "" && !item.NeedsInput() && item.Action != RepeatLastAction {
+		w.lastAction = item.Action
+		w.lastText = item.Text
+		for _, dropdown := range w.dropdownMenus {
+			w.updateRepeatItems(dropdown)
+		}
+		w.indexActions()
+	}
+
+	// Choosing a radio item unchecks the other items of its group
+	if item.RadioGroup != "" {
+		for _, dropdown := range w.dropdownMenus {
+			dropdown.SetRadioSelection(item.RadioGroup, item.Action)
+		}
+	}
+
+	if w.OnItemSelected != nil {
+		w.OnItemSelected(*item)
+	}
+
+	if w.actions != nil {
+		select {
+		case w.actions <- item.Action:
+		default:
+		}
+	}
+}
+
+// updateRepeatItems labels the items of the dropdown and its submenus that
+// repeat the last action with the text of the last selected item
+func (w *MenuWindow) updateRepeatItems(d *DropdownMenu) {
+	changed := false
+	for i := range d.Items {
+		item := &d.Items[i]
+		if item.Submenu != nil {
+			w.updateRepeatItems(item.Submenu)
+		} else if item.Action == RepeatLastAction && !item.Separator {
+			item.Text = "Repeat: " + w.lastText
+			changed = true
+		}
+	}
+	if changed {
+		d.calculateSize()
+	}
+}
+
+// LastAction returns the action of the item last selected from a dropdown,
+// or an empty string if none has been selected yet
+func (w *MenuWindow) LastAction() string {
+	return w.lastAction
+}
+
+// RepeatLastAction runs the action of the item last selected from a
+// dropdown again with the action runner
+func (w *MenuWindow) RepeatLastAction() error {
+	if w.lastAction == "" {
+		return errors.New("No menu action to repeat")
+	}
+	return w.RunAction(w.lastAction)
+}
+
+// maxRepeatCount bounds the repeat count that can be typed in a dropdown
+const maxRepeatCount = 10000
+
+// repeatCount returns the repeat count typed so far, or 1 if none was typed
+func (w *MenuWindow) repeatCount() int {
+	if w.count <= 0 {
+		return 1
+	}
+	if w.count > maxRepeatCount {
+		return maxRepeatCount
+	}
+	return w.count
+}
+
+// navigateToPreviousMenu moves to the previous menu item
+func (w *MenuWindow) navigateToPreviousMenu() {
+	w.navigateMenus(-1)
+}
+
+// navigateToNextMenu moves to the next menu item
+func (w *MenuWindow) navigateToNextMenu() {
+	w.navigateMenus(1)
+}
+
+// navigateMenus moves dir places along the menus shown on the menu bar,
+// including the overflow button, in the order they appear in. A menu that
+// doesn't fit on the bar counts as the overflow button
+func (w *MenuWindow) navigateMenus(dir int) {
+	xs := w.layout()
+	var slots []int
+	for i, x := range xs {
+		if x >= 0 {
+			slots = append(slots, i)
+		}
+	}
+	if len(slots) == 0 {
+		return
+	}
+	sort.Slice(slots, func(a, b int) bool {
+		return xs[slots[a]] < xs[slots[b]]
+	})
+
+	active := w.Active
+	if active >= 0 && active < len(w.MenuItems) && xs[active] < 0 {
+		active = w.overflowIndex()
+	}
+	pos := -1
+	for p, i := range slots {
+		if i == active {
+			pos = p
+		}
+	}
+	if pos < 0 && dir > 0 {
+		pos = 0
+	} else {
+		if pos < 0 {
+			pos = len(slots)
+		}
+		pos += dir
+		if pos < 0 || pos >= len(slots) {
+			if !w.WrapNavigation {
+				return
+			}
+			pos = (pos + len(slots)) % len(slots)
+		}
+	}
+	w.SetActive(slots[pos])
+	w.SetOpen(w.open)
+}
+
+// OccupiedRegions returns the rectangles covered by the menu bar and any
+// open dropdown and submenus, so that other popups can avoid them
+func (w *MenuWindow) OccupiedRegions() []image.Rectangle {
+	var regions []image.Rectangle
+	if w.Visible && w.Height > 0 {
+		width := w.Width
+		if w.Orientation == Vertical {
+			width = w.sidebarWidth()
+		}
+		regions = append(regions, image.Rect(0, w.Y, width, w.Y+w.Height))
+	}
+	if dropdown := w.GetActiveDropdown(); dropdown != nil {
+		regions = append(regions, dropdown.regions()...)
 	}
+	return regions
 }
 
 // GetMenuAction returns the action for the currently active menu
@@ -451,9 +2127,8 @@ func (w *MenuWindow) GetMenuAction() string {
 
 // GetActiveDropdown returns the currently active dropdown menu
 func (w *MenuWindow) GetActiveDropdown() *DropdownMenu {
-	if w.open && w.Active >= 0 && w.Active < len(w.MenuItems) {
-		activeItem := w.MenuItems[w.Active]
-		if dropdown, exists := w.dropdownMenus[activeItem.Action]; exists {
+	if w.open {
+		if dropdown, exists := w.menuDropdown(w.Active); exists {
 			return dropdown
 		}
 	}
@@ -0,0 +1,1774 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/buffer"
+	"github.com/zyedidia/micro/v2/internal/config"
+	"github.com/zyedidia/micro/v2/internal/screen"
+)
+
+func init() {
+	config.InitGlobalSettings()
+}
+
+func TestSetActiveDisabled(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.MenuItems[2].Enabled = false
+
+	w.SetActive(1)
+	assert.Equal(t, 1, w.GetActive())
+
+	// A disabled menu is rejected and the active menu is kept
+	w.SetActive(2)
+	assert.Equal(t, 1, w.GetActive())
+
+	w.SetActive(-1)
+	w.SetActive(2)
+	assert.Equal(t, -1, w.GetActive())
+
+	w.SetActive(len(w.MenuItems))
+	assert.Equal(t, -1, w.GetActive())
+}
+
+func TestHandleKeyRequiresAlt(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Plain typing never opens a menu
+	for _, r := range "idwsth" {
+		assert.False(t, w.HandleKey(r, tcell.ModNone))
+		assert.False(t, w.IsOpen())
+	}
+	item, consumed := w.HandleKeyNavigation('d', int(tcell.KeyRune), tcell.ModNone)
+	assert.Nil(t, item)
+	assert.False(t, consumed)
+	assert.False(t, w.IsOpen())
+
+	assert.True(t, w.HandleKey('i', tcell.ModAlt))
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, 0, w.GetActive())
+
+	// In an open dropdown plain letters choose items, while Alt with a
+	// menu's hotkey switches menus
+	w.HandleKeyNavigation('d', int(tcell.KeyRune), tcell.ModAlt)
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "edit", w.GetMenuAction())
+	item, consumed = w.HandleKeyNavigation('U', int(tcell.KeyRune), tcell.ModNone)
+	assert.True(t, consumed)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Undo", item.Action)
+	}
+}
+
+func TestFocusNavigation(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	w.ToggleFocus()
+	assert.True(t, w.IsFocused())
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, 0, w.GetActive())
+
+	w.HandleKeyNavigation(0, int(tcell.KeyRight), tcell.ModNone)
+	assert.Equal(t, 1, w.GetActive())
+	assert.False(t, w.IsOpen())
+
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	assert.True(t, w.IsOpen())
+	assert.NotNil(t, w.GetActiveDropdown())
+
+	w.ToggleFocus()
+	assert.False(t, w.IsFocused())
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, -1, w.GetActive())
+
+	config.GlobalSettings["menuautoopen"] = true
+	defer func() { config.GlobalSettings["menuautoopen"] = false }()
+	w.Focus()
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, 0, w.GetActive())
+}
+
+func TestFocusBar(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetMenuEnabled("file", false)
+
+	// The bar is focused without opening a menu, even with menuautoopen
+	config.GlobalSettings["menuautoopen"] = true
+	defer func() { config.GlobalSettings["menuautoopen"] = false }()
+	assert.True(t, w.FocusBar())
+	assert.True(t, w.IsFocused())
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, 1, w.GetActive())
+
+	w.HandleKeyNavigation(0, int(tcell.KeyRight), tcell.ModNone)
+	assert.Equal(t, 2, w.GetActive())
+	w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "view", w.GetMenuAction())
+
+	// Focusing again closes the open menu
+	assert.True(t, w.FocusBar())
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, 1, w.GetActive())
+
+	// Keys the bar has no use for go back to the editor
+	_, consumed := w.HandleKeyNavigation('x', int(tcell.KeyRune), tcell.ModNone)
+	assert.False(t, consumed)
+	assert.False(t, w.IsFocused())
+	assert.Equal(t, -1, w.GetActive())
+	w.FocusBar()
+
+	for _, item := range w.MenuItems {
+		w.SetMenuEnabled(item.Action, false)
+	}
+	w.CloseAll()
+	assert.False(t, w.FocusBar())
+	assert.False(t, w.IsFocused())
+}
+
+func TestSubmenuCycles(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// A submenu containing the menu it's added to is detached
+	err := w.AddDropdownItem("file", DropdownItem{Text: "Loop", Action: "loop", Enabled: true, Submenu: w.dropdownMenus["file"]})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "loop")
+	}
+	file := w.dropdownMenus["file"]
+	assert.Nil(t, file.Items[len(file.Items)-1].Submenu)
+
+	// So is one leading back to it through another submenu
+	inner := NewDropdownMenu()
+	outer := NewDropdownMenu()
+	outer.SetItems([]DropdownItem{{Text: "Inner", Action: "inner", Enabled: true, Submenu: inner}})
+	inner.SetItems([]DropdownItem{{Text: "Outer", Action: "outer", Enabled: true, Submenu: outer}})
+	w.AddMenu(MenuItem{Name: "Cycle", Action: "cycle", Enabled: true},
+		[]DropdownItem{{Text: "Outer", Action: "outer", Enabled: true, Submenu: outer}})
+	assert.Nil(t, inner.Items[0].Submenu)
+	assert.NotNil(t, outer.Items[0].Submenu)
+
+	// A submenu shared by two items is not a cycle
+	shared := NewDropdownMenu()
+	shared.SetItems([]DropdownItem{{Text: "Item", Action: "item", Enabled: true}})
+	assert.NoError(t, w.AddDropdownItem("edit", DropdownItem{Text: "A", Enabled: true, Submenu: shared}))
+	assert.NoError(t, w.AddDropdownItem("edit", DropdownItem{Text: "B", Enabled: true, Submenu: shared}))
+	assert.NoError(t, w.validateNoCycles())
+}
+
+func TestUpdateEnabledStates(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	state := EditorState{CanUndo: true}
+	w.EditorState = func() EditorState { return state }
+
+	w.SetActive(1)
+	w.SetOpen(true)
+	edit := w.GetActiveDropdown()
+	assert.True(t, edit.Items[0].Enabled)  // Undo
+	assert.False(t, edit.Items[1].Enabled) // Redo
+	assert.False(t, edit.Items[5].Enabled) // Paste
+
+	state = EditorState{CanRedo: true, HasClipboard: true}
+	w.SetOpen(true)
+	assert.False(t, edit.Items[0].Enabled)
+	assert.True(t, edit.Items[1].Enabled)
+	assert.True(t, edit.Items[5].Enabled)
+	// The first enabled item is active
+	assert.Equal(t, 1, edit.Active)
+}
+
+func TestFilterKeys(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	items := make([]DropdownItem, filterMinItems)
+	for i := range items {
+		items[i] = DropdownItem{Text: string(rune('a' + i)), Action: string(rune('A' + i)), Enabled: true}
+	}
+	w.dropdownMenus[w.MenuItems[0].Action].SetItems(items)
+	w.SetActive(0)
+	w.SetOpen(true)
+	dropdown := w.GetActiveDropdown()
+
+	w.HandleKeyNavigation('c', int(tcell.KeyRune), tcell.ModNone)
+	assert.Equal(t, "c", dropdown.Filter())
+	assert.Equal(t, 2, dropdown.Active)
+
+	w.HandleKeyNavigation(0, int(tcell.KeyBackspace2), tcell.ModNone)
+	assert.Equal(t, "", dropdown.Filter())
+
+	// Escape clears the filter before closing the menu
+	w.HandleKeyNavigation('d', int(tcell.KeyRune), tcell.ModNone)
+	w.HandleKeyNavigation(0, int(tcell.KeyEscape), tcell.ModNone)
+	assert.Equal(t, "", dropdown.Filter())
+	assert.True(t, w.IsOpen())
+	w.HandleKeyNavigation(0, int(tcell.KeyEscape), tcell.ModNone)
+	assert.False(t, w.IsOpen())
+}
+
+func TestActionChan(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Nothing is buffered before the channel is subscribed to
+	w.SetActive(0)
+	w.SetOpen(true)
+	item, _ := w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	assert.NotNil(t, item)
+	assert.Nil(t, w.actions)
+	actions := w.ActionChan()
+	assert.Len(t, actions, 0)
+	assert.Equal(t, actions, w.ActionChan())
+
+	w.SetActive(0)
+	w.SetOpen(true)
+	item, _ = w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	assert.NotNil(t, item)
+	select {
+	case action := <-w.ActionChan():
+		assert.Equal(t, item.Action, action)
+	default:
+		t.Fatal("no action was sent")
+	}
+
+	// A full channel doesn't block selections
+	for i := 0; i < actionChanSize+1; i++ {
+		w.SetActive(0)
+		w.SetOpen(true)
+		item, _ := w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+		assert.NotNil(t, item)
+	}
+	assert.Len(t, w.ActionChan(), actionChanSize)
+}
+
+func TestRenderToCells(t *testing.T) {
+	s := initTestScreen(t, 40, 12)
+	w := NewMenuWindow(0, 0, 40, 1)
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	w.RenderToCells(40, 12) // The clicked menu is drawn pressed once
+
+	cells := w.RenderToCells(40, 12)
+	var rows []string
+	for _, row := range cells {
+		text := ""
+		for _, c := range row {
+			text += string(c.Rune)
+		}
+		rows = append(rows, text)
+	}
+	assert.Equal(t, []string{
+		" File  Edit  View  Search  Tools  Help  ",
+		"┌───────────────────────┐               ",
+		"│ New            Ctrl-t │               ",
+		"│ Open           Ctrl-o │               ",
+		"│ Open Recent         ▶ │               ",
+		"│───────────────────────│               ",
+		"│ Save           Ctrl-s │               ",
+		"│ Save As               │               ",
+		"│───────────────────────│               ",
+		"│ Quit           Ctrl-q │               ",
+		"└───────────────────────┘               ",
+		"                                        ",
+	}, rows)
+
+	// The open menu and the active item are highlighted
+	theme := CurrentMenuTheme()
+	assert.Equal(t, theme.BarActive, cells[0][1].Style)
+	assert.Equal(t, theme.Bar, cells[0][7].Style)
+	assert.Equal(t, theme.Selected, cells[2][2].Style)
+	assert.Equal(t, theme.Dropdown, cells[3][2].Style)
+
+	// Drawing on the screen draws the same cells
+	w.Display()
+	w.GetActiveDropdown().Display()
+	s.Show()
+	contents, width, _ := s.GetContents()
+	for y, row := range cells {
+		for x, c := range row {
+			got := contents[y*width+x]
+			assert.Equal(t, string(c.Rune), string(got.Runes), "rune at %d,%d", x, y)
+			assert.Equal(t, c.Style, got.Style, "style at %d,%d", x, y)
+		}
+	}
+}
+
+func TestRightAlignedMenus(t *testing.T) {
+	initTestScreen(t, 40, 10)
+	w := NewMenuWindow(0, 0, 40, 1)
+	w.MenuItems = []MenuItem{
+		{Name: "File", Action: "file", Enabled: true},
+		{Name: "Edit", Action: "edit", Enabled: true},
+		{Name: "Gear", Action: "gear", Enabled: true, Align: AlignRight},
+	}
+	assert.Equal(t, []int{0, 6, 34, -1}, w.layout())
+
+	// Clicks land on the right-aligned menu
+	w.HandleClick(36, 0, tcell.Button1, tcell.ModNone)
+	assert.Equal(t, 2, w.Active)
+	assert.Equal(t, 34, w.getMenuItemX(w.Active))
+
+	// Left menus that would collide with the right ones move into the
+	// overflow button
+	w.Width = 14
+	assert.Equal(t, []int{-1, -1, 8, 0}, w.layout())
+}
+
+func TestOverflowMenu(t *testing.T) {
+	initTestScreen(t, 30, 20)
+	w := NewMenuWindow(0, 0, 30, 1)
+
+	// Tools and Help don't fit, so the button takes their place
+	assert.Equal(t, []int{0, 6, 12, 18, -1, -1, 26}, w.layout())
+	assert.Equal(t, []int{4, 5}, w.hiddenMenus())
+
+	// Clicking the button lists the hidden menus
+	_, consumed := w.HandleClick(27, 0, tcell.Button1, tcell.ModNone)
+	assert.True(t, consumed)
+	assert.Equal(t, w.overflowIndex(), w.Active)
+	assert.True(t, w.IsOpen())
+	dropdown := w.GetActiveDropdown()
+	if assert.NotNil(t, dropdown) && assert.Len(t, dropdown.Items, 2) {
+		assert.Equal(t, "Tools", dropdown.Items[0].Text)
+		assert.Equal(t, "Help", dropdown.Items[1].Text)
+		assert.Same(t, w.dropdownMenus["help"], dropdown.Items[1].Submenu)
+	}
+
+	// The hidden menus open as submenus of the button
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	w.HandleKeyNavigation(0, int(tcell.KeyRight), tcell.ModNone)
+	assert.True(t, w.dropdownMenus["help"].IsVisible())
+
+	// Navigation steps from the last menu on the bar to the button
+	w.SetActive(3)
+	w.SetOpen(true)
+	w.navigateToNextMenu()
+	assert.Equal(t, w.overflowIndex(), w.Active)
+	w.navigateToPreviousMenu()
+	assert.Equal(t, 3, w.Active)
+
+	// A hidden menu is opened below the button
+	assert.Equal(t, 26, w.getMenuItemX(5))
+
+	// Everything fits again on a wide bar
+	w.Width = 80
+	assert.Equal(t, -1, w.layout()[w.overflowIndex()])
+}
+
+func TestAddRemoveMenus(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	n := len(w.MenuItems)
+
+	w.AddMenu(MenuItem{Name: "Plugin", Action: "plugin", Hotkey: 'p', Enabled: true}, []DropdownItem{
+		{Text: "Run", Action: "lua:plugin.run", Hotkey: 'R', Enabled: true},
+	})
+	assert.Len(t, w.MenuItems, n+1)
+	assert.Equal(t, n, w.hotkeyMenuIndex('p'))
+
+	assert.NoError(t, w.AddDropdownItem("plugin", DropdownItem{Text: "Longer Item", Action: "lua:plugin.other", Enabled: true}))
+	assert.Error(t, w.AddDropdownItem("missing", DropdownItem{Text: "Item", Action: "A", Enabled: true}))
+	assert.Len(t, w.dropdownMenus["plugin"].Items, 2)
+	assert.Equal(t, 15, w.dropdownMenus["plugin"].Width)
+
+	assert.NoError(t, w.RemoveDropdownItem("plugin", "lua:plugin.other"))
+	assert.Error(t, w.RemoveDropdownItem("plugin", "lua:plugin.other"))
+	assert.Len(t, w.dropdownMenus["plugin"].Items, 1)
+
+	assert.NoError(t, w.RemoveMenu("plugin"))
+	assert.Error(t, w.RemoveMenu("plugin"))
+	assert.Len(t, w.MenuItems, n)
+	assert.Equal(t, -1, w.hotkeyMenuIndex('p'))
+}
+
+func TestValidateHotkeys(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	assert.Empty(t, w.ValidateHotkeys())
+
+	w.AddMenu(MenuItem{Name: "Format", Action: "format", Hotkey: 'I', Enabled: true}, []DropdownItem{
+		{Text: "Indent", Action: "Indent", Hotkey: 'I', Enabled: true},
+		{Separator: true},
+		{Text: "Dedent", Action: "Dedent", Hotkey: 'i', Enabled: true},
+	})
+	assert.Len(t, w.ValidateHotkeys(), 2)
+}
+
+func TestAutoAssignHotkeys(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.AddMenu(MenuItem{Name: "Format", Action: "format", Enabled: true}, []DropdownItem{
+		{Text: "Indent", Action: "Indent", Hotkey: 'I', Enabled: true},
+		{Text: "Dedent", Action: "Dedent", Enabled: true},
+		{Text: "Dedup", Action: "Dedup", Enabled: true},
+		{Text: "Ii", Action: "Ii", Enabled: true},
+	})
+	w.AutoAssignHotkeys()
+
+	// File, Edit, View, Search, Tools and Help use i, d, w, s, t and h
+	assert.Equal(t, 'F', w.MenuItems[len(w.MenuItems)-1].Hotkey)
+	items := w.dropdownMenus["format"].Items
+	assert.Equal(t, 'D', items[1].Hotkey)
+	assert.Equal(t, 'e', items[2].Hotkey)
+	assert.Equal(t, rune(0), items[3].Hotkey)
+	assert.Empty(t, w.ValidateHotkeys())
+}
+
+func TestTypeahead(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetActive(0)
+	w.SetOpen(true)
+	dropdown := w.GetActiveDropdown()
+	for i := range dropdown.Items {
+		dropdown.Items[i].Hotkey = 0
+	}
+
+	// Typeahead moves the highlight without selecting anything
+	item, consumed := w.HandleKeyNavigation('q', int(tcell.KeyRune), tcell.ModNone)
+	assert.Nil(t, item)
+	assert.True(t, consumed)
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "Quit", dropdown.GetActiveItem().Action)
+}
+
+func TestAutoHide(t *testing.T) {
+	s := initTestScreen(t, 40, 10)
+	w := NewMenuWindow(0, 0, 40, 1)
+	assert.Equal(t, 1, w.ReservedHeight())
+
+	w.SetAutoHide(true)
+	assert.Equal(t, 0, w.ReservedHeight())
+	assert.False(t, w.Visible)
+	w.Display()
+	s.Show()
+	cells, _, _ := s.GetContents()
+	assert.Equal(t, ' ', cells[1].Runes[0])
+
+	// Clicks on the hidden bar don't open menus
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.False(t, w.IsOpen())
+
+	w.ShowTemporarily()
+	w.Display()
+	s.Show()
+	cells, _, _ = s.GetContents()
+	assert.Equal(t, 'F', cells[1].Runes[0])
+
+	// The bar stays while a menu is open
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.True(t, w.IsOpen())
+	w.HideIfAuto()
+	assert.True(t, w.Visible)
+	w.SetOpen(false)
+	w.SetActive(-1)
+	w.HideIfAuto()
+	assert.False(t, w.Visible)
+}
+
+func TestMnemonicsWhileAltHeld(t *testing.T) {
+	s := initTestScreen(t, 40, 10)
+	w := NewMenuWindow(0, 0, 40, 1)
+	underlined := func() bool {
+		w.Display()
+		s.Show()
+		cells, _, _ := s.GetContents()
+		// The hotkey of the File menu is its i
+		_, _, attr := cells[2].Style.Decompose()
+		return attr&tcell.AttrUnderline != 0
+	}
+	assert.False(t, underlined())
+
+	w.SetAltHeld(true)
+	assert.True(t, underlined())
+
+	// Menus opened with the mouse hide the dropdown hotkeys too
+	w.SetAltHeld(false)
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.False(t, underlined())
+	assert.True(t, w.GetActiveDropdown().HideMnemonics)
+
+	// The keyboard shows them
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	assert.True(t, underlined())
+	assert.False(t, w.GetActiveDropdown().HideMnemonics)
+}
+
+func TestStopAtEdgeMenus(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetWrapNavigation(false)
+	assert.False(t, w.dropdownMenus["file"].WrapNavigation)
+
+	w.SetActive(0)
+	w.navigateToPreviousMenu()
+	assert.Equal(t, 0, w.Active)
+
+	last := len(w.MenuItems) - 1
+	w.SetActive(last)
+	w.navigateToNextMenu()
+	assert.Equal(t, last, w.Active)
+
+	w.SetWrapNavigation(true)
+	w.navigateToNextMenu()
+	assert.Equal(t, 0, w.Active)
+}
+
+func TestOccupiedRegions(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	assert.Equal(t, []image.Rectangle{image.Rect(0, 0, 80, 1)}, w.OccupiedRegions())
+
+	w.SetActive(0)
+	w.SetOpen(true)
+	x, y, dw, dh := w.GetActiveDropdown().Bounds()
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(0, 0, 80, 1),
+		image.Rect(x, y, x+dw, y+dh),
+	}, w.OccupiedRegions())
+}
+
+func TestHoverSwitchesMenus(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Hovering the bar doesn't open menus by itself
+	assert.False(t, w.HandleMotion(7, 0))
+	assert.False(t, w.IsOpen())
+
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.Equal(t, 0, w.Active)
+	assert.True(t, w.HandleMotion(2, 0))
+	assert.Equal(t, 0, w.Active)
+
+	// Moving to Edit opens it in place of File
+	assert.True(t, w.HandleMotion(7, 0))
+	assert.Equal(t, 1, w.Active)
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "edit", w.GetMenuAction())
+
+	// Moving over the dropdown highlights the item under the pointer
+	edit := w.GetActiveDropdown()
+	assert.True(t, w.HandleMotion(edit.shownX+2, edit.shownY+1))
+	assert.Equal(t, 0, edit.hoverActive.item)
+	assert.Equal(t, 1, w.Active)
+
+	// Motion away from the menus is kept from the editor while one is
+	// open, and removes the highlight
+	assert.True(t, w.WantsMotion())
+	assert.True(t, w.HandleMotion(70, 20))
+	assert.Equal(t, -1, edit.hoverActive.item)
+	w.CloseAll()
+	assert.False(t, w.WantsMotion())
+	assert.False(t, w.HandleMotion(70, 20))
+}
+
+func TestHoverDelay(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.HoverDelayMs = 20
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+
+	// Passing over Edit doesn't open it
+	assert.True(t, w.HandleMotion(7, 0))
+	assert.True(t, w.HoverWait() > 0)
+	assert.True(t, w.HandleMotion(2, 0))
+	assert.Equal(t, 0, w.Active)
+	assert.Equal(t, time.Duration(0), w.HoverWait())
+
+	// Resting on it does, once the delay is over
+	assert.True(t, w.HandleMotion(7, 0))
+	assert.False(t, w.HoverStep())
+	assert.Equal(t, 0, w.Active)
+	time.Sleep(25 * time.Millisecond)
+	assert.True(t, w.HoverStep())
+	assert.Equal(t, 1, w.Active)
+	assert.Equal(t, time.Duration(0), w.HoverWait())
+
+	// Submenus are opened after the delay as well
+	w.SetActive(0)
+	w.SetOpen(true)
+	file := w.GetActiveDropdown()
+	y := file.shownY + 1
+	for file.itemAt(2, y-file.shownY) != 2 {
+		y++
+	}
+	file.HandleHover(file.shownX+2, y)
+	assert.Nil(t, file.ActiveSubmenu())
+	time.Sleep(25 * time.Millisecond)
+	file.HandleHover(file.shownX+2, y)
+	assert.NotNil(t, file.ActiveSubmenu())
+
+	// Without a delay menus switch at once
+	w.HoverDelayMs = 0
+	assert.True(t, w.HandleMotion(7, 0))
+	assert.Equal(t, 1, w.Active)
+	assert.Equal(t, time.Duration(0), w.HoverWait())
+}
+
+func TestSetEnabled(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.True(t, w.IsOpen())
+
+	// Disabling the bar closes the open menu and ignores all input
+	w.SetEnabled(false)
+	assert.False(t, w.Enabled())
+	assert.False(t, w.IsOpen())
+	_, consumed := w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.False(t, consumed)
+	assert.False(t, w.HandleMouseDown(1, 0))
+	assert.False(t, w.HandleKey('i', tcell.ModAlt))
+	_, consumed = w.HandleKeyNavigation('i', int(tcell.KeyRune), tcell.ModAlt)
+	assert.False(t, consumed)
+	assert.False(t, w.WantsKey('i'))
+	assert.False(t, w.FocusBar())
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, -1, w.Active)
+
+	// It stays visible, dimmed
+	cells := w.RenderToCells(80, 1)
+	assert.Equal(t, 'F', cells[0][1].Rune)
+	assert.Equal(t, CurrentMenuTheme().Bar.Dim(true), cells[0][1].Style)
+
+	w.SetEnabled(true)
+	assert.True(t, w.HandleKey('i', tcell.ModAlt))
+	assert.True(t, w.IsOpen())
+}
+
+func TestSetMenuEnabled(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetMenuEnabled("view", false)
+	assert.Equal(t, -1, w.layout()[2])
+	assert.Equal(t, -1, w.hotkeyMenuIndex('w'))
+
+	// Keyboard navigation skips the disabled menu
+	w.SetActive(1)
+	w.navigateToNextMenu()
+	assert.Equal(t, 3, w.Active)
+	w.navigateToPreviousMenu()
+	assert.Equal(t, 1, w.Active)
+
+	// Disabling the open menu closes it
+	w.SetOpen(true)
+	w.SetMenuEnabled("edit", false)
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, -1, w.Active)
+	assert.Nil(t, w.GetActiveDropdown())
+
+	w.SetMenuEnabled("view", true)
+	assert.Equal(t, 2, w.hotkeyMenuIndex('w'))
+}
+
+func TestActionRunner(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	assert.Error(t, w.RunAction("Save"))
+
+	var ran []string
+	w.SetActionRunner(func(cmdline string) error {
+		ran = append(ran, cmdline)
+		return nil
+	})
+	assert.NoError(t, w.RunAction("Save"))
+	assert.NoError(t, w.RunAction("set colorscheme monokai"))
+	assert.Equal(t, []string{"Save", "set colorscheme monokai"}, ran)
+}
+
+func TestRepeatLastAction(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.EditorState = func() EditorState { return EditorState{} }
+	var ran []string
+	w.SetActionRunner(func(cmdline string) error {
+		ran = append(ran, cmdline)
+		return nil
+	})
+	assert.Equal(t, "", w.LastAction())
+	assert.Error(t, w.RepeatLastAction())
+
+	w.SetActive(0)
+	w.SetOpen(true)
+	item, _ := w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, item.Action, w.LastAction())
+	}
+	assert.NoError(t, w.RepeatLastAction())
+	assert.Equal(t, []string{"NewTab"}, ran)
+
+	// The repeat item at the top of the Tools menu names the action and
+	// choosing it doesn't replace the last action
+	w.SetActive(4)
+	w.SetOpen(true)
+	tools := w.GetActiveDropdown()
+	assert.Equal(t, "Repeat: New", tools.Items[0].Text)
+	assert.True(t, tools.Items[0].Enabled)
+	item, _ = w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, RepeatLastAction, item.Action)
+	}
+	assert.Equal(t, "NewTab", w.LastAction())
+}
+
+func TestPromptItem(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.EditorState = func() EditorState { return EditorState{} }
+
+	// Choosing Go to Line returns it for the app to prompt for the line
+	assert.True(t, w.HandleKey('s', tcell.ModAlt))
+	item, consumed := w.HandleKeyNavigation('g', int(tcell.KeyRune), tcell.ModNone)
+	assert.True(t, consumed)
+	if assert.NotNil(t, item) {
+		assert.True(t, item.NeedsInput())
+		assert.Equal(t, "Line: ", item.Prompt)
+		assert.Equal(t, "goto", item.Action)
+	}
+	assert.False(t, w.IsOpen())
+
+	// It can't be repeated without its input
+	assert.Equal(t, "", w.LastAction())
+
+	assert.False(t, DropdownItem{Text: "Save", Action: "Save"}.NeedsInput())
+	assert.False(t, DropdownItem{Text: "Docs", URL: "https://example.com", Prompt: "Page: "}.NeedsInput())
+}
+
+func TestDropdownOnShortTerminal(t *testing.T) {
+	tests := []struct {
+		height int
+		item   string // text of the item in the only visible row, if any
+	}{
+		{height: 3},
+		{height: 4, item: "New"},
+		{height: 5, item: "New"},
+	}
+	for _, tt := range tests {
+		w := NewMenuWindow(0, 0, 40, 1)
+		w.SetActive(0)
+		w.SetOpen(true)
+		d := w.GetActiveDropdown()
+		cells := w.RenderToCells(40, tt.height)
+
+		// The dropdown stays below the menu bar, with its bottom border in
+		// the last row it reaches and a "more" indicator
+		assert.Equal(t, 'F', cells[0][1].Rune, "height %d", tt.height)
+		assert.Equal(t, '┌', cells[1][0].Rune, "height %d", tt.height)
+		bottom := 2
+		if tt.item != "" {
+			bottom = 3
+		}
+		assert.Equal(t, '└', cells[bottom][0].Rune, "height %d", tt.height)
+		assert.Equal(t, '▼', cells[bottom][d.Width-2].Rune, "height %d", tt.height)
+
+		// Clicks resolve to the items of the truncated view
+		item, _ := w.HandleClick(3, 2, tcell.Button1, tcell.ModNone)
+		if tt.item == "" {
+			assert.Nil(t, item, "height %d", tt.height)
+			continue
+		}
+		assert.Equal(t, tt.item[0], byte(cells[2][2].Rune), "height %d", tt.height)
+		if assert.NotNil(t, item, "height %d", tt.height) {
+			assert.Equal(t, tt.item, item.Text, "height %d", tt.height)
+		}
+	}
+}
+
+func TestCloseAll(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Closing with nothing open does nothing
+	w.CloseAll()
+	assert.Equal(t, -1, w.GetActive())
+	assert.False(t, w.IsOpen())
+
+	// A highlighted menu that isn't open is reset
+	w.SetActive(2)
+	w.CloseAll()
+	assert.Equal(t, -1, w.GetActive())
+
+	// Open menus and submenus are hidden and focus is given back
+	w.Focus()
+	w.SetOpen(true)
+	d := w.GetActiveDropdown()
+	w.CloseAll()
+	assert.Equal(t, -1, w.GetActive())
+	assert.False(t, w.IsOpen())
+	assert.False(t, w.IsFocused())
+	assert.False(t, d.IsVisible())
+}
+
+func TestNoNavigationWhileRevealing(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	config.GlobalSettings["menuanimate"] = true
+	defer func() { config.GlobalSettings["menuanimate"] = false }()
+
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetActive(0)
+	w.SetOpen(true)
+	d := w.GetActiveDropdown()
+	assert.True(t, d.Revealing())
+	item, _ := w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	assert.Nil(t, item)
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	assert.Equal(t, 0, d.Active)
+
+	// Clicks on the rows revealed so far don't choose items either
+	d.Step()
+	d.Step()
+	d.Step()
+	choice, consumed := w.HandleClick(d.shownX+2, d.shownY+1, tcell.Button1, tcell.ModNone)
+	assert.Nil(t, choice)
+	assert.True(t, consumed)
+	assert.True(t, w.IsOpen())
+
+	for d.Step() {
+	}
+	choice, _ = w.HandleClick(d.shownX+2, d.shownY+1, tcell.Button1, tcell.ModNone)
+	if assert.NotNil(t, choice) {
+		assert.Equal(t, d.Items[0].Action, choice.Action)
+	}
+	w.SetActive(0)
+	w.SetOpen(true)
+	for d.Step() {
+	}
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	assert.Equal(t, 1, d.Active)
+
+	// Escape closes the dropdown even while it rolls down
+	w.SetActive(0)
+	w.SetOpen(true)
+	w.HandleKeyNavigation(0, int(tcell.KeyEscape), tcell.ModNone)
+	assert.False(t, w.IsOpen())
+}
+
+func TestHandleClickConsumed(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Clicks in the editor are passed on while no menu is open
+	item, consumed := w.HandleClick(10, 10, tcell.Button1, tcell.ModNone)
+	assert.Nil(t, item)
+	assert.False(t, consumed)
+
+	// Opening a menu consumes the click
+	item, consumed = w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.Nil(t, item)
+	assert.True(t, consumed)
+	assert.True(t, w.IsOpen())
+
+	// So do clicks on the dropdown's border
+	x, y, _, _ := w.GetActiveDropdown().Bounds()
+	_, consumed = w.HandleClick(x, y, tcell.Button1, tcell.ModNone)
+	assert.True(t, consumed)
+	assert.True(t, w.IsOpen())
+
+	// Choosing an item returns it
+	item, consumed = w.HandleClick(x+2, y+1, tcell.Button1, tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "NewTab", item.Action)
+	}
+	assert.True(t, consumed)
+
+	// Clicking elsewhere to close an open menu is consumed as well
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	_, consumed = w.HandleClick(10, 20, tcell.Button1, tcell.ModNone)
+	assert.True(t, consumed)
+	assert.False(t, w.IsOpen())
+}
+
+func TestAltActionClick(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	file := w.dropdownMenus["file"]
+	file.Items[1].AltAction = "OpenInNewTab"
+
+	click := func(button tcell.ButtonMask, mod tcell.ModMask) *Choice {
+		w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+		x, y, _, _ := w.GetActiveDropdown().Bounds()
+		item, _ := w.HandleClick(x+2, y+2, button, mod)
+		return item
+	}
+
+	// Plain clicks run the item's action
+	if item := click(tcell.Button1, tcell.ModNone); assert.NotNil(t, item) {
+		assert.Equal(t, "Open", item.Action)
+	}
+
+	// Ctrl-clicks and middle clicks run the variant
+	if item := click(tcell.Button1, tcell.ModCtrl); assert.NotNil(t, item) {
+		assert.Equal(t, "OpenInNewTab", item.Action)
+	}
+	if item := click(tcell.Button3, tcell.ModNone); assert.NotNil(t, item) {
+		assert.Equal(t, "OpenInNewTab", item.Action)
+	}
+	assert.Equal(t, "Open", file.Items[1].Action)
+
+	// Items without a variant run their action either way
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	x, y, _, _ := w.GetActiveDropdown().Bounds()
+	if item, _ := w.HandleClick(x+2, y+1, tcell.Button1, tcell.ModCtrl); assert.NotNil(t, item) {
+		assert.Equal(t, "NewTab", item.Action)
+	}
+}
+
+func TestOpenMenu(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	assert.False(t, w.OpenMenu("nonexistent"))
+	assert.Nil(t, w.SelectDropdownItem("Undo"))
+	w.SetMenuEnabled("view", false)
+	assert.False(t, w.OpenMenu("view"))
+	assert.False(t, w.IsOpen())
+
+	assert.True(t, w.OpenMenu("edit"))
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "edit", w.GetMenuAction())
+	dropdown := w.GetActiveDropdown()
+	if item := w.SelectDropdownItem("Paste"); assert.NotNil(t, item) {
+		assert.Equal(t, "Paste", item.Action)
+		assert.Equal(t, item, dropdown.GetActiveItem())
+	}
+	assert.Nil(t, w.SelectDropdownItem("Quit"))
+
+	// Submenus leading to the item are opened
+	sub := NewDropdownMenu()
+	sub.SetItems([]DropdownItem{
+		{Text: "HTML", Action: "ExportHTML", Enabled: true},
+		{Text: "PDF", Action: "ExportPDF", Enabled: true},
+	})
+	assert.NoError(t, w.AddDropdownItem("file", DropdownItem{Text: "Export", Action: "export", Enabled: true, Submenu: sub}))
+	assert.True(t, w.OpenMenu("file"))
+	if item := w.SelectDropdownItem("ExportPDF"); assert.NotNil(t, item) {
+		assert.Equal(t, "ExportPDF", item.Action)
+	}
+	assert.Equal(t, sub, w.GetActiveDropdown().FocusedMenu())
+	assert.Equal(t, 1, sub.Active)
+
+	// And the inline items of accordions are expanded
+	w.AddMenu(MenuItem{Name: "Export", Action: "exportmenu", Enabled: true},
+		[]DropdownItem{{Text: "Export", Action: "export", Enabled: true, Submenu: sub}})
+	w.dropdownMenus["exportmenu"].Accordion = true
+	assert.True(t, w.OpenMenu("exportmenu"))
+	if item := w.SelectDropdownItem("ExportHTML"); assert.NotNil(t, item) {
+		assert.Equal(t, "ExportHTML", item.Action)
+	}
+	accordion := w.GetActiveDropdown()
+	assert.True(t, accordion.isExpanded(0))
+	assert.Equal(t, menuRow{0, 0}, accordion.activeRow())
+}
+
+func TestMenuNotch(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	config.GlobalSettings["menunotch"] = true
+	defer func() { config.GlobalSettings["menunotch"] = false }()
+	w := NewMenuWindow(0, 0, 80, 1)
+	border := func(cells [][]Cell, from, to int) string {
+		s := ""
+		for x := from; x < to; x++ {
+			s += string(cells[1][x].Rune)
+		}
+		return s
+	}
+
+	// The border is open below the menu's name
+	w.OpenMenu("edit")
+	x, _, width, _ := w.GetActiveDropdown().Bounds()
+	assert.Equal(t, 6, x)
+	cells := w.RenderToCells(80, 24)
+	assert.Equal(t, "│     └───", border(cells, 6, 16))
+	assert.Equal(t, '┐', cells[1][x+width-1].Rune)
+
+	// And joined to the right corner when the dropdown is moved left to fit
+	w.MenuItems[5].Align = AlignRight
+	w.OpenMenu("help")
+	x, _, width, _ = w.GetActiveDropdown().Bounds()
+	cells = w.RenderToCells(80, 24)
+	assert.Equal(t, "┌", border(cells, x, x+1))
+	assert.Equal(t, 79, x+width) // Leaving room for the shadow
+	assert.Equal(t, "─┘    │", border(cells, 72, 79))
+
+	// Without the option the border is closed
+	config.GlobalSettings["menunotch"] = false
+	w.OpenMenu("edit")
+	cells = w.RenderToCells(80, 24)
+	assert.Equal(t, "┌─────────", border(cells, 6, 16))
+}
+
+func TestClone(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	sub := NewDropdownMenu()
+	sub.SetItems([]DropdownItem{{Text: "Inner", Action: "inner", Enabled: true}})
+	assert.NoError(t, w.AddDropdownItem("file", DropdownItem{Text: "More", Enabled: true, Submenu: sub}))
+
+	c := w.Clone()
+	assert.Equal(t, w.MenuItems, c.MenuItems)
+	assert.Equal(t, len(w.actionIndex), len(c.actionIndex))
+
+	// Changing the original leaves the clone as it was
+	w.MenuItems[0].Name = "Changed"
+	w.dropdownMenus["file"].Items[0].Text = "Changed"
+	sub.Items[0].Text = "Changed"
+	w.AddMenu(MenuItem{Name: "Extra", Action: "extra", Enabled: true}, nil)
+	assert.Equal(t, "File", c.MenuItems[0].Name)
+	assert.NotEqual(t, "Changed", c.dropdownMenus["file"].Items[0].Text)
+	items := c.dropdownMenus["file"].Items
+	assert.Equal(t, "Inner", items[len(items)-1].Submenu.Items[0].Text)
+	assert.Equal(t, -1, c.menuIndex("extra"))
+
+	// And the other way around
+	assert.NoError(t, c.RemoveMenu("edit"))
+	assert.GreaterOrEqual(t, w.menuIndex("edit"), 0)
+	assert.Contains(t, w.dropdownMenus, "edit")
+}
+
+func TestPopulatedItemsEnabledStates(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	state := EditorState{}
+	w.EditorState = func() EditorState { return state }
+	sub := NewDropdownMenu()
+	sub.PopulateFunc = func() []DropdownItem {
+		return []DropdownItem{{Text: "Paste", Action: "Paste", Enabled: true}}
+	}
+	assert.True(t, w.SetMenuPopulateFunc("edit", func() []DropdownItem {
+		return []DropdownItem{
+			{Text: "Copy", Action: "Copy", Enabled: true},
+			{Text: "Other", Action: "other", Enabled: true},
+			{Text: "More", Enabled: true, Submenu: sub},
+		}
+	}))
+
+	// The EnabledFuncs apply to the items built when the menu is opened,
+	// and to those of its submenus
+	w.OpenMenu("edit")
+	edit := w.GetActiveDropdown()
+	assert.Equal(t, "Copy", edit.Items[0].Action)
+	assert.False(t, edit.Items[0].Enabled)
+	assert.True(t, edit.Items[1].Enabled)
+	assert.Equal(t, 1, edit.Active)
+	edit.MoveDown()
+	assert.True(t, sub.IsVisible())
+	assert.False(t, sub.Items[0].Enabled)
+	w.CloseAll()
+
+	state = EditorState{HasSelection: true, HasClipboard: true}
+	w.OpenMenu("edit")
+	assert.True(t, w.GetActiveDropdown().Items[0].Enabled)
+}
+
+func TestCloneOpenMenu(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	var events []string
+	w.OnMenuOpen = func(action string) { events = append(events, "open "+action) }
+	w.OnMenuClose = func(action string) { events = append(events, "close "+action) }
+	w.OnItemSelected = func(item DropdownItem) { events = append(events, "select "+item.Action) }
+	w.OpenMenu("file")
+	assert.Equal(t, []string{"open file"}, events)
+
+	// Cloning doesn't report the original's menu as closed, and using the
+	// clone doesn't notify the original's listeners
+	c := w.Clone()
+	assert.Equal(t, []string{"open file"}, events)
+	assert.True(t, w.IsOpen())
+	assert.True(t, w.GetActiveDropdown().IsVisible())
+	assert.False(t, c.IsOpen())
+	c.OpenMenu("edit")
+	c.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	c.CloseAll()
+	assert.Equal(t, []string{"open file"}, events)
+
+	w.CloseAll()
+	assert.Equal(t, []string{"open file", "close file"}, events)
+}
+
+func TestHotkeySelectionReturnsItem(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.AddMenu(MenuItem{Name: "Greek", Action: "greek", Enabled: true}, []DropdownItem{
+		{Text: "Alpha", Action: "alpha", Hotkey: 'a', Enabled: true},
+		{Text: "Beta", Action: "beta", Hotkey: 'b', Enabled: true},
+		{Text: "Gamma", Action: "gamma", Hotkey: 'g', Enabled: true},
+	})
+	w.SetActive(w.menuIndex("greek"))
+	w.SetOpen(true)
+
+	item, _ := w.HandleKeyNavigation('a', int(tcell.KeyRune), tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "alpha", item.Action)
+		assert.Equal(t, "Alpha", item.Text)
+		assert.Same(t, &w.dropdownMenus["greek"].Items[0], item.DropdownItem)
+	}
+}
+
+func TestRepeatCountChoice(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.EditorState = func() EditorState { return EditorState{CanUndo: true} }
+	key := func(r rune) (*Choice, bool) {
+		return w.HandleKeyNavigation(r, int(tcell.KeyRune), tcell.ModNone)
+	}
+
+	// Digits typed before a repeatable item are returned with it
+	assert.True(t, w.OpenMenu("edit"))
+	key('1')
+	key('2')
+	choice, consumed := key('u')
+	assert.True(t, consumed)
+	if assert.NotNil(t, choice) {
+		assert.Equal(t, "Undo", choice.Action)
+		assert.Equal(t, 12, choice.Count)
+	}
+
+	// Choosing with Enter counts the same
+	assert.True(t, w.OpenMenu("edit"))
+	key('3')
+	choice, _ = w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, choice) {
+		assert.Equal(t, 3, choice.Count)
+	}
+
+	// Other items run once, and so do items chosen without a count
+	assert.True(t, w.OpenMenu("file"))
+	key('5')
+	if choice, _ = key('n'); assert.NotNil(t, choice) {
+		assert.Equal(t, "NewTab", choice.Action)
+		assert.Equal(t, 1, choice.Count)
+	}
+	assert.True(t, w.OpenMenu("edit"))
+	if choice, _ = key('u'); assert.NotNil(t, choice) {
+		assert.Equal(t, 1, choice.Count)
+	}
+}
+
+func TestChoiceSelection(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	state := EditorState{}
+	w.EditorState = func() EditorState { return state }
+
+	// Replace in Selection is only enabled with a selection
+	assert.True(t, w.OpenMenu("search"))
+	assert.Nil(t, w.SelectDropdownItem("ReplaceInSelection"))
+	w.CloseAll()
+
+	// And is chosen along with its bounds
+	state = EditorState{HasSelection: true, Selection: [2]buffer.Loc{{X: 2, Y: 1}, {X: 5, Y: 3}}}
+	assert.True(t, w.OpenMenu("search"))
+	assert.NotNil(t, w.SelectDropdownItem("ReplaceInSelection"))
+	choice, _ := w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, choice) {
+		assert.Equal(t, "ReplaceInSelection", choice.Action)
+		assert.Equal(t, [2]buffer.Loc{{X: 2, Y: 1}, {X: 5, Y: 3}}, choice.Selection)
+	}
+
+	// Items chosen without a selection have none
+	state = EditorState{Selection: [2]buffer.Loc{{X: 2, Y: 1}, {X: 2, Y: 1}}}
+	assert.True(t, w.OpenMenu("search"))
+	choice, _ = w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, choice) {
+		assert.Equal(t, "Find", choice.Action)
+		assert.Equal(t, [2]buffer.Loc{}, choice.Selection)
+	}
+}
+
+func TestLifecycleCallbacks(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	var events []string
+	w.OnMenuOpen = func(action string) { events = append(events, "open "+action) }
+	w.OnMenuClose = func(action string) { events = append(events, "close "+action) }
+	w.OnItemSelected = func(item DropdownItem) { events = append(events, "select "+item.Action) }
+
+	w.SetActive(0)
+	w.SetOpen(true)
+	w.SetOpen(true)
+	w.navigateToNextMenu()
+	w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	assert.Equal(t, []string{"open file", "close file", "open edit", "close edit", "select " + w.dropdownMenus["edit"].Items[0].Action}, events)
+
+	// Without callbacks nothing is called
+	w.OnMenuOpen, w.OnMenuClose, w.OnItemSelected = nil, nil, nil
+	w.SetActive(0)
+	w.SetOpen(true)
+	w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	w.CloseAll()
+}
+
+func TestDragToSelect(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Presses in the editor are passed on
+	assert.False(t, w.HandleMouseDown(10, 10))
+	assert.False(t, w.IsDragging())
+
+	// Pressing on a menu opens it and dragging highlights items
+	assert.True(t, w.HandleMouseDown(1, 0))
+	assert.True(t, w.IsOpen())
+	assert.True(t, w.IsDragging())
+	x, y, _, _ := w.GetActiveDropdown().Bounds()
+	assert.True(t, w.HandleMouseDrag(x+2, y+2))
+	assert.Equal(t, menuRow{1, -1}, w.GetActiveDropdown().hoverActive)
+
+	// Releasing on an item chooses it
+	expected := w.GetActiveDropdown().Items[1].Action
+	item, ended := w.HandleMouseUp(x+2, y+2)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, expected, item.Action)
+	}
+	assert.True(t, ended)
+	assert.False(t, w.IsOpen())
+	assert.False(t, w.IsDragging())
+
+	// Releasing on the pressed menu keeps it open like a click
+	w.HandleMouseDown(1, 0)
+	item, _ = w.HandleMouseUp(1, 0)
+	assert.Nil(t, item)
+	assert.True(t, w.IsOpen())
+
+	// And pressing it again closes it
+	w.HandleMouseDown(1, 0)
+	w.HandleMouseUp(1, 0)
+	assert.False(t, w.IsOpen())
+
+	// Dragging across the bar switches menus and releasing off the menus
+	// closes them
+	w.HandleMouseDown(1, 0)
+	w.HandleMouseDrag(7, 0)
+	assert.Equal(t, 1, w.GetActive())
+	item, ended = w.HandleMouseUp(60, 20)
+	assert.Nil(t, item)
+	assert.True(t, ended)
+	assert.False(t, w.IsOpen())
+
+	// Without a drag releases are not handled
+	_, ended = w.HandleMouseUp(1, 0)
+	assert.False(t, ended)
+}
+
+func TestTranslate(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	german := map[string]string{"File": "Datei", "Edit": "Bearbeiten", "New": "Neue Registerkarte"}
+	w.Translate = func(s string) string {
+		if tr, ok := german[s]; ok {
+			return tr
+		}
+		return s
+	}
+
+	// Widths follow the translated names
+	xs := w.layout()
+	assert.Equal(t, 0, xs[0])
+	assert.Equal(t, 7, xs[1])
+	assert.Equal(t, 19, xs[2])
+	assert.True(t, w.OpenByPrefix("bear"))
+	assert.Equal(t, 1, w.GetActive())
+
+	// Dropdown labels are translated, but actions stay as they are
+	w.SetActive(0)
+	w.SetOpen(true)
+	dropdown := w.GetActiveDropdown()
+	assert.Equal(t, "Neue Registerkarte", dropdown.translate(dropdown.Items[0].Text))
+	assert.GreaterOrEqual(t, dropdown.Width, 20)
+	item, _ := w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "NewTab", item.Action)
+	}
+}
+
+func TestItemPadding(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.MenuItems = w.MenuItems[:2]
+	assert.Equal(t, []int{0, 6, -1}, w.layout())
+
+	// The bar and its hit-testing follow the padding
+	w.ItemPadding = 2
+	assert.Equal(t, []int{0, 8, -1}, w.layout())
+	w.HandleClick(7, 0, tcell.Button1, tcell.ModNone)
+	assert.Equal(t, 0, w.GetActive())
+	w.HandleClick(8, 0, tcell.Button1, tcell.ModNone)
+	assert.Equal(t, 1, w.GetActive())
+	cells := w.RenderToCells(80, 24)
+	row := ""
+	for _, c := range cells[0][:16] {
+		row += string(c.Rune)
+	}
+	assert.Equal(t, "  File    Edit  ", row)
+
+	// So do the dropdowns, down to their minimum width
+	w.ItemPadding = 0
+	w.MinDropdownWidth = 4
+	w.SetActive(0)
+	w.SetOpen(true)
+	dropdown := w.GetActiveDropdown()
+	assert.Equal(t, 0, dropdown.ItemPadding)
+	dropdown.SetItems([]DropdownItem{{Text: "A", Action: "a", Enabled: true}})
+	assert.Equal(t, 4, dropdown.Width)
+	dropdown.SetItems([]DropdownItem{{Text: "Longer", Action: "a", Enabled: true}})
+	assert.Equal(t, 8, dropdown.Width)
+}
+
+func TestRadioSelection(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.AddMenu(MenuItem{Name: "Tabs", Action: "tabs", Enabled: true}, []DropdownItem{
+		{Text: "2", Action: "set tabsize 2", Enabled: true, RadioGroup: "tabsize"},
+		{Text: "4", Action: "set tabsize 4", Enabled: true, RadioGroup: "tabsize", Checked: true},
+		{Text: "8", Action: "set tabsize 8", Enabled: true, RadioGroup: "tabsize"},
+	})
+	var selected DropdownItem
+	w.OnItemSelected = func(item DropdownItem) { selected = item }
+
+	// Choosing an item of the group unchecks the others
+	w.SetActive(w.menuIndex("tabs"))
+	w.SetOpen(true)
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	assert.Equal(t, "set tabsize 8", selected.Action)
+	assert.True(t, selected.Checked)
+	items := w.dropdownMenus["tabs"].Items
+	assert.False(t, items[0].Checked)
+	assert.False(t, items[1].Checked)
+	assert.True(t, items[2].Checked)
+}
+
+func TestResizeWhileOpen(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.MenuItems[0].Align = AlignRight
+	w.SetActive(0)
+	w.SetOpen(true)
+	w.RenderToCells(80, 24)
+	assert.Equal(t, 74, w.GetActiveDropdown().X)
+
+	// The dropdown follows its menu once the bar is drawn again, however
+	// many resizes there were in between
+	w.Resize(70, 1)
+	w.Resize(60, 1)
+	assert.Equal(t, 60, w.Width)
+	assert.Equal(t, 1, w.Height)
+	w.RenderToCells(60, 24)
+	assert.Equal(t, 54, w.GetActiveDropdown().X)
+	assert.True(t, w.IsOpen())
+
+	// A menu that doesn't fit anymore opens below the overflow button
+	w.SetActive(5)
+	w.SetOpen(true)
+	w.Resize(30, 1)
+	w.RenderToCells(30, 24)
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, w.layout()[w.overflowIndex()], w.GetActiveDropdown().X)
+
+	// And is closed if not even the overflow button fits
+	w.Resize(2, 1)
+	w.RenderToCells(2, 24)
+	assert.False(t, w.IsOpen())
+	assert.Equal(t, -1, w.GetActive())
+}
+
+func TestCompactMode(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.Resize(80, 1)
+	assert.False(t, w.IsCompact())
+
+	// Narrow bars show a single button holding every menu
+	w.Resize(20, 1)
+	assert.True(t, w.IsCompact())
+	xs := w.layout()
+	assert.Equal(t, 0, xs[w.overflowIndex()])
+	for i := range w.MenuItems {
+		assert.Equal(t, -1, xs[i])
+	}
+	assert.Equal(t, w.overflowIndex(), w.slotAt(2, 0))
+
+	w.HandleClick(2, 0, tcell.Button1, tcell.ModNone)
+	assert.True(t, w.IsOpen())
+	menu := w.GetActiveDropdown()
+	if assert.NotNil(t, menu) && assert.Len(t, menu.Items, len(w.MenuItems)) {
+		assert.Equal(t, "File", menu.Items[0].Text)
+		assert.Equal(t, w.dropdownMenus["file"], menu.Items[0].Submenu)
+	}
+
+	// Alt+hotkey opens the menus below the button
+	w.CloseAll()
+	w.HandleKey('d', tcell.ModAlt)
+	assert.Equal(t, "edit", w.GetMenuAction())
+	assert.Equal(t, 0, w.GetActiveDropdown().X)
+
+	// Switching modes closes the open menu
+	config.GlobalSettings["menucompact"] = "never"
+	defer func() { config.GlobalSettings["menucompact"] = "auto" }()
+	w.Resize(20, 1)
+	assert.False(t, w.IsCompact())
+	assert.False(t, w.IsOpen())
+
+	config.GlobalSettings["menucompact"] = "always"
+	w.Resize(80, 1)
+	assert.True(t, w.IsCompact())
+}
+
+func TestVerticalOrientation(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 10)
+	w.Orientation = Vertical
+
+	// Menus are stacked one per row in a sidebar as wide as the widest
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, -1}, w.layout())
+	assert.Equal(t, 8, w.sidebarWidth())
+	assert.Equal(t, 0, w.ReservedHeight())
+	assert.Equal(t, 8, w.ReservedWidth())
+	cells := w.RenderToCells(80, 24)
+	col := func(y int) string {
+		s := ""
+		for _, c := range cells[y][:8] {
+			s += string(c.Rune)
+		}
+		return s
+	}
+	assert.Equal(t, " File   ", col(0))
+	assert.Equal(t, " Search ", col(3))
+
+	// Clicks are hit-tested by row and dropdowns open to the right
+	_, consumed := w.HandleClick(6, 2, tcell.Button1, tcell.ModNone)
+	assert.True(t, consumed)
+	assert.Equal(t, 2, w.GetActive())
+	dropdown := w.GetActiveDropdown()
+	assert.Equal(t, 8, dropdown.X)
+	assert.Equal(t, 2, dropdown.Y)
+	_, consumed = w.HandleClick(9, 0, tcell.Button1, tcell.ModNone)
+	assert.True(t, consumed)
+	assert.False(t, w.IsOpen())
+
+	// The focused sidebar is navigated with up and down
+	w.ToggleFocus()
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	assert.Equal(t, 1, w.GetActive())
+	w.HandleKeyNavigation(0, int(tcell.KeyRight), tcell.ModNone)
+	assert.True(t, w.IsOpen())
+	w.CloseAll()
+
+	// Menus that don't fit go into the overflow button on the last row
+	w.Height = 4
+	assert.Equal(t, []int{0, 1, 2, -1, -1, -1, 3}, w.layout())
+	assert.Equal(t, w.overflowIndex(), w.slotAt(1, 3))
+	assert.Equal(t, []int{3, 4, 5}, w.hiddenMenus())
+}
+
+func TestTinyScreen(t *testing.T) {
+	initTestScreen(t, 1, 1)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetActive(0)
+	w.SetOpen(true)
+
+	// Drawing doesn't panic when the menus are far larger than the screen
+	assert.NotPanics(t, func() {
+		w.Display()
+		w.RenderToCells(1, 1)
+	})
+
+	// The same holds for menus placed entirely off-screen
+	w.Y = 5
+	assert.NotPanics(t, w.Display)
+	assert.NotPanics(t, func() { safeSetContent(-1, -1, 'x', nil, config.DefStyle) })
+}
+
+func TestNoScreen(t *testing.T) {
+	saved := screen.Screen
+	screen.Screen = nil
+	defer func() { screen.Screen = saved }()
+
+	// The menus can be created, opened and drawn before the screen is up
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetActive(0)
+	w.SetOpen(true)
+	assert.NotPanics(t, func() {
+		w.Display()
+		w.GetActiveDropdown().Display()
+	})
+	assert.Greater(t, w.GetActiveDropdown().Width, 0)
+	_, _, width, _ := w.GetActiveDropdown().Bounds()
+	assert.Equal(t, 0, width)
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.SetActive(0)
+	w.SetOpen(true)
+	recent := w.recentFilesMenu()
+	assert.Equal(t, "", recent.Title)
+
+	config.GlobalSettings["menubreadcrumbs"] = true
+	defer func() { config.GlobalSettings["menubreadcrumbs"] = false }()
+	w.SetOpen(true)
+	assert.Equal(t, "File ▸ Open Recent", recent.Title)
+	assert.Equal(t, "", w.GetActiveDropdown().Title)
+
+	// The title is centered in the top border, which is widened for it
+	assert.Equal(t, len([]rune(recent.Title))+4, recent.Width)
+	recent.Show(0, 0)
+	cells := recent.RenderToCells(40, 10)
+	top := ""
+	for _, c := range cells[0][:recent.Width] {
+		top += string(c.Rune)
+	}
+	assert.Equal(t, "┌ File ▸ Open Recent ┐", top)
+}
+
+func TestPressedMenu(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	defer ResetMenuTheme()
+	theme := DefaultMenuTheme()
+	theme.BarActive = tcell.StyleDefault.Background(tcell.ColorTeal)
+	theme.BarPressed = tcell.StyleDefault.Background(tcell.ColorMaroon)
+	SetMenuTheme(theme)
+	w := NewMenuWindow(0, 0, 80, 1)
+
+	// Clicking a menu open draws it pressed for a single frame
+	w.HandleClick(1, 0, tcell.Button1, tcell.ModNone)
+	assert.True(t, w.IsOpen())
+	assert.True(t, w.Pressed())
+	cells := w.RenderToCells(80, 24)
+	assert.Equal(t, theme.BarPressed, cells[0][1].Style)
+	assert.False(t, w.Pressed())
+	cells = w.RenderToCells(80, 24)
+	assert.Equal(t, theme.BarActive, cells[0][1].Style)
+
+	// As does pressing the button on it, but not pressing it closed
+	w.CloseAll()
+	w.HandleMouseDown(1, 0)
+	assert.True(t, w.Pressed())
+	w.HandleMouseUp(1, 0)
+	w.RenderToCells(80, 24)
+	w.HandleMouseDown(1, 0)
+	assert.False(t, w.Pressed())
+	w.HandleMouseUp(1, 0)
+	assert.False(t, w.IsOpen())
+
+	// Or opening it with the keyboard
+	w.CloseAll()
+	w.OpenMenu("file")
+	assert.False(t, w.Pressed())
+	cells = w.RenderToCells(80, 24)
+	assert.Equal(t, theme.BarActive, cells[0][1].Style)
+}
+
+func TestWideMenuNames(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.MenuItems = nil
+	items := []DropdownItem{{Text: "Item", Action: "item", Enabled: true}}
+	w.AddMenu(MenuItem{Name: "文件", Action: "file", Hotkey: '件', Enabled: true}, items)
+	w.AddMenu(MenuItem{Name: "Cafe\u0301", Action: "cafe", Hotkey: 'f', Enabled: true}, items)
+	w.AddMenu(MenuItem{Name: "👩\u200d💻 Dev", Action: "dev", Hotkey: 'd', Enabled: true}, items)
+	w.SetAltHeld(true)
+
+	// Each rune is as wide as it is drawn, combining marks and joiners
+	// included, so the menus are laid out where they are drawn
+	assert.Equal(t, []int{0, 6, 12, -1}, w.layout())
+	underlined := func(c Cell) bool {
+		_, _, attr := c.Style.Decompose()
+		return attr&tcell.AttrUnderline != 0
+	}
+	cells := w.RenderToCells(80, 24)
+	assert.Equal(t, '文', cells[0][1].Rune)
+	assert.Equal(t, '件', cells[0][3].Rune)
+	assert.True(t, underlined(cells[0][3]))
+	assert.Equal(t, 'e', cells[0][10].Rune)
+	assert.Equal(t, []rune{'\u0301'}, cells[0][10].Comb)
+	assert.True(t, underlined(cells[0][9]))
+	assert.Equal(t, '👩', cells[0][13].Rune)
+	assert.Equal(t, '💻', cells[0][15].Rune)
+	assert.Equal(t, 'D', cells[0][18].Rune)
+	assert.True(t, underlined(cells[0][18]))
+	assert.Equal(t, ' ', cells[0][22].Rune)
+
+	// Clicks hit the menu drawn under them, which opens below it
+	for _, click := range []struct {
+		x      int
+		action string
+	}{{5, "file"}, {6, "cafe"}, {11, "cafe"}, {12, "dev"}, {21, "dev"}} {
+		w.CloseAll()
+		w.HandleClick(click.x, 0, tcell.Button1, tcell.ModNone)
+		assert.Equal(t, click.action, w.GetMenuAction(), "click at %d", click.x)
+		x, _, _, _ := w.GetActiveDropdown().Bounds()
+		assert.Equal(t, w.layout()[w.GetActive()], x)
+	}
+	w.CloseAll()
+	w.HandleClick(22, 0, tcell.Button1, tcell.ModNone)
+	assert.False(t, w.IsOpen())
+}
+
+func TestHitTest(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	sub := NewDropdownMenu()
+	sub.SetItems([]DropdownItem{{Text: "Inner", Action: "inner", Enabled: true}})
+	w.AddMenu(MenuItem{Name: "Nest", Action: "nest", Enabled: true}, []DropdownItem{
+		{Text: "Outer", Enabled: true, Submenu: sub},
+	})
+
+	// The whole bar row belongs to the menus, the rest only while open
+	assert.True(t, w.HitTest(79, 0))
+	assert.False(t, w.HitTest(1, 1))
+	assert.True(t, w.OpenMenu("file"))
+	w.RenderToCells(80, 24)
+	x, y, width, height := w.GetActiveDropdown().Bounds()
+	assert.True(t, w.HitTest(x, y))
+	assert.True(t, w.HitTest(x+width-1, y+height-1))
+	assert.True(t, w.HitTest(x+width, y+1))
+	assert.True(t, w.HitTest(x+1, y+height))
+	assert.False(t, w.HitTest(x+width, y))
+	assert.False(t, w.HitTest(x+width+1, y+1))
+	assert.False(t, w.HitTest(x, y+height))
+
+	w.GetActiveDropdown().ShadowEnabled = false
+	assert.False(t, w.HitTest(x+width, y+1))
+
+	// Open submenus belong to the menus as well
+	assert.True(t, w.OpenMenu("nest"))
+	assert.NotNil(t, w.SelectDropdownItem("inner"))
+	w.RenderToCells(80, 24)
+	sx, sy, _, _ := sub.Bounds()
+	assert.True(t, sub.IsVisible())
+	assert.True(t, w.HitTest(sx+1, sy+1))
+	w.CloseAll()
+	assert.False(t, w.HitTest(sx+1, sy+1))
+}
+
+func TestAltItemHotkey(t *testing.T) {
+	w := NewMenuWindow(0, 0, 80, 1)
+	press := func(key rune, mod tcell.ModMask) *Choice {
+		item, consumed := w.HandleKeyNavigation(key, int(tcell.KeyRune), mod)
+		assert.True(t, consumed)
+		return item
+	}
+
+	// Alt+i opens File and Alt+s, still holding Alt, chooses Save rather
+	// than opening Search
+	assert.Nil(t, press('i', tcell.ModAlt))
+	assert.Equal(t, "file", w.GetMenuAction())
+	item := press('s', tcell.ModAlt)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Save", item.Action)
+	}
+	assert.False(t, w.IsOpen())
+
+	// Without Alt and in either case
+	press('i', tcell.ModAlt)
+	item = press('S', tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Save", item.Action)
+	}
+
+	// Alt+letter of a menu without a matching item switches to it
+	press('i', tcell.ModAlt)
+	assert.Nil(t, press('w', tcell.ModAlt))
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "view", w.GetMenuAction())
+	w.CloseAll()
+
+	// Alt+letter chooses items of long dropdowns instead of filtering them
+	var items []DropdownItem
+	for i := 0; i < filterMinItems; i++ {
+		items = append(items, DropdownItem{Text: fmt.Sprintf("Item %d", i), Action: fmt.Sprint(i), Enabled: true})
+	}
+	items[4].Hotkey = 'k'
+	w.AddMenu(MenuItem{Name: "Long", Action: "long", Hotkey: 'l', Enabled: true}, items)
+	press('l', tcell.ModAlt)
+	item = press('k', tcell.ModAlt)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "4", item.Action)
+	}
+	press('l', tcell.ModAlt)
+	assert.Nil(t, press('k', tcell.ModNone))
+	assert.Equal(t, "k", w.GetActiveDropdown().Filter())
+}
+
+func TestKeepOpenOnSelect(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.AddMenu(MenuItem{Name: "Options", Action: "options", Enabled: true}, []DropdownItem{
+		{Text: "Wrap", Action: "wrap", Hotkey: 'w', Enabled: true, Checkable: true, KeepOpenOnSelect: true},
+		{Text: "Tabs", Action: "tabs", Enabled: true, RadioGroup: "indent", KeepOpenOnSelect: true},
+		{Text: "Spaces", Action: "spaces", Enabled: true, RadioGroup: "indent", Checked: true, KeepOpenOnSelect: true},
+		{Text: "Close", Action: "close", Enabled: true},
+	})
+	assert.True(t, w.OpenMenu("options"))
+	w.RenderToCells(80, 24)
+	d := w.GetActiveDropdown()
+	x, y, _, _ := d.Bounds()
+
+	// Clicking a toggle fires it and checks it, leaving it highlighted
+	item, consumed := w.HandleClick(x+2, y+1, tcell.Button1, tcell.ModNone)
+	assert.True(t, consumed)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "wrap", item.Action)
+	}
+	assert.True(t, w.IsOpen())
+	assert.True(t, d.Items[0].Checked)
+	assert.Equal(t, 0, d.Active)
+	assert.Equal(t, "wrap", w.LastAction())
+
+	// As does Enter on a radio item, which unchecks the others
+	w.HandleKeyNavigation(0, int(tcell.KeyDown), tcell.ModNone)
+	item, _ = w.HandleKeyNavigation(0, int(tcell.KeyEnter), tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "tabs", item.Action)
+	}
+	assert.True(t, w.IsOpen())
+	assert.True(t, d.Items[1].Checked)
+	assert.False(t, d.Items[2].Checked)
+	assert.Equal(t, 1, d.Active)
+
+	// And its hotkey, or a drag released on it
+	item, _ = w.HandleKeyNavigation('w', int(tcell.KeyRune), tcell.ModNone)
+	assert.NotNil(t, item)
+	assert.False(t, d.Items[0].Checked)
+	assert.Equal(t, 0, d.Active)
+	w.RenderToCells(80, 24)
+	assert.True(t, w.HandleMouseDown(x+2, y+3))
+	item, _ = w.HandleMouseUp(x+2, y+3)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "spaces", item.Action)
+	}
+	assert.True(t, w.IsOpen())
+	assert.True(t, d.Items[2].Checked)
+	assert.Equal(t, 2, d.Active)
+
+	// Other items close the menu
+	item, _ = w.HandleClick(x+2, y+4, tcell.Button1, tcell.ModNone)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "close", item.Action)
+	}
+	assert.False(t, w.IsOpen())
+}
+
+func TestOpenByPrefix(t *testing.T) {
+	initTestScreen(t, 80, 24)
+	w := NewMenuWindow(0, 0, 80, 1)
+	w.AddMenu(MenuItem{Name: "Settings", Action: "settings", Enabled: true}, []DropdownItem{
+		{Text: "Options", Action: "Options", Enabled: true},
+	})
+
+	// Prefixes are matched ignoring case, and the menu gets keyboard focus
+	assert.True(t, w.OpenByPrefix("fi"))
+	assert.True(t, w.IsOpen())
+	assert.Equal(t, "file", w.GetMenuAction())
+	assert.True(t, w.keyboardFocus)
+	assert.True(t, w.OpenByPrefix("SET"))
+	assert.Equal(t, "settings", w.GetMenuAction())
+
+	// An ambiguous prefix opens the first match in bar order, and a
+	// disabled menu is skipped
+	assert.True(t, w.OpenByPrefix("se"))
+	assert.Equal(t, "search", w.GetMenuAction())
+	w.SetMenuEnabled("search", false)
+	assert.True(t, w.OpenByPrefix("se"))
+	assert.Equal(t, "settings", w.GetMenuAction())
+
+	// No match leaves the menu bar as it was
+	w.CloseAll()
+	assert.False(t, w.OpenByPrefix("zzz"))
+	assert.False(t, w.OpenByPrefix(""))
+	assert.False(t, w.IsOpen())
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/micro-editor/tcell/v2"
+	"github.com/micro-editor/tcell/v2/terminfo"
 	"github.com/zyedidia/micro/v2/internal/config"
 	"github.com/zyedidia/micro/v2/internal/util"
 )
@@ -39,6 +40,76 @@ var drawChan chan bool
 // them in a list
 var rawSeq = make([]string, 0)
 
+// FocusIn and FocusOut are the raw escape sequences the terminal sends when
+// it gains and loses the focus, which it reports along with the mouse
+const (
+	FocusIn  = "\x1b[I"
+	FocusOut = "\x1b[O"
+)
+
+// xtermMouseMode is the mouse mode of the terminals tcell knows with an
+// xterm style mouse, which reports buttons and drags in SGR format, and
+// focusMouseMode the same mode reporting the focus as well. tcell sends it
+// when the mouse is enabled and disabled, and when the screen is shut down,
+// so the focus is never left reported after micro exits.
+// motionMouseMode reports any motion of the pointer too, see
+// SetMouseMotion, and stillMouseMode stops reporting it. Some terminals
+// leave all of the modes when one is left, so the any motion mode is left
+// before the others are entered and entered after them
+const (
+	xtermMouseMode  = "%?%p1%{1}%=%t%'h'%Pa%e%'l'%Pa%;\x1b[?1000%ga%c\x1b[?1002%ga%c\x1b[?1006%ga%c"
+	focusMouseMode  = xtermMouseMode + "\x1b[?1004%ga%c"
+	motionMouseMode = focusMouseMode + "\x1b[?1003%ga%c"
+	stillMouseMode  = "\x1b[?1003l" + focusMouseMode
+)
+
+// mouseInfo is the description of the terminal whose mouse mode was
+// extended, nil if it has no xterm style mouse
+var mouseInfo *terminfo.Terminfo
+
+// mouseMotion is whether the terminal is asked to report any motion of the
+// mouse pointer, see SetMouseMotion
+var mouseMotion bool
+
+// extendMouseMode has the terminal described by $TERM report the focus
+// along with the mouse, and any motion while it is asked to, if it has an
+// xterm style mouse
+func extendMouseMode() {
+	mouseInfo = nil
+	ti, err := terminfo.LookupTerminfo(os.Getenv("TERM"))
+	if err != nil {
+		return
+	}
+	switch ti.MouseMode {
+	case xtermMouseMode, motionMouseMode, stillMouseMode:
+		mouseInfo = ti
+		ti.MouseMode = stillMouseMode
+		if mouseMotion {
+			ti.MouseMode = motionMouseMode
+		}
+	}
+}
+
+// SetMouseMotion sets whether the terminal reports the mouse pointer moving
+// without a button pressed, which it otherwise only reports while one is.
+// It does nothing for terminals without an xterm style mouse
+func SetMouseMotion(on bool) {
+	if on == mouseMotion {
+		return
+	}
+	mouseMotion = on
+	if mouseInfo == nil {
+		return
+	}
+	mouseInfo.MouseMode = stillMouseMode
+	if on {
+		mouseInfo.MouseMode = motionMouseMode
+	}
+	if Screen != nil && config.GetGlobalOption("mouse").(bool) {
+		Screen.EnableMouse()
+	}
+}
+
 // Lock locks the screen lock
 func Lock() {
 	lock.Lock()
@@ -207,11 +278,13 @@ func Init() error {
 
 	// Initilize tcell
 	var err error
+	extendMouseMode()
 	Screen, err = tcell.NewScreen()
 	if err != nil {
 		log.Println("Warning: during screen initialization:", err)
 		log.Println("Falling back to TERM=xterm-256color")
 		setXterm()
+		extendMouseMode()
 		Screen, err = tcell.NewScreen()
 		if err != nil {
 			return err
@@ -235,6 +308,8 @@ func Init() error {
 	for _, r := range rawSeq {
 		Screen.RegisterRawSeq(r)
 	}
+	Screen.RegisterRawSeq(FocusIn)
+	Screen.RegisterRawSeq(FocusOut)
 
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"runtime"
 
 	shellquote "github.com/kballard/go-shellquote"
 	"github.com/zyedidia/micro/v2/internal/screen"
@@ -31,6 +32,20 @@ func ExecCommand(name string, arg ...string) (string, error) {
 	return outstring, err
 }
 
+// OpenURL opens the given URL with the operating system's default handler
+func OpenURL(url string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = ExecCommand("open", url)
+	case "windows":
+		_, err = ExecCommand("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		_, err = ExecCommand("xdg-open", url)
+	}
+	return err
+}
+
 // RunCommand executes a shell command and returns the output/error
 func RunCommand(input string) (string, error) {
 	args, err := shellquote.Split(input)